@@ -0,0 +1,121 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package lib
+
+import (
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// The functions Env, EnvOr and RequiredEnv may read any environment variable;
+// they are not part of Functions, use EnvFunctions instead.
+var (
+	// Env returns the value of an environment variable, or an empty string, if
+	// it is unset, e.g. env("APP_REGION").
+	Env = MakeEnvFunc("*")
+
+	// EnvOr returns the value of an environment variable, or its second
+	// argument, if the variable is unset or empty, e.g.
+	// env_or("APP_REGION", "eu").
+	EnvOr = MakeEnvOrFunc("*")
+
+	// RequiredEnv returns the value of an environment variable and fails, if
+	// it is unset or empty, e.g. required_env("APP_REGION").
+	RequiredEnv = MakeRequiredEnvFunc("*")
+)
+
+// EnvFunctions returns the functions env, env_or and required_env, which may
+// only read the environment variables matching allow or more, cf.
+// MakeEnvFunc. At least one pattern is required; "*" explicitly allows all
+// variables.
+func EnvFunctions(allow string, more ...string) map[string]function.Function {
+	patterns := append([]string{allow}, more...)
+	return map[string]function.Function{
+		"env":          MakeEnvFunc(patterns...),
+		"env_or":       MakeEnvOrFunc(patterns...),
+		"required_env": MakeRequiredEnvFunc(patterns...),
+	}
+}
+
+// MakeEnvFunc returns a function like Env, which may only read the environment
+// variables matching allow, either names or glob patterns like "APP_*", cf.
+// path.Match. Reading any other variable fails; if allow is empty, no
+// variable can be read.
+func MakeEnvFunc(allow ...string) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{{Name: "name", Type: cty.String}},
+		Type:   function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			value, _, err := lookupEnv(args[0].AsString(), allow)
+			if err != nil {
+				return cty.NilVal, function.NewArgError(0, err)
+			}
+			return cty.StringVal(value), nil
+		},
+	})
+}
+
+// MakeEnvOrFunc returns a function like EnvOr, which may only read the
+// environment variables matching allow, cf. MakeEnvFunc.
+func MakeEnvOrFunc(allow ...string) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{Name: "name", Type: cty.String},
+			{Name: "default", Type: cty.String},
+		},
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			value, _, err := lookupEnv(args[0].AsString(), allow)
+			if err != nil {
+				return cty.NilVal, function.NewArgError(0, err)
+			}
+			if len(value) <= 0 {
+				return args[1], nil
+			}
+			return cty.StringVal(value), nil
+		},
+	})
+}
+
+// MakeRequiredEnvFunc returns a function like RequiredEnv, which may only read
+// the environment variables matching allow, cf. MakeEnvFunc.
+func MakeRequiredEnvFunc(allow ...string) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{{Name: "name", Type: cty.String}},
+		Type:   function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			name := args[0].AsString()
+			value, ok, err := lookupEnv(name, allow)
+			if err != nil {
+				return cty.NilVal, function.NewArgError(0, err)
+			}
+			if !ok {
+				return cty.NilVal, function.NewArgErrorf(0, "required environment variable %q is not set", name)
+			}
+			if len(value) <= 0 {
+				return cty.NilVal, function.NewArgErrorf(0, "required environment variable %q is empty", name)
+			}
+			return cty.StringVal(value), nil
+		},
+	})
+}
+
+// lookupEnv retrieves the value of the environment variable name like
+// os.LookupEnv, if it matches allow, cf. MakeEnvFunc.
+func lookupEnv(name string, allow []string) (string, bool, error) {
+	if len(name) <= 0 {
+		return "", false, fmt.Errorf("the name of an environment variable must not be empty")
+	}
+	for _, pattern := range allow {
+		if ok, _ := path.Match(pattern, name); ok || pattern == name {
+			value, ok := os.LookupEnv(name)
+			return value, ok, nil
+		}
+	}
+	return "", false, fmt.Errorf("reading the environment variable %q is not allowed", name)
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package lib_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+
+	"github.com/sobchak-security/klutz/pkg/cty/function/lib"
+)
+
+func TestEnvFunctions(t *testing.T) {
+	t.Setenv("TEST_ENV_REGION", "eu")
+	t.Setenv("TEST_ENV_EMPTY", "")
+	t.Setenv("TEST_SECRET", "s3cr3t")
+
+	contexts := map[string]*hcl.EvalContext{
+		"all":        lib.NewEvalContext(nil, lib.EnvFunctions("*")),
+		"restricted": lib.NewEvalContext(nil, lib.EnvFunctions("TEST_ENV_*", "TEST_OTHER")),
+		"none":       lib.NewEvalContext(nil, map[string]function.Function{"env": lib.MakeEnvFunc()}),
+	}
+
+	tests := []struct {
+		name    string
+		ctx     string
+		expr    string
+		want    cty.Value
+		wantErr string
+	}{
+		{name: "success: env", expr: `env("TEST_ENV_REGION")`, want: cty.StringVal("eu")},
+		{name: "success: env unset", expr: `env("TEST_ENV_UNSET")`, want: cty.StringVal("")},
+		{name: "success: env_or", expr: `env_or("TEST_ENV_REGION", "us")`, want: cty.StringVal("eu")},
+		{name: "success: env_or unset", expr: `env_or("TEST_ENV_UNSET", "us")`, want: cty.StringVal("us")},
+		{name: "success: env_or empty", expr: `env_or("TEST_ENV_EMPTY", "us")`, want: cty.StringVal("us")},
+		{name: "success: required_env", expr: `required_env("TEST_ENV_REGION")`, want: cty.StringVal("eu")},
+		{name: "success: unrestricted", expr: `env("TEST_SECRET")`, want: cty.StringVal("s3cr3t")},
+		{name: "success: allowed pattern", ctx: "restricted", expr: `env("TEST_ENV_REGION")`, want: cty.StringVal("eu")},
+		{name: "success: allowed name", ctx: "restricted", expr: `env_or("TEST_OTHER", "x")`, want: cty.StringVal("x")},
+		{
+			name:    "failure: required_env unset",
+			expr:    `required_env("TEST_ENV_UNSET")`,
+			wantErr: `required environment variable "TEST_ENV_UNSET" is not set`,
+		},
+		{
+			name:    "failure: required_env empty",
+			expr:    `required_env("TEST_ENV_EMPTY")`,
+			wantErr: `required environment variable "TEST_ENV_EMPTY" is empty`,
+		},
+		{
+			name:    "failure: env not allowed",
+			ctx:     "restricted",
+			expr:    `env("TEST_SECRET")`,
+			wantErr: `reading the environment variable "TEST_SECRET" is not allowed`,
+		},
+		{
+			name:    "failure: env_or not allowed",
+			ctx:     "restricted",
+			expr:    `env_or("TEST_SECRET", "x")`,
+			wantErr: `not allowed`,
+		},
+		{
+			name:    "failure: no patterns allow nothing",
+			ctx:     "none",
+			expr:    `env("TEST_ENV_REGION")`,
+			wantErr: `reading the environment variable "TEST_ENV_REGION" is not allowed`,
+		},
+
+		{
+			name:    "failure: empty name",
+			expr:    `env("")`,
+			wantErr: `must not be empty`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := contexts[tt.ctx]
+			if ctx == nil {
+				ctx = contexts["all"]
+			}
+			got, diags := testEval(t, ctx, tt.expr)
+			if len(tt.wantErr) > 0 {
+				if !diags.HasErrors() || !strings.Contains(diags.Error(), tt.wantErr) {
+					t.Errorf("Value() error = %v, want %q", diags, tt.wantErr)
+				}
+				// the diagnostic points at the name
+				if diags.HasErrors() && diags[0].Subject.Start.Column == 1 {
+					t.Errorf("Value() diagnostic subject = %s, want the argument", diags[0].Subject)
+				}
+				return
+			}
+			if diags.HasErrors() {
+				t.Fatalf("Value() error = %v", diags)
+			}
+			if !got.RawEquals(tt.want) {
+				t.Errorf("Value() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
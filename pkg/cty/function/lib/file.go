@@ -0,0 +1,234 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package lib
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// Sensitive marks values, which must not be disclosed, e.g. those read by
+// secret_file(), cf. cty.Value.Mark.
+const Sensitive = valueMark("sensitive")
+
+// valueMark is the type of the marks of this package.
+type valueMark string
+
+// FileFunctions returns the functions file, file_exists, fileset, filebase64
+// and secret_file, which may only read files in the directory base, cf.
+// MakeFileFunc.
+func FileFunctions(base string) map[string]function.Function {
+	return map[string]function.Function{
+		"file":        MakeFileFunc(base),
+		"file_exists": MakeFileExistsFunc(base),
+		"fileset":     MakeFilesetFunc(base),
+		"filebase64":  MakeFileBase64Func(base),
+		"secret_file": MakeSecretFileFunc(base),
+	}
+}
+
+// MakeFileFunc returns a function, which returns the content of a file as
+// string, e.g. file("endpoint.txt"); the content has to be valid UTF-8.
+// Relative paths are resolved relative to the directory base, and files
+// outside of base, including the targets of symbolic links, cannot be read.
+func MakeFileFunc(base string) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{{Name: "path", Type: cty.String}},
+		Type:   function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			b, err := readFile(base, args[0].AsString())
+			if err != nil {
+				return cty.NilVal, function.NewArgError(0, err)
+			}
+			if !utf8.Valid(b) {
+				return cty.NilVal, function.NewArgErrorf(0, "the content of %q is not valid UTF-8, use filebase64() instead", args[0].AsString())
+			}
+			return cty.StringVal(string(b)), nil
+		},
+	})
+}
+
+// MakeFileBase64Func returns a function like the one of MakeFileFunc, which
+// returns the content of a file encoded as standard base64, e.g. of binary
+// files.
+func MakeFileBase64Func(base string) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{{Name: "path", Type: cty.String}},
+		Type:   function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			b, err := readFile(base, args[0].AsString())
+			if err != nil {
+				return cty.NilVal, function.NewArgError(0, err)
+			}
+			return cty.StringVal(base64.StdEncoding.EncodeToString(b)), nil
+		},
+	})
+}
+
+// MakeSecretFileFunc returns a function like the one of MakeFileFunc, whose
+// result is marked as Sensitive and stripped of trailing line breaks, as
+// usual for secrets mounted as files, e.g. secret_file("api-key").
+func MakeSecretFileFunc(base string) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{{Name: "path", Type: cty.String}},
+		Type:   function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			b, err := readFile(base, args[0].AsString())
+			if err != nil {
+				return cty.NilVal, function.NewArgError(0, err)
+			}
+			if !utf8.Valid(b) {
+				return cty.NilVal, function.NewArgErrorf(0, "the content of %q is not valid UTF-8", args[0].AsString())
+			}
+			return cty.StringVal(strings.TrimRight(string(b), "\r\n")).Mark(Sensitive), nil
+		},
+	})
+}
+
+// MakeFileExistsFunc returns a function, which reports, whether a regular
+// file exists, e.g. file_exists("override.hcl"); paths are resolved like by
+// the function of MakeFileFunc, and paths outside of base are an error.
+func MakeFileExistsFunc(base string) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{{Name: "path", Type: cty.String}},
+		Type:   function.StaticReturnType(cty.Bool),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			path, err := resolvePath(base, args[0].AsString())
+			if errors.Is(err, fs.ErrNotExist) {
+				return cty.False, nil
+			}
+			if err != nil {
+				return cty.NilVal, function.NewArgError(0, err)
+			}
+			fi, err := os.Stat(path)
+			if err != nil {
+				return cty.NilVal, function.NewArgError(0, err)
+			}
+			return cty.BoolVal(fi.Mode().IsRegular()), nil
+		},
+	})
+}
+
+// MakeFilesetFunc returns a function, which returns the set of regular files
+// in a directory matching a pattern, cf. filepath.Match, e.g.
+// fileset("conf.d", "*.hcl"); the paths are relative to the directory and use
+// forward slashes. The directory is resolved like by the function of
+// MakeFileFunc, and matches outside of base are omitted.
+func MakeFilesetFunc(base string) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{Name: "dir", Type: cty.String},
+			{Name: "pattern", Type: cty.String},
+		},
+		Type: function.StaticReturnType(cty.Set(cty.String)),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			dir, err := resolvePath(base, args[0].AsString())
+			if err != nil {
+				return cty.NilVal, function.NewArgError(0, err)
+			}
+			pattern := filepath.FromSlash(args[1].AsString())
+			matches, err := filepath.Glob(filepath.Join(dir, pattern))
+			if err != nil {
+				return cty.NilVal, function.NewArgErrorf(1, "invalid pattern %q - %v", args[1].AsString(), err)
+			}
+			sort.Strings(matches)
+
+			var paths []cty.Value
+			for _, match := range matches {
+				path, err := resolvePath(base, match)
+				if err != nil {
+					continue
+				}
+				if fi, err := os.Stat(path); err != nil || !fi.Mode().IsRegular() {
+					continue
+				}
+				rel, err := filepath.Rel(dir, match)
+				if err != nil {
+					continue
+				}
+				paths = append(paths, cty.StringVal(filepath.ToSlash(rel)))
+			}
+			if len(paths) <= 0 {
+				return cty.SetValEmpty(cty.String), nil
+			}
+			return cty.SetVal(paths), nil
+		},
+	})
+}
+
+// readFile reads the file path resolved by resolvePath.
+func readFile(base, path string) ([]byte, error) {
+	resolved, err := resolvePath(base, path)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(resolved)
+	if err != nil {
+		return nil, fmt.Errorf("reading %q failed - %w", path, err)
+	}
+	return b, nil
+}
+
+// resolvePath resolves path relative to the directory base and evaluates
+// symbolic links; the result has to be located in base.
+func resolvePath(base, path string) (string, error) {
+	if len(base) <= 0 {
+		return "", fmt.Errorf("no base directory to read %q from", path)
+	}
+	if len(path) <= 0 {
+		return "", fmt.Errorf("the path must not be empty")
+	}
+
+	// errors of the base directory must not wrap fs.ErrNotExist, cf.
+	// MakeFileExistsFunc
+	absBase, err := filepath.Abs(base)
+	if err != nil {
+		return "", fmt.Errorf("resolving the base directory %q failed - %v", base, err)
+	}
+	root, err := filepath.EvalSymlinks(absBase)
+	if err != nil {
+		return "", fmt.Errorf("resolving the base directory %q failed - %v", base, err)
+	}
+
+	abs := path
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(absBase, abs)
+	}
+	abs, err = filepath.Abs(abs)
+	if err != nil {
+		return "", fmt.Errorf("resolving %q failed - %w", path, err)
+	}
+	resolved, err := filepath.EvalSymlinks(abs)
+	if errors.Is(err, fs.ErrNotExist) {
+		// the path has to be located in base, even though it does not exist
+		if !within(root, abs) && !within(absBase, abs) {
+			return "", fmt.Errorf("the path %q is outside of the base directory", path)
+		}
+		return "", fmt.Errorf("the path %q does not exist - %w", path, err)
+	}
+	if err != nil {
+		return "", fmt.Errorf("resolving %q failed - %w", path, err)
+	}
+	if !within(root, resolved) {
+		return "", fmt.Errorf("the path %q is outside of the base directory", path)
+	}
+	return resolved, nil
+}
+
+// within reports, whether path is located in the directory root; both have to
+// be absolute and clean.
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package lib_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/sobchak-security/klutz/pkg/cty/function/lib"
+)
+
+func TestFileFunctions(t *testing.T) {
+	root := t.TempDir()
+	base := filepath.Join(root, "base")
+	for path, content := range map[string]string{
+		"base/endpoint.txt":     "udp://graylog:12201",
+		"base/binary.bin":       "\xff\x00",
+		"base/secrets/api-key":  "s3cr3t\r\n",
+		"base/conf.d/a.hcl":     "",
+		"base/conf.d/b.hcl":     "",
+		"base/conf.d/c.txt":     "",
+		"base/conf.d/d.hcl/x":   "",
+		"outside/password.txt":  "hunter2",
+		"outside/conf.d/e.hcl":  "",
+		"base/conf.d/sub/f.hcl": "",
+	} {
+		path = filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for link, target := range map[string]string{
+		"base/escape.txt":       "../outside/password.txt",
+		"base/conf.d/e.hcl":     "../../outside/conf.d/e.hcl",
+		"base/current/key.txt":  "../secrets/api-key",
+		"base/current/dangling": "../../outside/missing.txt",
+	} {
+		link = filepath.Join(root, filepath.FromSlash(link))
+		if err := os.MkdirAll(filepath.Dir(link), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(filepath.FromSlash(target), link); err != nil {
+			t.Skipf("creating symbolic links failed - %v", err)
+		}
+	}
+
+	ctx := lib.NewEvalContext(
+		map[string]cty.Value{"abs": cty.StringVal(filepath.Join(base, "endpoint.txt"))},
+		lib.FileFunctions(base),
+	)
+
+	tests := []struct {
+		name    string
+		expr    string
+		want    cty.Value
+		wantErr string
+	}{
+		{name: "success: file", expr: `file("endpoint.txt")`, want: cty.StringVal("udp://graylog:12201")},
+		{name: "success: absolute path", expr: `file(abs)`, want: cty.StringVal("udp://graylog:12201")},
+		{name: "success: symbolic link within base", expr: `file("current/key.txt")`, want: cty.StringVal("s3cr3t\r\n")},
+		{name: "success: filebase64", expr: `filebase64("binary.bin")`, want: cty.StringVal("/wA=")},
+		{
+			name: "success: secret_file",
+			expr: `secret_file("secrets/api-key")`,
+			want: cty.StringVal("s3cr3t").Mark(lib.Sensitive),
+		},
+		{name: "success: file_exists", expr: `file_exists("endpoint.txt")`, want: cty.True},
+		{name: "success: file_exists missing", expr: `file_exists("missing.txt")`, want: cty.False},
+		{name: "success: file_exists directory", expr: `file_exists("secrets")`, want: cty.False},
+		{
+			name: "success: fileset",
+			expr: `fileset("conf.d", "*.hcl")`,
+			want: cty.SetVal([]cty.Value{cty.StringVal("a.hcl"), cty.StringVal("b.hcl")}),
+		},
+		{name: "success: fileset empty", expr: `fileset(".", "*.yaml")`, want: cty.SetValEmpty(cty.String)},
+		{name: "failure: missing file", expr: `file("missing.txt")`, wantErr: `does not exist`},
+		{name: "failure: invalid UTF-8", expr: `file("binary.bin")`, wantErr: `use filebase64() instead`},
+		{name: "failure: parent directory", expr: `file("../outside/password.txt")`, wantErr: `outside of the base directory`},
+		{name: "failure: symbolic link", expr: `file("escape.txt")`, wantErr: `outside of the base directory`},
+		{name: "failure: dangling symbolic link", expr: `file("current/dangling")`, wantErr: `does not exist`},
+		{name: "failure: file_exists outside", expr: `file_exists("../outside/password.txt")`, wantErr: `outside of the base directory`},
+		{name: "failure: fileset outside", expr: `fileset("../outside", "*")`, wantErr: `outside of the base directory`},
+		{name: "failure: fileset pattern", expr: `fileset("conf.d", "[")`, wantErr: `invalid pattern`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, diags := testEval(t, ctx, tt.expr)
+			if len(tt.wantErr) > 0 {
+				if !diags.HasErrors() || !strings.Contains(diags.Error(), tt.wantErr) {
+					t.Errorf("Value() error = %v, want %q", diags, tt.wantErr)
+				}
+				return
+			}
+			if diags.HasErrors() {
+				t.Fatalf("Value() error = %v", diags)
+			}
+			if !got.RawEquals(tt.want) {
+				t.Errorf("Value() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+
+	// without a base directory, no files can be read
+	ctx = lib.NewEvalContext(nil, lib.FileFunctions(""))
+	if _, diags := testEval(t, ctx, `file("endpoint.txt")`); !diags.HasErrors() {
+		t.Errorf("Value() error = nil, want error without base directory")
+	}
+}
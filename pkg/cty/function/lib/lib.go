@@ -0,0 +1,121 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+// Package lib provides cty functions for HCL configurations, bundled with
+// go-cty's standard library, cf. Functions and NewEvalContext.
+package lib
+
+import (
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+)
+
+// Functions returns a new function table keyed by the names used in HCL
+// expressions, which bundles the string, collection, encoding, date and time,
+// regular expression, numeric and type conversion functions of go-cty's
+// standard library with hostname(). Functions reading the environment or
+// files are left out, callers opt in by EnvFunctions and FileFunctions. The
+// names follow those of Terraform, e.g. "upper", "formatdate" or "jsonencode".
+func Functions() map[string]function.Function {
+	return map[string]function.Function{
+		// strings
+		"chomp":         stdlib.ChompFunc,
+		"format":        stdlib.FormatFunc,
+		"formatlist":    stdlib.FormatListFunc,
+		"indent":        stdlib.IndentFunc,
+		"join":          stdlib.JoinFunc,
+		"lower":         stdlib.LowerFunc,
+		"replace":       stdlib.ReplaceFunc,
+		"split":         stdlib.SplitFunc,
+		"strlen":        stdlib.StrlenFunc,
+		"strrev":        stdlib.ReverseFunc,
+		"substr":        stdlib.SubstrFunc,
+		"title":         stdlib.TitleFunc,
+		"trim":          stdlib.TrimFunc,
+		"trimprefix":    stdlib.TrimPrefixFunc,
+		"trimspace":     stdlib.TrimSpaceFunc,
+		"trimsuffix":    stdlib.TrimSuffixFunc,
+		"upper":         stdlib.UpperFunc,
+		"regex":         stdlib.RegexFunc,
+		"regexall":      stdlib.RegexAllFunc,
+		"regex_replace": stdlib.RegexReplaceFunc,
+
+		// collections
+		"chunklist":              stdlib.ChunklistFunc,
+		"coalesce":               stdlib.CoalesceFunc,
+		"coalescelist":           stdlib.CoalesceListFunc,
+		"compact":                stdlib.CompactFunc,
+		"concat":                 stdlib.ConcatFunc,
+		"contains":               stdlib.ContainsFunc,
+		"distinct":               stdlib.DistinctFunc,
+		"element":                stdlib.ElementFunc,
+		"flatten":                stdlib.FlattenFunc,
+		"keys":                   stdlib.KeysFunc,
+		"length":                 stdlib.LengthFunc,
+		"lookup":                 stdlib.LookupFunc,
+		"merge":                  stdlib.MergeFunc,
+		"range":                  stdlib.RangeFunc,
+		"reverse":                stdlib.ReverseListFunc,
+		"setintersection":        stdlib.SetIntersectionFunc,
+		"setproduct":             stdlib.SetProductFunc,
+		"setsubtract":            stdlib.SetSubtractFunc,
+		"setsymmetricdifference": stdlib.SetSymmetricDifferenceFunc,
+		"setunion":               stdlib.SetUnionFunc,
+		"slice":                  stdlib.SliceFunc,
+		"sort":                   stdlib.SortFunc,
+		"values":                 stdlib.ValuesFunc,
+		"zipmap":                 stdlib.ZipmapFunc,
+
+		// encoding
+		"csvdecode":  stdlib.CSVDecodeFunc,
+		"jsondecode": stdlib.JSONDecodeFunc,
+		"jsonencode": stdlib.JSONEncodeFunc,
+
+		// date and time
+		"formatdate": stdlib.FormatDateFunc,
+		"timeadd":    stdlib.TimeAddFunc,
+
+		// numbers
+		"abs":      stdlib.AbsoluteFunc,
+		"ceil":     stdlib.CeilFunc,
+		"floor":    stdlib.FloorFunc,
+		"log":      stdlib.LogFunc,
+		"max":      stdlib.MaxFunc,
+		"min":      stdlib.MinFunc,
+		"parseint": stdlib.ParseIntFunc,
+		"pow":      stdlib.PowFunc,
+		"signum":   stdlib.SignumFunc,
+
+		// type conversions
+		"tobool":   stdlib.MakeToFunc(cty.Bool),
+		"tonumber": stdlib.MakeToFunc(cty.Number),
+		"tostring": stdlib.MakeToFunc(cty.String),
+		"tolist":   stdlib.MakeToFunc(cty.List(cty.DynamicPseudoType)),
+		"toset":    stdlib.MakeToFunc(cty.Set(cty.DynamicPseudoType)),
+		"tomap":    stdlib.MakeToFunc(cty.Map(cty.DynamicPseudoType)),
+
+		// klutz
+		"hostname": Hostname,
+	}
+}
+
+// NewEvalContext returns an evaluation context with the variables vars, if
+// any, and the functions of Functions, which are extended or overridden by
+// the function tables funcs in the given order.
+func NewEvalContext(vars map[string]cty.Value, funcs ...map[string]function.Function) *hcl.EvalContext {
+	ctx := &hcl.EvalContext{
+		Functions: Functions(),
+		Variables: map[string]cty.Value{},
+	}
+	for name, v := range vars {
+		ctx.Variables[name] = v
+	}
+	for _, fns := range funcs {
+		for name, fn := range fns {
+			ctx.Functions[name] = fn
+		}
+	}
+	return ctx
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package lib_test
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+
+	"github.com/sobchak-security/klutz/pkg/cty/function/lib"
+)
+
+// testEval evaluates the HCL expression expr in ctx.
+func testEval(t *testing.T, ctx *hcl.EvalContext, expr string) (cty.Value, hcl.Diagnostics) {
+	t.Helper()
+
+	e, diags := hclsyntax.ParseExpression([]byte(expr), "test.hcl", hcl.InitialPos)
+	if diags.HasErrors() {
+		t.Fatalf("ParseExpression() error = %v", diags)
+	}
+	return e.Value(ctx)
+}
+
+func TestNewEvalContext(t *testing.T) {
+	t.Setenv("HOSTNAME", "test-host")
+
+	ctx := lib.NewEvalContext(
+		map[string]cty.Value{"env": cty.StringVal("prod")},
+		map[string]function.Function{"upper": lib.Hostname},
+	)
+
+	tests := []struct {
+		name string
+		expr string
+		want cty.Value
+	}{
+		{"success: strings", `lower("A-${env}")`, cty.StringVal("a-prod")},
+		{"success: collections", `join(",", sort(distinct(["b", "a", "b"])))`, cty.StringVal("a,b")},
+		{"success: encoding", `jsonencode({ env = env })`, cty.StringVal(`{"env":"prod"}`)},
+		{"success: date and time", `formatdate("YYYY-MM-DD", timeadd("2023-01-31T23:00:00Z", "1h"))`, cty.StringVal("2023-02-01")},
+		{"success: regular expressions", `regex_replace("v1.2.3", "[.]", "_")`, cty.StringVal("v1_2_3")},
+		{"success: conversions", `tonumber("42") + max(1, 2)`, cty.NumberIntVal(44)},
+		{"success: hostname", `hostname()`, cty.StringVal("test-host")},
+		{"success: overridden function", `upper()`, cty.StringVal("test-host")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, diags := testEval(t, ctx, tt.expr)
+			if diags.HasErrors() {
+				t.Fatalf("Value() error = %v", diags)
+			}
+			if !got.RawEquals(tt.want) {
+				t.Errorf("Value() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+
+	// each call returns a new function table
+	lib.Functions()["lower"] = lib.Hostname
+	if got, _ := testEval(t, lib.NewEvalContext(nil), `lower("A")`); !got.RawEquals(cty.StringVal("a")) {
+		t.Errorf("Functions() shares its table: lower(\"A\") = %#v", got)
+	}
+
+	// reading the environment or files requires opting in
+	for _, name := range []string{"env", "env_or", "required_env", "file", "secret_file"} {
+		if _, ok := lib.Functions()[name]; ok {
+			t.Errorf("Functions() contains %s()", name)
+		}
+	}
+}
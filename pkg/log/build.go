@@ -0,0 +1,267 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package log
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// encodings keeps the encoder constructors known to this package, as zap does
+// not expose its own registry, cf. zap.RegisterEncoder.
+var encodings = newRegistry("encoding", map[string]func(zapcore.EncoderConfig) (zapcore.Encoder, error){
+	"console": func(ec zapcore.EncoderConfig) (zapcore.Encoder, error) {
+		return zapcore.NewConsoleEncoder(ec), nil
+	},
+	"json": func(ec zapcore.EncoderConfig) (zapcore.Encoder, error) {
+		return zapcore.NewJSONEncoder(ec), nil
+	},
+})
+
+// RegisterEncoding registers the encoder constructor under name with zap, cf.
+// zap.RegisterEncoder, and with this package. New, NewReloader and TeeConfig
+// create encoders themselves and, as zap does not expose its registry, only
+// support encodings registered this way, besides "console" and "json".
+func RegisterEncoding(name string, constructor func(zapcore.EncoderConfig) (zapcore.Encoder, error)) error {
+	if err := zap.RegisterEncoder(name, constructor); err != nil {
+		return err
+	}
+	return encodings.register(name, constructor, constructor == nil)
+}
+
+// newEncoder creates the encoder for encoding, cf. zap's Config.Build.
+func newEncoder(encoding string, ec zapcore.EncoderConfig) (zapcore.Encoder, error) {
+	if len(encoding) <= 0 {
+		return nil, errors.New("missing encoding")
+	}
+	constructor, ok := encodings.lookup(encoding)
+	if !ok {
+		return nil, fmt.Errorf("no encoder registered for name %q, cf. RegisterEncoding", encoding)
+	}
+	return constructor(ec)
+}
+
+// New builds a logger configured by cfg, e.g. a preset configuration like
+// DevConfig or a configuration unmarshaled by Config.UnmarshalHCL. It returns
+// the logger's atomic level, i.e. cfg.Level or a new one at info level, if
+// cfg.Level is not set, and a function, which syncs the logger and closes all
+// sinks, including the error output; the logger must not be used after calling
+// it.
+func New(cfg Config, opts ...zap.Option) (*zap.Logger, zap.AtomicLevel, func() error, error) {
+	if cfg.Level == (zap.AtomicLevel{}) {
+		cfg.Level = zap.NewAtomicLevel()
+	}
+
+	core, closeSinks, err := buildCore(cfg, cfg.Level)
+	if err != nil {
+		return nil, zap.AtomicLevel{}, nil, fmt.Errorf("New(): building core failed - %w", err)
+	}
+	errSink, closeErrOut, err := zap.Open(cfg.ErrorOutputPaths...)
+	if err != nil {
+		closeSinks()
+		return nil, zap.AtomicLevel{}, nil, fmt.Errorf("New(): opening error output failed - %w", err)
+	}
+
+	logger := zap.New(core, append(buildOptions(cfg.Config, errSink), opts...)...)
+	return logger, cfg.Level, cleanupFunc(logger, closeSinks, closeErrOut), nil
+}
+
+// Build builds a logger configured by c, cf. New. Unlike zap's Config.Build,
+// which c.Config.Build still provides, it applies the settings of this package
+// and returns a function, which syncs the logger and closes all sinks; the
+// logger must not be used after calling it.
+func (c Config) Build(opts ...zap.Option) (*zap.Logger, func() error, error) {
+	l, _, cleanup, err := New(c, opts...)
+	return l, cleanup, err
+}
+
+// cleanupFunc returns a function, which syncs l and calls all closers once.
+// Repeated calls return the result of the first one.
+func cleanupFunc(l *zap.Logger, closers ...func()) func() error {
+	var once sync.Once
+	var err error
+	return func() error {
+		once.Do(func() {
+			err = syncLogger(l)
+			for _, closer := range closers {
+				closer()
+			}
+		})
+		return err
+	}
+}
+
+// syncLogger syncs l, ignoring errors of sinks, which do not support syncing,
+// e.g. stderr attached to a terminal or a pipe.
+func syncLogger(l *zap.Logger) error {
+	var errs error
+	for _, err := range multierr.Errors(l.Sync()) {
+		if !errors.Is(err, syscall.EINVAL) && !errors.Is(err, syscall.ENOTTY) {
+			errs = multierr.Append(errs, err)
+		}
+	}
+	return errs
+}
+
+// buildCore creates the core of a logger configured by cfg, whose entries are
+// enabled by enab rather than cfg.Level. Sampling, with cfg.SamplingTick, and
+// initial fields are applied. The returned function closes the sinks of
+// cfg.OutputPaths.
+func buildCore(cfg Config, enab zapcore.LevelEnabler) (zapcore.Core, func(), error) {
+	enc, err := newConfigEncoder(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sink, closeSink, err := zap.Open(cfg.OutputPaths...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	core := zapcore.NewCore(enc, sink, enab)
+
+	if sc := cfg.Sampling; sc != nil {
+		var opts []zapcore.SamplerOption
+		if sc.Hook != nil {
+			opts = append(opts, zapcore.SamplerHook(sc.Hook))
+		}
+		tick := cfg.SamplingTick
+		if tick <= 0 {
+			tick = time.Second
+		}
+		core = zapcore.NewSamplerWithOptions(core, tick, sc.Initial, sc.Thereafter, opts...)
+	}
+
+	if fields := initialFields(cfg.Config); len(fields) > 0 {
+		core = core.With(fields)
+	}
+
+	return core, closeSink, nil
+}
+
+// newConfigEncoder creates the encoder configured by cfg, including its time
+// layout and zone and its syslog options, whose fields are redacted as
+// configured by cfg.Redact, if set.
+func newConfigEncoder(cfg Config) (zapcore.Encoder, error) {
+	ec := cfg.EncoderConfig
+	var err error
+	if ec.EncodeTime, err = timeEncoder(ec.EncodeTime, cfg.TimeLayout, cfg.TimeZone); err != nil {
+		return nil, err
+	}
+
+	var enc zapcore.Encoder
+	if cfg.Syslog != (SyslogOptions{}) {
+		if cfg.Encoding != SyslogEncoding {
+			return nil, fmt.Errorf("syslog options require the encoding %q, got %q", SyslogEncoding, cfg.Encoding)
+		}
+		enc, err = NewSyslogEncoder(ec, cfg.Syslog)
+	} else {
+		enc, err = newEncoder(cfg.Encoding, ec)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Redact != nil {
+		r, err := newRedactor(*cfg.Redact)
+		if err != nil {
+			return nil, err
+		}
+		enc = r.WrapEncoder(enc)
+	}
+	return enc, nil
+}
+
+// timeEncoder returns the time encoder serializing times with layout, if set,
+// or else by enc, in the time zone named zone, if set, cf. Config.TimeLayout
+// and Config.TimeZone.
+func timeEncoder(enc zapcore.TimeEncoder, layout, zone string) (zapcore.TimeEncoder, error) {
+	var loc *time.Location
+	if len(zone) > 0 {
+		var err error
+		if loc, err = time.LoadLocation(zone); err != nil {
+			return nil, fmt.Errorf("loading time zone %q failed - %w", zone, err)
+		}
+	}
+	if len(layout) > 0 {
+		return LayoutTimeEncoder(layout, loc), nil
+	}
+	if enc == nil {
+		return nil, nil
+	}
+	return TimeEncoderIn(enc, loc), nil
+}
+
+// initialFields returns the initial fields of cfg in the order of their keys,
+// cf. zap's Config.Build.
+func initialFields(cfg zap.Config) []zap.Field {
+	keys := make([]string, 0, len(cfg.InitialFields))
+	for k := range cfg.InitialFields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fields := make([]zap.Field, 0, len(keys))
+	for _, k := range keys {
+		fields = append(fields, zap.Any(k, cfg.InitialFields[k]))
+	}
+	return fields
+}
+
+// buildOptions returns the logger options configured by cfg, except for
+// sampling and initial fields, which are part of the core built by buildCore.
+func buildOptions(cfg zap.Config, errSink zapcore.WriteSyncer) []zap.Option {
+	opts := []zap.Option{zap.ErrorOutput(errSink)}
+
+	if cfg.Development {
+		opts = append(opts, zap.Development())
+	}
+
+	if !cfg.DisableCaller {
+		opts = append(opts, zap.AddCaller())
+	}
+
+	stackLevel := zap.ErrorLevel
+	if cfg.Development {
+		stackLevel = zap.WarnLevel
+	}
+	if !cfg.DisableStacktrace {
+		opts = append(opts, zap.AddStacktrace(stackLevel))
+	}
+
+	return opts
+}
+
+// encoderFingerprint encodes a probe entry as configured by cfg, so encoder
+// configurations can be compared, even if they contain closures, e.g.
+// created by LayoutTimeEncoder.
+func encoderFingerprint(cfg Config) (string, error) {
+	enc, err := newConfigEncoder(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	probe := zapcore.Entry{
+		Level:      zapcore.WarnLevel,
+		Time:       time.Date(2001, 2, 3, 4, 5, 6, 789000000, time.UTC),
+		LoggerName: "probe.name",
+		Message:    "probe",
+		Caller:     zapcore.NewEntryCaller(0, "/probe/dir/file.go", 1, true),
+		Stack:      "probe stack",
+	}
+	buf, err := enc.EncodeEntry(probe, []zap.Field{zap.Duration("duration", 1234567*time.Microsecond)})
+	if err != nil {
+		return "", err
+	}
+	defer buf.Free()
+
+	return buf.String(), nil
+}
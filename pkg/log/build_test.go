@@ -0,0 +1,240 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package log_test
+
+import (
+	"bytes"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/sobchak-security/klutz/pkg/log"
+)
+
+// testSink is a zap.Sink recording writes, syncs and closes.
+type testSink struct {
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	syncs  int
+	closes int
+}
+
+var testSinks sync.Map
+
+func init() {
+	if err := zap.RegisterSink("testsink", func(u *url.URL) (zap.Sink, error) {
+		s, _ := testSinks.LoadOrStore(u.Host, &testSink{})
+		return s.(*testSink), nil
+	}); err != nil {
+		panic(err)
+	}
+	if err := zap.RegisterEncoder("test_zap_only", func(ec zapcore.EncoderConfig) (zapcore.Encoder, error) {
+		return zapcore.NewJSONEncoder(ec), nil
+	}); err != nil {
+		panic(err)
+	}
+	if err := log.RegisterEncoding("test_registered", func(ec zapcore.EncoderConfig) (zapcore.Encoder, error) {
+		return zapcore.NewJSONEncoder(ec), nil
+	}); err != nil {
+		panic(err)
+	}
+}
+
+func (s *testSink) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.Write(p)
+}
+
+func (s *testSink) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.syncs++
+	return nil
+}
+
+func (s *testSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closes++
+	return nil
+}
+
+func TestNew(t *testing.T) {
+	hclConfig := func(t *testing.T, conf string) log.Config {
+		t.Helper()
+		hf, diags := hclparse.NewParser().ParseHCL([]byte(conf), "new.hcl")
+		if diags.HasErrors() {
+			t.Fatalf("ParseHCL() error = %v", diags)
+		}
+		var cfg log.Config
+		if err := cfg.UnmarshalHCL(nil, hf.Body); err != nil {
+			t.Fatalf("UnmarshalHCL() error = %v", err)
+		}
+		return cfg
+	}
+
+	tests := []struct {
+		name    string
+		cfg     func(t *testing.T, sink string) log.Config
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "success: DevConfig",
+			cfg: func(t *testing.T, sink string) log.Config {
+				cfg := log.Config{Config: log.DevConfig()}
+				cfg.OutputPaths = []string{sink}
+				cfg.ErrorOutputPaths = []string{sink + "-err"}
+				cfg.DisableCaller = true
+				cfg.DisableStacktrace = true
+				return cfg
+			},
+			want: `\tDEBUG\tdebug\n.*\tWARN\twarn\n$`,
+		},
+		{
+			name: "success: StdConfig",
+			cfg: func(t *testing.T, sink string) log.Config {
+				cfg := log.Config{Config: log.StdConfig()}
+				cfg.OutputPaths = []string{sink}
+				cfg.ErrorOutputPaths = []string{sink + "-err"}
+				return cfg
+			},
+			want: `^[^\n]*WARN.*\twarn\n$`,
+		},
+		{
+			name: "success: Config",
+			cfg: func(t *testing.T, sink string) log.Config {
+				return hclConfig(t, `
+level              = "info"
+encoding           = "json"
+output_paths       = ["`+sink+`"]
+error_output_paths = ["`+sink+`-err"]
+encoder_config {
+  message_key = "msg"
+}`)
+			},
+			want: `^\{"msg":"warn"\}\n$`,
+		},
+		{
+			name: "success: RegisterEncoding",
+			cfg: func(t *testing.T, sink string) log.Config {
+				cfg := log.Config{Config: log.StdConfig()}
+				cfg.Encoding = "test_registered"
+				cfg.OutputPaths = []string{sink}
+				cfg.ErrorOutputPaths = []string{sink + "-err"}
+				return cfg
+			},
+			want: `^\{[^\n]*"warn"[^\n]*\}\n$`,
+		},
+		{
+			name: "failure: unknown encoding",
+			cfg: func(t *testing.T, sink string) log.Config {
+				cfg := log.Config{Config: log.StdConfig()}
+				cfg.Encoding = "yaml"
+				return cfg
+			},
+			wantErr: true,
+		},
+		{
+			// zap does not expose the encodings registered with it
+			name: "failure: encoding registered with zap only",
+			cfg: func(t *testing.T, sink string) log.Config {
+				cfg := log.Config{Config: log.StdConfig()}
+				cfg.Encoding = "test_zap_only"
+				return cfg
+			},
+			wantErr: true,
+		},
+		{
+			name: "failure: invalid output path",
+			cfg: func(t *testing.T, sink string) log.Config {
+				cfg := log.Config{Config: log.StdConfig()}
+				cfg.OutputPaths = []string{"unknown://path"}
+				return cfg
+			},
+			wantErr: true,
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := "new" + strconv.Itoa(i)
+			sink := "testsink://" + id
+			t.Cleanup(func() {
+				testSinks.Delete(id)
+				testSinks.Delete(id + "-err")
+			})
+			l, lvl, cleanup, err := log.New(tt.cfg(t, sink))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			l.Debug("debug")
+			lvl.SetLevel(zap.WarnLevel)
+			l.Info("info")
+			l.Warn("warn")
+			if err := cleanup(); err != nil {
+				t.Errorf("cleanup() error = %v", err)
+			}
+			if err := cleanup(); err != nil {
+				t.Errorf("cleanup() error = %v", err)
+			}
+
+			s, _ := testSinks.Load(id)
+			out := s.(*testSink)
+			testMatchLog(t, out.buf.String(), tt.want)
+			if out.syncs != 1 || out.closes != 1 {
+				t.Errorf("cleanup(): %d syncs and %d closes of output, want 1", out.syncs, out.closes)
+			}
+			s, _ = testSinks.Load(id + "-err")
+			if errOut := s.(*testSink); errOut.closes != 1 {
+				t.Errorf("cleanup(): %d closes of error output, want 1", errOut.closes)
+			}
+		})
+	}
+}
+
+func TestConfigBuild(t *testing.T) {
+	sink := "testsink://build"
+	t.Cleanup(func() {
+		testSinks.Delete("build")
+		testSinks.Delete("build-err")
+	})
+
+	cfg := log.Config{Config: log.StdConfig(), Redact: &log.RedactConfig{Keys: []string{"password"}}}
+	cfg.Encoding = "json"
+	cfg.EncoderConfig = zapcore.EncoderConfig{MessageKey: "msg"}
+	cfg.OutputPaths = []string{sink}
+	cfg.ErrorOutputPaths = []string{sink + "-err"}
+
+	l, cleanup, err := cfg.Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	l.Info("login", zap.String("password", "s3cr3t"))
+	if err := cleanup(); err != nil {
+		t.Errorf("cleanup() error = %v", err)
+	}
+
+	// unlike zap's Config.Build, the redaction is applied and the sinks are
+	// closed by the cleanup function
+	s, _ := testSinks.Load("build")
+	out := s.(*testSink)
+	testMatchLog(t, out.buf.String(), `^\{"msg":"login","password":"\[REDACTED\]"\}\n$`)
+	if out.closes != 1 {
+		t.Errorf("cleanup(): %d closes of output, want 1", out.closes)
+	}
+
+	if _, _, err := (log.Config{Config: zap.Config{Encoding: "yaml"}}).Build(); err == nil {
+		t.Errorf("Build() error = %v, want error", err)
+	}
+}
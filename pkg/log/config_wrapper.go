@@ -6,7 +6,7 @@ package log
 import (
 	"encoding/json"
 	"fmt"
-	"os"
+	"time"
 
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/gohcl"
@@ -19,77 +19,423 @@ import (
 // calling the unmarshaling function would be
 // _ = (*ConfigWrapper)(&tt.cfg).Unmarshal(m)
 // ... deal with it.
+//
+// Time layouts and zones are applied to the time encoder. Syslog options,
+// redaction and a sampling tick other than a second are errors; they require
+// Config.
 type ConfigWrapper zap.Config
 
-// UnmarshalMap supports unmarshaling a zap logger configuration from a map. This
-// way, in a pre-proccessing step, different configuration file formats can be
-// parsed. Also, enhancements, like new encoders, are processed. Lastly,
-// environment variables are resolved, including an attempt, at ensuring the
-// variable HOSTNAME (not available on every platform), is made.
+// UnmarshalMap supports unmarshaling a zap logger configuration from a map, cf.
+// Config.UnmarshalMap.
 func (cw *ConfigWrapper) UnmarshalMap(m map[string]interface{}) error {
-	b, err := json.Marshal(m)
-	if err != nil {
-		return fmt.Errorf("UnmarshalConfig(): marshaling to JSON failed - %w", err)
+	cfg := cw.config()
+	if err := cfg.UnmarshalMap(m); err != nil {
+		return err
+	}
+	return cw.set("UnmarshalConfig()", cfg, nil)
+}
+
+// UnmarshalHCL processes a HCL configuration, cf. Config.UnmarshalHCL. The
+// sampling tick has to be a second, as zap.Config always samples per second.
+func (cw *ConfigWrapper) UnmarshalHCL(ctx *hcl.EvalContext, body hcl.Body) error {
+	var cfg Config
+	if err := cfg.UnmarshalHCL(ctx, body); err != nil {
+		return err
+	}
+	return cw.set("UnmarshalHCL()", cfg, body)
+}
+
+// OverlayMap overrides the settings of cw by the keys present in m, cf.
+// Config.OverlayMap.
+func (cw *ConfigWrapper) OverlayMap(m map[string]interface{}) error {
+	cfg := cw.config()
+	if err := cfg.OverlayMap(m); err != nil {
+		return err
+	}
+	return cw.set("OverlayMap()", cfg, nil)
+}
+
+// OverlayHCL overrides the settings of cw by the attributes and blocks
+// present in body, cf. Config.OverlayHCL and UnmarshalHCL.
+func (cw *ConfigWrapper) OverlayHCL(ctx *hcl.EvalContext, body hcl.Body) error {
+	cfg := cw.config()
+	if err := cfg.OverlayHCL(ctx, body); err != nil {
+		return err
 	}
-	if _, ok := os.LookupEnv("HOSTNAME"); !ok {
-		var hostname string
-		if hostname, err = os.Hostname(); err != nil || len(hostname) <= 0 {
-			hostname, _ = os.LookupEnv("HOST")
+	return cw.set("OverlayHCL()", cfg, body)
+}
+
+// config returns the configuration of cw.
+func (cw *ConfigWrapper) config() Config {
+	return Config{Config: zap.Config(*cw)}
+}
+
+// set sets cw to the zap configuration of cfg, whose time layout and zone are
+// applied to the time encoder. The settings zap.Config cannot hold are errors
+// prefixed by name; a sampling tick is reported as a diagnostic located in
+// body, if set.
+func (cw *ConfigWrapper) set(name string, cfg Config, body hcl.Body) error {
+	switch {
+	case cfg.SamplingTick != 0 && cfg.SamplingTick != time.Second:
+		detail := fmt.Sprintf("The tick %s requires Config, as zap.Config always samples per second.", cfg.SamplingTick)
+		if body == nil {
+			return fmt.Errorf("%s: %s", name, detail)
 		}
-		if len(hostname) > 0 {
-			os.Setenv("HOSTNAME", hostname)
+		return fmt.Errorf("%s: parsing log configuration failed - %w", name, hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid sampling configuration",
+			Detail:   detail,
+			Subject:  samplingTickRange(body),
+		}})
+	case cfg.Syslog != (SyslogOptions{}):
+		return fmt.Errorf("%s: syslog options require Config", name)
+	case cfg.Redact != nil:
+		return fmt.Errorf("%s: redaction requires Config", name)
+	}
+
+	zc := cfg.Config
+	if len(cfg.TimeLayout) > 0 || len(cfg.TimeZone) > 0 {
+		var err error
+		if zc.EncoderConfig.EncodeTime, err = timeEncoder(zc.EncoderConfig.EncodeTime, cfg.TimeLayout, cfg.TimeZone); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
 		}
 	}
-	b = []byte(os.ExpandEnv(string(b)))
-	if err := json.Unmarshal(b, cw); err != nil {
+	*cw = ConfigWrapper(zc)
+	return nil
+}
+
+// samplingTickRange returns the range of the tick attribute of the sampling
+// block of body or, if there is none, the range of body.
+func samplingTickRange(body hcl.Body) *hcl.Range {
+	schema := &hcl.BodySchema{Blocks: []hcl.BlockHeaderSchema{{Type: "sampling"}}}
+	if content, _, _ := body.PartialContent(schema); content != nil && len(content.Blocks) > 0 {
+		return attributeRanges(content.Blocks[0].Body, &samplingConfigHCL{}).get("tick")
+	}
+	return body.MissingItemRange().Ptr()
+}
+
+// Config is a zap logger configuration together with the settings of this
+// package, which zap.Config cannot hold. zap's Config.Build ignores these
+// settings, hence loggers have to be built by its own Build method, New,
+// NewReloader or TeeConfig.
+type Config struct {
+	zap.Config
+
+	// SamplingTick is the interval, in which the first Sampling.Initial
+	// entries are logged, cf. zapcore.NewSamplerWithOptions; zero means a
+	// second.
+	SamplingTick time.Duration `json:"-"`
+
+	// Syslog are the options of the syslog encoding, cf. NewSyslogEncoder;
+	// they require the encoding "syslog", if set.
+	Syslog SyslogOptions `json:"-"`
+
+	// Redact configures the redaction of field values by all encoders, if
+	// set, cf. Redactor.WrapEncoder.
+	Redact *RedactConfig `json:"-"`
+
+	// TimeLayout is the layout of times, either a layout of package time or
+	// the name of one of its layout constants, cf. TimeLayout; if set, it
+	// replaces EncoderConfig.EncodeTime, cf. LayoutTimeEncoder.
+	TimeLayout string `json:"-"`
+
+	// TimeZone is the name of the time zone, e.g. "UTC", times are converted
+	// to before they are serialized, if set, cf. TimeEncoderIn.
+	TimeZone string `json:"-"`
+}
+
+// UnmarshalMap supports unmarshaling a zap logger configuration from a map. This
+// way, in a pre-proccessing step, different configuration file formats can be
+// parsed. Also, enhancements, like new encoders, are processed; encoder names
+// are resolved with the encoders registered with this package, and unknown
+// names are an error, whereas zap silently falls back to a default. Nested maps
+// with non-string keys, as produced by some YAML decoders, are supported.
+// Lastly, environment variables in string values are resolved by LookupEnv, cf.
+// UnmarshalMapLookup.
+func (c *Config) UnmarshalMap(m map[string]interface{}) error {
+	return c.UnmarshalMapLookup(m, LookupEnv)
+}
+
+// UnmarshalMapLookup works like UnmarshalMap, but resolves variables in string
+// values by lookup, cf. ExpandEnv. Map keys are never expanded.
+func (c *Config) UnmarshalMapLookup(m map[string]interface{}, lookup func(string) (string, bool)) error {
+	v, err := expandEnvValue(normalizeValue(m), lookup)
+	if err != nil {
+		return fmt.Errorf("UnmarshalConfig(): expanding variables failed - %w", err)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("UnmarshalConfig(): marshaling to JSON failed - %w", err)
+	}
+
+	encoding := c.Encoding
+	if err := json.Unmarshal(b, c); err != nil {
 		return fmt.Errorf("UnmarshalConfig(): unmarshaling (full) from JSON failed - %w", err)
 	}
 
 	var enhancements struct {
+		Redact        *RedactConfig
 		EncoderConfig struct {
-			TimeEncoder string
+			LevelEncoder    json.RawMessage
+			TimeEncoder     json.RawMessage
+			DurationEncoder json.RawMessage
+			CallerEncoder   json.RawMessage
+			NameEncoder     json.RawMessage
+			SyslogFacility  string
+			SyslogAppName   string
+			SyslogHostname  string
+			SyslogFormat    string
 		}
 	}
 	if err := json.Unmarshal(b, &enhancements); err != nil {
 		return fmt.Errorf("UnmarshalConfig(): unmarshaling (enhancements) from JSON failed - %w", err)
 	}
-	switch enhancements.EncoderConfig.TimeEncoder {
-	case ConfigKeyTimeEncoderShort:
-		cw.EncoderConfig.EncodeTime = EpochShortTimeEncoder
-	case ConfigKeyTimeEncoderInt64Seconds:
-		cw.EncoderConfig.EncodeTime = EpochInt64SecondsEncoder
+
+	ec := &c.EncoderConfig
+	if err := unmarshalEncoderName(levelEncoders, enhancements.EncoderConfig.LevelEncoder, &ec.EncodeLevel); err != nil {
+		return err
+	}
+	if err := unmarshalTimeEncoder(enhancements.EncoderConfig.TimeEncoder, &ec.EncodeTime, &c.TimeLayout, &c.TimeZone); err != nil {
+		return err
+	}
+	if err := unmarshalEncoderName(durationEncoders, enhancements.EncoderConfig.DurationEncoder, &ec.EncodeDuration); err != nil {
+		return err
+	}
+	if err := unmarshalEncoderName(callerEncoders, enhancements.EncoderConfig.CallerEncoder, &ec.EncodeCaller); err != nil {
+		return err
+	}
+	if err := unmarshalEncoderName(nameEncoders, enhancements.EncoderConfig.NameEncoder, &ec.EncodeName); err != nil {
+		return err
+	}
+
+	// syslog options of another encoding do not apply, the others are merged
+	// with those configured by m
+	opts := c.Syslog
+	if c.Encoding != encoding {
+		opts = SyslogOptions{}
+	}
+	for _, opt := range []struct {
+		dst *string
+		v   string
+	}{
+		{&opts.Facility, enhancements.EncoderConfig.SyslogFacility},
+		{&opts.AppName, enhancements.EncoderConfig.SyslogAppName},
+		{&opts.Hostname, enhancements.EncoderConfig.SyslogHostname},
+		{&opts.Format, enhancements.EncoderConfig.SyslogFormat},
+	} {
+		if len(opt.v) > 0 {
+			*opt.dst = opt.v
+		}
+	}
+	if opts != (SyslogOptions{}) {
+		if c.Encoding != SyslogEncoding {
+			return fmt.Errorf("UnmarshalConfig(): syslog options require the encoding %q, got %q", SyslogEncoding, c.Encoding)
+		}
+		if err := opts.validate(); err != nil {
+			return fmt.Errorf("UnmarshalConfig(): %w", err)
+		}
+	}
+	c.Syslog = opts
+
+	if enhancements.Redact != nil {
+		if _, err := newRedactor(*enhancements.Redact); err != nil {
+			return fmt.Errorf("UnmarshalConfig(): invalid redact configuration - %w", err)
+		}
+		c.Redact = enhancements.Redact
 	}
 	return nil
 }
 
-// UnmarshalHCL processes a HCL configuration and returns a zap.Config and
-// the unparsed remainder of body.
-func (cw *ConfigWrapper) UnmarshalHCL(ctx *hcl.EvalContext, body hcl.Body) error {
+// OverlayMap works like UnmarshalMap, but leaves the configuration, which c
+// has been copied from, e.g. a preset like StdConfig, untouched: only the
+// keys present in m override the settings of c, and the level, the sampling
+// configuration, slices and maps are replaced rather than modified. Like with
+// UnmarshalMap, initial fields are merged, the keys of the encoder
+// configuration and syslog options override those of c one by one, and a
+// redaction replaces the one of c.
+func (c *Config) OverlayMap(m map[string]interface{}) error {
+	cfg := c.clone()
+	if err := cfg.UnmarshalMap(m); err != nil {
+		return err
+	}
+	*c = cfg
+	return nil
+}
+
+// clone returns a copy of c, which does not share any mutable state with c.
+func (c *Config) clone() Config {
+	cfg := *c
+	if cfg.Level != (zap.AtomicLevel{}) {
+		cfg.Level = zap.NewAtomicLevelAt(cfg.Level.Level())
+	}
+	if cfg.Sampling != nil {
+		sc := *cfg.Sampling
+		cfg.Sampling = &sc
+	}
+	cfg.OutputPaths = append([]string(nil), cfg.OutputPaths...)
+	cfg.ErrorOutputPaths = append([]string(nil), cfg.ErrorOutputPaths...)
+	if cfg.InitialFields != nil {
+		fields := make(map[string]interface{}, len(cfg.InitialFields))
+		for k, v := range cfg.InitialFields {
+			fields[k] = v
+		}
+		cfg.InitialFields = fields
+	}
+	return cfg
+}
+
+// unmarshalTimeEncoder resolves the time encoder given by the JSON value raw,
+// which is either a name or an object with a layout (or preset name, cf.
+// TimeLayout) or an encoder name, and an optional time zone, e.g.
+// {"layout": "StampMilli", "timeZone": "UTC"}. The layout and zone are set,
+// cf. Config.TimeLayout, and reset by an encoder name.
+func unmarshalTimeEncoder(raw json.RawMessage, enc *zapcore.TimeEncoder, layout, zone *string) error {
+	var obj struct {
+		Name     string
+		Layout   string
+		TimeZone string
+	}
+	if len(raw) <= 0 {
+		return nil
+	}
+	if json.Unmarshal(raw, &obj) != nil {
+		if err := unmarshalEncoderName(timeEncoders, raw, enc); err != nil {
+			return err
+		}
+		*layout, *zone = "", ""
+		return nil
+	}
+
+	switch {
+	case len(obj.Name) > 0 && len(obj.Layout) > 0:
+		return fmt.Errorf("UnmarshalConfig(): only one of time encoder name %q and layout %q may be set",
+			obj.Name, obj.Layout)
+	case len(obj.Name) > 0:
+		name, _ := json.Marshal(obj.Name)
+		if err := unmarshalEncoderName(timeEncoders, name, enc); err != nil {
+			return err
+		}
+	case len(obj.Layout) <= 0:
+		return fmt.Errorf("UnmarshalConfig(): time encoder requires either a name or a layout")
+	}
+
+	if len(obj.TimeZone) > 0 {
+		if _, err := time.LoadLocation(obj.TimeZone); err != nil {
+			return fmt.Errorf("UnmarshalConfig(): loading time zone %q failed - %w", obj.TimeZone, err)
+		}
+	}
+	*layout, *zone = obj.Layout, obj.TimeZone
+	return nil
+}
+
+// unmarshalEncoderName resolves the encoder named by the JSON string raw, if
+// any, in the given registry. Other JSON values, e.g. zap's layout objects for
+// time encoders, are left to zap's own unmarshaling.
+func unmarshalEncoderName[E any](encoders *registry[E], raw json.RawMessage, enc *E) error {
+	var name string
+	if len(raw) <= 0 || json.Unmarshal(raw, &name) != nil || len(name) <= 0 {
+		return nil
+	}
+	e, ok := encoders.lookup(name)
+	if !ok {
+		if suggestion := nameSuggestion(name, encoders.names()); len(suggestion) > 0 {
+			return fmt.Errorf("UnmarshalConfig(): unknown %s %q, did you mean %q?", encoders.kind, name, suggestion)
+		}
+		return fmt.Errorf("UnmarshalConfig(): unknown %s %q", encoders.kind, name)
+	}
+	*enc = e
+	return nil
+}
+
+// UnmarshalHCL processes a HCL configuration. Configuration errors are
+// reported as hcl.Diagnostics, which can be retrieved with errors.As and point
+// at the offending attribute. Marked values, e.g. the sensitive ones of
+// secret_file(), cf. lib.FileFunctions, are only accepted as redact.hash_key;
+// elsewhere they would be disclosed, e.g. by initial fields in every entry.
+func (c *Config) UnmarshalHCL(ctx *hcl.EvalContext, body hcl.Body) error {
 	var cfg configHCL
 
-	if diags := gohcl.DecodeBody(body, ctx, &cfg); diags.HasErrors() {
+	if diags := gohcl.DecodeBody(unmarkBody(body), ctx, &cfg); diags.HasErrors() {
 		return fmt.Errorf("UnmarshalHCL(): parsing log configuration failed - %w", diags)
 	}
 
-	if err := cfg.initZapConfig((*zap.Config)(cw)); err != nil {
-		return fmt.Errorf("UnmarshalHCL(): initializing configuration failed - %w", err)
+	if len(cfg.Outputs) > 0 {
+		return fmt.Errorf("UnmarshalHCL(): parsing log configuration failed - %w", hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Unsupported block type",
+			Detail:   "Output blocks are only supported by TeeConfig.",
+			Subject:  cfg.Outputs[0].Body.MissingItemRange().Ptr(),
+		}})
+	}
+
+	if diags := cfg.initConfig(c); diags.HasErrors() {
+		return fmt.Errorf("UnmarshalHCL(): initializing configuration failed - %w", diags)
+	}
+
+	return nil
+}
+
+// OverlayHCL works like UnmarshalHCL, but only the attributes and blocks
+// present in body override the settings of c, so configuration files can be
+// layered on top of a preset like StdConfig or of each other: initial fields
+// are merged, sink blocks are appended to the output paths, the attributes of
+// the sampling and encoder_config blocks as well as syslog options override
+// those of c one by one, and a redact block replaces the redaction of c. c is
+// left untouched, if an error is returned, and the configuration c has been
+// copied from is never modified.
+func (c *Config) OverlayHCL(ctx *hcl.EvalContext, body hcl.Body) error {
+	var cfg configHCL
+
+	if diags := gohcl.DecodeBody(unmarkBody(body), ctx, &cfg); diags.HasErrors() {
+		return fmt.Errorf("OverlayHCL(): parsing log configuration failed - %w", diags)
+	}
+
+	if len(cfg.Outputs) > 0 {
+		return fmt.Errorf("OverlayHCL(): parsing log configuration failed - %w", hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Unsupported block type",
+			Detail:   "Output blocks are only supported by TeeConfig.",
+			Subject:  cfg.Outputs[0].Body.MissingItemRange().Ptr(),
+		}})
 	}
 
+	overlaid := *c
+	if diags := cfg.overlayConfig(&overlaid); diags.HasErrors() {
+		return fmt.Errorf("OverlayHCL(): overriding configuration failed - %w", diags)
+	}
+	*c = overlaid
+
 	return nil
 }
 
-// EncoderConfigWrapper ...
+// EncoderConfigWrapper is a simple unmarshaling wrapper of zap's EncoderConfig
+// structure, cf. ConfigWrapper.
 type EncoderConfigWrapper zapcore.EncoderConfig
 
-// UnmarshalHCL ...
+// UnmarshalHCL processes a HCL encoder configuration. Like
+// ConfigWrapper.UnmarshalHCL, configuration errors are reported as
+// hcl.Diagnostics, e.g. for unknown encoder names.
 func (ecw *EncoderConfigWrapper) UnmarshalHCL(ctx *hcl.EvalContext, body hcl.Body) error {
 	var ec encoderConfigHCL
 
-	if diags := gohcl.DecodeBody(body, ctx, &ec); diags.HasErrors() {
+	if diags := gohcl.DecodeBody(unmarkBody(body), ctx, &ec); diags.HasErrors() {
 		return fmt.Errorf("UnmarshalHCL(): parsing log configuration failed - %w", diags)
 	}
 
-	ec.initZapEncoderConfig((*zapcore.EncoderConfig)(ecw))
+	zec := (*zapcore.EncoderConfig)(ecw)
+	if diags := ec.initZapEncoderConfig(zec); diags.HasErrors() {
+		return fmt.Errorf("UnmarshalHCL(): initializing encoder configuration failed - %w", diags)
+	}
+	layout, zone, diags := ec.timeFormat("", "")
+	if diags.HasErrors() {
+		return fmt.Errorf("UnmarshalHCL(): initializing encoder configuration failed - %w", diags)
+	}
+	// zapcore.EncoderConfig cannot hold the layout and zone
+	enc, err := timeEncoder(zec.EncodeTime, layout, zone)
+	if err != nil {
+		return fmt.Errorf("UnmarshalHCL(): %w", err)
+	}
+	zec.EncodeTime = enc
 
 	return nil
 }
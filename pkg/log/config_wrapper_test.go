@@ -2,17 +2,19 @@ package log_test
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"reflect"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclparse"
 	"github.com/zclconf/go-cty/cty"
-	"github.com/zclconf/go-cty/cty/function"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 
@@ -108,6 +110,18 @@ func TestUnmarshalMap(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "failure: unknown level encoder",
+			cfg:  zap.Config{},
+			args: args{
+				m: map[string]interface{}{
+					"encoderConfig": map[string]interface{}{
+						"levelEncoder": "captial",
+					},
+				},
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -152,12 +166,7 @@ func TestUnmarshalMap(t *testing.T) {
 }
 
 func TestEncoderConfigWrapperUnmarshalHCL(t *testing.T) {
-	ctx := &hcl.EvalContext{
-		Functions: map[string]function.Function{
-			"hostname": klib.Hostname,
-		},
-		Variables: map[string]cty.Value{},
-	}
+	ctx := klib.NewEvalContext(nil)
 	defaultEncoderConfig := zap.NewProductionEncoderConfig()
 	encoderConfig := zapcore.EncoderConfig{
 		EncodeCaller:   defaultEncoderConfig.EncodeCaller,
@@ -267,13 +276,32 @@ func TestEncoderConfigWrapperUnmarshalHCL(t *testing.T) {
 	}
 }
 
-func TestConfigWrapperUnmarshalHCL(t *testing.T) {
-	ctx := &hcl.EvalContext{
-		Functions: map[string]function.Function{
-			"hostname": klib.Hostname,
-		},
-		Variables: map[string]cty.Value{},
+func TestEncoderConfigWrapperTimeLayout(t *testing.T) {
+	hf, diags := hclparse.NewParser().ParseHCL([]byte(`
+		time_key    = "T"
+		time_layout = "2006-01-02 15:04 MST"
+		time_zone   = "UTC"`), "")
+	if diags.HasErrors() {
+		t.Fatalf("parsing config failed %v", diags)
+	}
+
+	var got zapcore.EncoderConfig
+	if err := (*log.EncoderConfigWrapper)(&got).UnmarshalHCL(nil, hf.Body); err != nil {
+		t.Fatalf("UnmarshalHCL() error = %v", err)
+	}
+	ent := zapcore.Entry{Time: time.Date(2001, 2, 3, 4, 5, 6, 0, time.FixedZone("UTC+1", 3600))}
+	buf, err := zapcore.NewConsoleEncoder(got).EncodeEntry(ent, nil)
+	if err != nil {
+		t.Fatalf("EncodeEntry() error = %v", err)
+	}
+	defer buf.Free()
+	if want := "2001-02-03 03:05 UTC\n"; buf.String() != want {
+		t.Errorf("EncodeEntry(): %q, want %q", buf.String(), want)
 	}
+}
+
+func TestConfigWrapperUnmarshalHCL(t *testing.T) {
+	ctx := klib.NewEvalContext(nil)
 	defaultEncoderConfig := zap.NewProductionEncoderConfig()
 	encoderConfig := zapcore.EncoderConfig{
 		EncodeCaller:   defaultEncoderConfig.EncodeCaller,
@@ -412,3 +440,894 @@ func TestConfigWrapperUnmarshalHCL(t *testing.T) {
 		})
 	}
 }
+
+func TestConfigUnmarshalHCLSampling(t *testing.T) {
+	var counter log.SamplingCounter
+	log.RestoreRegistries(t)
+	if err := log.RegisterSamplingHook("test_counter", counter.Hook); err != nil {
+		t.Fatal(err)
+	}
+	if err := log.RegisterSamplingHook("test_counter", counter.Hook); err == nil {
+		t.Errorf("RegisterSamplingHook(): duplicate registration succeeded")
+	}
+
+	tests := []struct {
+		name     string
+		conf     string
+		want     *zap.SamplingConfig
+		wantTick time.Duration
+		wantLine int
+		wantErr  bool
+	}{
+		{
+			name: "success: no sampling",
+			conf: `level = "info"`,
+		},
+		{
+			name: "success: sampling with tick",
+			conf: `sampling {
+				initial = 100
+				thereafter = 10
+				tick = "500ms"
+			}`,
+			want:     &zap.SamplingConfig{Initial: 100, Thereafter: 10},
+			wantTick: 500 * time.Millisecond,
+		},
+		{
+			name: "success: sampling with default tick",
+			conf: `sampling {
+				initial = 1
+			}`,
+			want: &zap.SamplingConfig{Initial: 1, Thereafter: 100},
+		},
+		{
+			name: "success: empty sampling block",
+			conf: `sampling {}`,
+			want: &zap.SamplingConfig{Initial: 100, Thereafter: 100},
+		},
+		{
+			name: "success: explicit thereafter 0",
+			conf: `sampling {
+				thereafter = 0
+			}`,
+			want: &zap.SamplingConfig{Initial: 100},
+		},
+		{
+			name: "failure: negative initial",
+			conf: `sampling {
+				initial = -1
+			}`,
+			wantLine: 2,
+			wantErr:  true,
+		},
+		{
+			name: "failure: negative thereafter",
+			conf: `sampling {
+				initial = 1
+				thereafter = -1
+			}`,
+			wantLine: 3,
+			wantErr:  true,
+		},
+		{
+			name: "failure: unparsable tick",
+			conf: `sampling {
+				tick = "1 second"
+			}`,
+			wantLine: 2,
+			wantErr:  true,
+		},
+		{
+			name: "failure: unknown hook",
+			conf: `sampling {
+				hook = "unknown"
+			}`,
+			wantLine: 2,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hf, diags := hclparse.NewParser().ParseHCL([]byte(tt.conf), "test.hcl")
+			if diags.HasErrors() {
+				t.Fatalf("parsing config failed %v", diags)
+			}
+
+			var got log.Config
+
+			if err := got.UnmarshalHCL(nil, hf.Body); err != nil {
+				if !tt.wantErr {
+					t.Errorf("UnmarshalHCL() error = %v, wantErr %v", err, false)
+					return
+				}
+				var diags hcl.Diagnostics
+				if !errors.As(err, &diags) || len(diags) <= 0 || diags[0].Subject == nil {
+					t.Errorf("UnmarshalHCL() error = %v, want diagnostics with subject", err)
+					return
+				}
+				if diags[0].Subject.Start.Line != tt.wantLine {
+					t.Errorf("UnmarshalHCL() diagnostic line = %d, want %d",
+						diags[0].Subject.Start.Line, tt.wantLine)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Errorf("UnmarshalHCL() error = %v, wantErr %v", nil, true)
+				return
+			}
+
+			if tt.want == nil {
+				if got.Sampling != nil {
+					t.Errorf("UnmarshalHCL() [Sampling]: %+v, want nil", got.Sampling)
+				}
+			} else if got.Sampling == nil ||
+				got.Sampling.Initial != tt.want.Initial ||
+				got.Sampling.Thereafter != tt.want.Thereafter {
+				t.Errorf("UnmarshalHCL() [Sampling]: %+v, want %+v", got.Sampling, tt.want)
+			}
+			if got.SamplingTick != tt.wantTick {
+				t.Errorf("UnmarshalHCL() [SamplingTick]: %v, want %v", got.SamplingTick, tt.wantTick)
+			}
+		})
+	}
+}
+
+func TestConfigWrapperUnmarshalHCLExtensions(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    string
+		wantErr bool
+	}{
+		{
+			name: "success: default sampling tick",
+			conf: `sampling {
+				tick = "1s"
+			}`,
+		},
+		{
+			// zap's Config.Build always samples per second
+			name: "failure: sampling tick",
+			conf: `sampling {
+				tick = "500ms"
+			}`,
+			wantErr: true,
+		},
+		{
+			name: "failure: redaction",
+			conf: `redact {
+				action = "hash"
+			}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hf, diags := hclparse.NewParser().ParseHCL([]byte(tt.conf), "test.hcl")
+			if diags.HasErrors() {
+				t.Fatalf("parsing config failed %v", diags)
+			}
+
+			var cfg zap.Config
+			if err := (*log.ConfigWrapper)(&cfg).UnmarshalHCL(nil, hf.Body); (err != nil) != tt.wantErr {
+				t.Errorf("UnmarshalHCL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigWrapperRequiresConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    string
+		m       map[string]interface{}
+		wantErr string
+	}{
+		{
+			name: "failure: sampling tick",
+			conf: `level = "info"
+sampling {
+  tick = "5s"
+}`,
+			wantErr: `UnmarshalHCL(): parsing log configuration failed - test.hcl:3,10-14: Invalid sampling configuration; The tick 5s requires Config, as zap.Config always samples per second.`,
+		},
+		{
+			name: "failure: syslog options",
+			conf: `encoding = "syslog"
+encoder_config {
+  syslog_facility = "local0"
+}`,
+			wantErr: `UnmarshalHCL(): syslog options require Config`,
+		},
+		{
+			name: "failure: redaction",
+			conf: `redact {
+  keys = ["password"]
+}`,
+			wantErr: `UnmarshalHCL(): redaction requires Config`,
+		},
+		{
+			name:    "failure: map syslog options",
+			m:       map[string]interface{}{"encoding": "syslog", "encoderConfig": map[string]interface{}{"syslogAppName": "klutz"}},
+			wantErr: `UnmarshalConfig(): syslog options require Config`,
+		},
+		{
+			name:    "failure: map redaction",
+			m:       map[string]interface{}{"redact": map[string]interface{}{"keys": []interface{}{"password"}}},
+			wantErr: `UnmarshalConfig(): redaction requires Config`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg zap.Config
+			var err error
+			if tt.m != nil {
+				err = (*log.ConfigWrapper)(&cfg).UnmarshalMap(tt.m)
+			} else {
+				hf, diags := hclparse.NewParser().ParseHCL([]byte(tt.conf), "test.hcl")
+				if diags.HasErrors() {
+					t.Fatalf("parsing config failed %v", diags)
+				}
+				err = (*log.ConfigWrapper)(&cfg).UnmarshalHCL(nil, hf.Body)
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Unmarshal() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSamplingCounter(t *testing.T) {
+	var counter log.SamplingCounter
+	log.RestoreRegistries(t)
+	if err := log.RegisterSamplingHook("test_sampling_counter", counter.Hook); err != nil {
+		t.Fatal(err)
+	}
+
+	f, closer := testTmpFile(t, "")
+	defer closer()
+
+	hf, diags := hclparse.NewParser().ParseHCL([]byte(fmt.Sprintf(`
+		output_paths = [ %q ]
+		sampling {
+			initial = 2
+			thereafter = 0
+			hook = "test_sampling_counter"
+		}`, f.Name())), "")
+	if diags.HasErrors() {
+		t.Fatalf("parsing config failed %v", diags)
+	}
+
+	var cfg zap.Config
+	if err := (*log.ConfigWrapper)(&cfg).UnmarshalHCL(nil, hf.Body); err != nil {
+		t.Fatal(err)
+	}
+	logger, err := cfg.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		logger.Info("repeated")
+	}
+
+	if got := counter.Sampled(); got != 2 {
+		t.Errorf("SamplingCounter.Sampled(): %d, want %d", got, 2)
+	}
+	if got := counter.Dropped(); got != 3 {
+		t.Errorf("SamplingCounter.Dropped(): %d, want %d", got, 3)
+	}
+}
+
+func TestUnmarshalHCLDiagnostics(t *testing.T) {
+	tests := []struct {
+		name       string
+		conf       string
+		encoder    bool
+		wantLine   int
+		wantColumn int
+		wantDetail string
+	}{
+		{
+			name: "failure: misspelled level encoder",
+			conf: `
+encoder_config {
+  level_encoder = "captial"
+}`,
+			wantLine:   3,
+			wantColumn: 19,
+			wantDetail: `Did you mean "capital"?`,
+		},
+		{
+			name: "failure: unknown time encoder",
+			conf: `
+encoder_config {
+  time_key = "T"
+  time_encoder = "unknown"
+}`,
+			wantLine:   4,
+			wantColumn: 18,
+			wantDetail: `valid names are`,
+		},
+		{
+			name:       "failure: misspelled duration encoder",
+			conf:       `duration_encoder = "strng"`,
+			encoder:    true,
+			wantLine:   1,
+			wantColumn: 20,
+			wantDetail: `Did you mean "string"?`,
+		},
+		{
+			name:       "failure: misspelled caller encoder",
+			conf:       `caller_encoder = "shrt"`,
+			encoder:    true,
+			wantLine:   1,
+			wantColumn: 18,
+			wantDetail: `Did you mean "short"?`,
+		},
+		{
+			name:       "failure: misspelled log level",
+			conf:       `level = "wran"`,
+			wantLine:   1,
+			wantColumn: 9,
+			wantDetail: `Did you mean "warn"?`,
+		},
+		{
+			name: "failure: unknown syslog facility",
+			conf: `
+encoding = "syslog"
+encoder_config {
+  syslog_facility = "local9"
+}`,
+			wantLine:   4,
+			wantColumn: 21,
+			wantDetail: `unknown syslog facility "local9"`,
+		},
+		{
+			name: "failure: syslog options without syslog encoding",
+			conf: `
+encoder_config {
+  syslog_app_name = "app"
+}`,
+			wantLine:   3,
+			wantColumn: 21,
+			wantDetail: `require the encoding "syslog"`,
+		},
+		{
+			name: "failure: misspelled redact preset",
+			conf: `
+redact {
+  keys    = ["password"]
+  presets = ["jwtt"]
+}`,
+			wantLine:   4,
+			wantColumn: 13,
+			wantDetail: `did you mean "jwt"?`,
+		},
+		{
+			name: "failure: redact hash without key",
+			conf: `
+redact {
+  keys   = ["password"]
+  action = "hash"
+}`,
+			wantLine:   4,
+			wantColumn: 12,
+			wantDetail: `requires a hash key`,
+		},
+		{
+			name:       "failure: initial fields not an object",
+			conf:       `initial_fields = ["service"]`,
+			wantLine:   1,
+			wantColumn: 18,
+			wantDetail: `must be an object, got tuple`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hf, diags := hclparse.NewParser().ParseHCL([]byte(tt.conf), "test.hcl")
+			if diags.HasErrors() {
+				t.Fatalf("parsing config failed %v", diags)
+			}
+
+			var err error
+			if tt.encoder {
+				var got zapcore.EncoderConfig
+				err = (*log.EncoderConfigWrapper)(&got).UnmarshalHCL(nil, hf.Body)
+			} else {
+				var got zap.Config
+				err = (*log.ConfigWrapper)(&got).UnmarshalHCL(nil, hf.Body)
+			}
+			if err == nil {
+				t.Fatalf("UnmarshalHCL() error = %v, wantErr %v", err, true)
+			}
+
+			if !errors.As(err, &diags) || len(diags) != 1 {
+				t.Fatalf("UnmarshalHCL() error = %v, want a single diagnostic", err)
+			}
+			diag := diags[0]
+			if diag.Subject == nil {
+				t.Fatalf("UnmarshalHCL() diagnostic %v without subject", diag)
+			}
+			if diag.Subject.Filename != "test.hcl" ||
+				diag.Subject.Start.Line != tt.wantLine ||
+				diag.Subject.Start.Column != tt.wantColumn {
+				t.Errorf("UnmarshalHCL() diagnostic subject = %s, want test.hcl:%d,%d",
+					diag.Subject, tt.wantLine, tt.wantColumn)
+			}
+			if !strings.Contains(diag.Detail, tt.wantDetail) {
+				t.Errorf("UnmarshalHCL() diagnostic detail = %q, want %q", diag.Detail, tt.wantDetail)
+			}
+		})
+	}
+}
+
+func TestConfigWrapperUnmarshalHCLInitialFields(t *testing.T) {
+	ctx := &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"unknown": cty.UnknownVal(cty.String),
+			"secret":  cty.StringVal("s3cr3t").Mark("sensitive"),
+		},
+	}
+
+	tests := []struct {
+		name    string
+		conf    string
+		want    map[string]interface{}
+		wantErr string
+	}{
+		{
+			name: "success: typed values",
+			conf: `initial_fields = {
+				s = "v"
+				n = 42
+				f = 1.5
+				big = 1e30
+				b = true
+				l = [1, "a", false]
+				o = { k = "v", n = { m = -1 } }
+				z = null
+			}`,
+			want: map[string]interface{}{
+				"s":   "v",
+				"n":   int64(42),
+				"f":   1.5,
+				"big": 1e30,
+				"b":   true,
+				"l":   []interface{}{int64(1), "a", false},
+				"o":   map[string]interface{}{"k": "v", "n": map[string]interface{}{"m": int64(-1)}},
+				"z":   nil,
+			},
+		},
+		{
+			name: "success: null",
+			conf: `initial_fields = null`,
+		},
+		{
+			name:    "failure: unknown value",
+			conf:    `initial_fields = { l = ["a", unknown] }`,
+			wantErr: "value of initial_fields.l[1] is unknown",
+		},
+		{
+			name:    "failure: sensitive value",
+			conf:    `initial_fields = { token = secret }`,
+			wantErr: "must not contain marked values",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hf, diags := hclparse.NewParser().ParseHCL([]byte(tt.conf), "test.hcl")
+			if diags.HasErrors() {
+				t.Fatalf("parsing config failed %v", diags)
+			}
+
+			var cfg zap.Config
+			err := (*log.ConfigWrapper)(&cfg).UnmarshalHCL(ctx, hf.Body)
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("UnmarshalHCL() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("UnmarshalHCL() error = %v", err)
+			}
+			if !reflect.DeepEqual(cfg.InitialFields, tt.want) {
+				t.Errorf("UnmarshalHCL() [InitialFields]: %#v, want %#v", cfg.InitialFields, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigUnmarshalHCLSecrets(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "key"), []byte("k3y"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ctx := klib.NewEvalContext(nil, klib.FileFunctions(dir))
+
+	tests := []struct {
+		name    string
+		hcl     string
+		wantErr bool
+	}{
+		{
+			name: "success: hash key",
+			hcl: `redact {
+				action   = "hash"
+				hash_key = secret_file("key")
+			}`,
+		},
+		{name: "failure: output paths", hcl: `output_paths = [secret_file("key")]`, wantErr: true},
+		{name: "failure: initial fields", hcl: `initial_fields = { key = secret_file("key") }`, wantErr: true},
+		{name: "failure: encoder configuration", hcl: `encoder_config { message_key = secret_file("key") }`, wantErr: true},
+		{name: "failure: redaction replacement", hcl: `redact { replacement = secret_file("key") }`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hf, diags := hclparse.NewParser().ParseHCL([]byte(tt.hcl), "test.hcl")
+			if diags.HasErrors() {
+				t.Fatalf("parsing config failed %v", diags)
+			}
+			var cfg log.Config
+			err := cfg.UnmarshalHCL(ctx, hf.Body)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UnmarshalHCL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				// the sensitive value is not disclosed
+				if strings.Contains(err.Error(), "k3y") {
+					t.Errorf("UnmarshalHCL() error = %v, discloses the sensitive value", err)
+				}
+				return
+			}
+			if cfg.Redact == nil || cfg.Redact.HashKey != "k3y" {
+				t.Errorf("UnmarshalHCL() [Redact]: %+v, want hash key", cfg.Redact)
+			}
+		})
+	}
+}
+
+func TestUnmarshalTimeLayout(t *testing.T) {
+	wantUTC := `\w{3} \d\d \d\d:\d\d:\d\d\.\d{3} UTC\s+warning`
+
+	tests := []struct {
+		name    string
+		hcl     string
+		m       map[string]interface{}
+		wantLog string
+		wantErr bool
+	}{
+		{
+			name: "success: HCL preset layout in UTC",
+			hcl: `encoder_config {
+				time_layout = "StampMilli"
+				time_zone = "UTC"
+			}`,
+			wantLog: `\w{3} [ \d]\d \d\d:\d\d:\d\d\.\d{3}\s+warning`,
+		},
+		{
+			name: "success: HCL custom layout",
+			hcl: `encoder_config {
+				time_layout = "2006/01/02 MST"
+				time_zone = "UTC"
+			}`,
+			wantLog: `\d{4}/\d\d/\d\d UTC\s+warning`,
+		},
+		{
+			name: "success: HCL named encoder in UTC",
+			hcl: `encoder_config {
+				time_encoder = "iso8601"
+				time_zone = "UTC"
+			}`,
+			wantLog: `\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{3}Z\s+warning`,
+		},
+		{
+			name: "failure: HCL layout and encoder",
+			hcl: `encoder_config {
+				time_encoder = "iso8601"
+				time_layout = "Kitchen"
+			}`,
+			wantErr: true,
+		},
+		{
+			name: "failure: HCL unknown time zone",
+			hcl: `encoder_config {
+				time_zone = "Mars/Olympus_Mons"
+			}`,
+			wantErr: true,
+		},
+		{
+			name: "success: JSON preset layout in UTC",
+			m: map[string]interface{}{
+				"timeEncoder": map[string]interface{}{
+					"layout":   "StampMilli",
+					"timeZone": "UTC",
+				},
+			},
+			wantLog: `\w{3} [ \d]\d \d\d:\d\d:\d\d\.\d{3}\s+warning`,
+		},
+		{
+			name: "success: JSON custom layout",
+			m: map[string]interface{}{
+				"timeEncoder": map[string]interface{}{
+					"layout":   "Jan _2 15:04:05.000 MST",
+					"timeZone": "UTC",
+				},
+			},
+			wantLog: wantUTC,
+		},
+		{
+			name: "success: JSON named encoder in UTC",
+			m: map[string]interface{}{
+				"timeEncoder": map[string]interface{}{
+					"name":     "iso8601",
+					"timeZone": "UTC",
+				},
+			},
+			wantLog: `\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{3}Z\s+warning`,
+		},
+		{
+			name: "failure: JSON unknown time zone",
+			m: map[string]interface{}{
+				"timeEncoder": map[string]interface{}{
+					"layout":   "Kitchen",
+					"timeZone": "Mars/Olympus_Mons",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "failure: JSON without layout",
+			m: map[string]interface{}{
+				"timeEncoder": map[string]interface{}{
+					"timeZone": "UTC",
+				},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, closer := testTmpFile(t, "")
+			defer closer()
+
+			var cfg zap.Config
+			var err error
+			if tt.m != nil {
+				tt.m["timeKey"] = "T"
+				tt.m["messageKey"] = "M"
+				err = (*log.ConfigWrapper)(&cfg).UnmarshalMap(map[string]interface{}{
+					"encoding":      "console",
+					"level":         "info",
+					"outputPaths":   []string{f.Name()},
+					"encoderConfig": tt.m,
+				})
+			} else {
+				// the encoding carries the time layout and zone, cf. ConfigWrapper
+				hf, diags := hclparse.NewParser().ParseHCL([]byte(fmt.Sprintf(`encoding = "console"
+				output_paths = [%q]
+				%s`, f.Name(), tt.hcl)), "")
+				if diags.HasErrors() {
+					t.Fatalf("parsing config failed %v", diags)
+				}
+				if err = (*log.ConfigWrapper)(&cfg).UnmarshalHCL(nil, hf.Body); err == nil {
+					cfg.EncoderConfig.TimeKey = "T"
+					cfg.EncoderConfig.MessageKey = "M"
+				}
+			}
+			if err != nil {
+				if !tt.wantErr {
+					t.Errorf("Unmarshal() error = %v, wantErr %v", err, false)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Fatalf("Unmarshal() error = %v, wantErr %v", err, true)
+			}
+			testEncoderOutput(t, cfg, f, tt.wantLog)
+		})
+	}
+}
+
+func TestConfigOverlayHCL(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    []string
+		want    func() log.Config
+		wantErr bool
+	}{
+		{
+			name: "success: empty config",
+			conf: []string{``},
+			want: func() log.Config { return log.Config{Config: log.StdConfig()} },
+		},
+		{
+			name: "success: present attributes override",
+			conf: []string{`
+				level = "debug"
+				disable_caller = false
+				output_paths = ["stdout"]
+				initial_fields = { service = "api" }
+				encoder_config {
+					message_key = "message"
+					time_encoder = "rfc3339"
+				}`,
+			},
+			want: func() log.Config {
+				cfg := log.Config{Config: log.StdConfig()}
+				cfg.Level = zap.NewAtomicLevelAt(zapcore.DebugLevel)
+				cfg.DisableCaller = false
+				cfg.OutputPaths = []string{"stdout"}
+				cfg.InitialFields = map[string]interface{}{"service": "api"}
+				cfg.EncoderConfig.MessageKey = "message"
+				cfg.EncoderConfig.EncodeTime = zapcore.RFC3339TimeEncoder
+				return cfg
+			},
+		},
+		{
+			name: "success: layers",
+			conf: []string{`
+				encoding = "syslog"
+				initial_fields = { service = "api", env = "dev" }
+				sampling {
+					initial = 10
+					thereafter = 100
+				}
+				encoder_config {
+					syslog_facility = "local0"
+				}
+				redact {
+					keys = ["password"]
+				}`, `
+				level = "warn"
+				initial_fields = { env = "prod" }
+				sink {
+					path = "/var/log/app.log"
+				}
+				sampling {
+					thereafter = 1000
+				}
+				encoder_config {
+					syslog_app_name = "api"
+				}`,
+			},
+			want: func() log.Config {
+				cfg := log.Config{Config: log.StdConfig()}
+				cfg.Level = zap.NewAtomicLevelAt(zapcore.WarnLevel)
+				cfg.InitialFields = map[string]interface{}{"service": "api", "env": "prod"}
+				cfg.OutputPaths = append(cfg.OutputPaths, log.RotateURL("/var/log/app.log", log.RotateOptions{}))
+				cfg.Sampling.Initial = 10
+				cfg.Sampling.Thereafter = 1000
+				cfg.Encoding = "syslog"
+				cfg.Syslog = log.SyslogOptions{Facility: "local0", AppName: "api"}
+				cfg.Redact = &log.RedactConfig{Keys: []string{"password"}}
+				return cfg
+			},
+		},
+		{
+			name: "success: other encoding drops syslog options",
+			conf: []string{`
+				encoding = "syslog"
+				encoder_config {
+					syslog_facility = "local0"
+				}`, `
+				encoding = "json"`,
+			},
+			want: func() log.Config {
+				cfg := log.Config{Config: log.StdConfig()}
+				cfg.Encoding = "json"
+				return cfg
+			},
+		},
+		{
+			name:    "failure: invalid level",
+			conf:    []string{`level = "loud"`},
+			wantErr: true,
+		},
+		{
+			name:    "failure: syslog options without syslog encoding",
+			conf:    []string{`encoder_config { syslog_facility = "local0" }`},
+			wantErr: true,
+		},
+		{
+			name:    "failure: output block",
+			conf:    []string{`output "stderr" { path = "stderr" }`},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := log.Config{Config: log.StdConfig()}
+			before, _ := json.Marshal(&base)
+
+			cfg := base
+			var err error
+			for i, conf := range tt.conf {
+				hf, diags := hclparse.NewParser().ParseHCL([]byte(conf), fmt.Sprintf("layer%d.hcl", i))
+				if diags.HasErrors() {
+					t.Fatalf("ParseHCL() error = %v", diags)
+				}
+				if err = cfg.OverlayHCL(nil, hf.Body); err != nil {
+					break
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("OverlayHCL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			// the base configuration is never modified
+			if after, _ := json.Marshal(&base); string(after) != string(before) {
+				t.Errorf("OverlayHCL() modified the base configuration: %s, want %s", after, before)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			want := tt.want()
+			wantJSON, err := json.Marshal(&want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, err := json.Marshal(&cfg); err != nil || string(got) != string(wantJSON) {
+				t.Errorf("OverlayHCL() = %s, %v, want %s", got, err, wantJSON)
+			}
+		})
+	}
+}
+
+func TestConfigOverlayMap(t *testing.T) {
+	base := log.Config{Config: log.StdConfig()}
+	base.InitialFields = map[string]interface{}{"service": "api"}
+	before, _ := json.Marshal(&base)
+
+	cfg := base
+	for _, m := range []map[string]interface{}{
+		{
+			"level":            "debug",
+			"encoding":         "syslog",
+			"initialFields":    map[string]interface{}{"env": "dev"},
+			"sampling":         map[string]interface{}{"initial": 10, "thereafter": 100},
+			"encoderConfig":    map[string]interface{}{"messageKey": "message", "syslogFacility": "local0"},
+			"redact":           map[string]interface{}{"keys": []string{"password"}},
+			"errorOutputPaths": []string{"stdout"},
+		},
+		{
+			"sampling":      map[string]interface{}{"thereafter": 1000},
+			"encoderConfig": map[string]interface{}{"syslogAppName": "api"},
+		},
+	} {
+		if err := cfg.OverlayMap(m); err != nil {
+			t.Fatalf("OverlayMap() error = %v", err)
+		}
+	}
+
+	if after, _ := json.Marshal(&base); string(after) != string(before) {
+		t.Errorf("OverlayMap() modified the base configuration: %s, want %s", after, before)
+	}
+
+	want := log.Config{Config: log.StdConfig()}
+	want.Level = zap.NewAtomicLevelAt(zapcore.DebugLevel)
+	want.InitialFields = map[string]interface{}{"service": "api", "env": "dev"}
+	want.Sampling.Initial = 10
+	want.Sampling.Thereafter = 1000
+	want.ErrorOutputPaths = []string{"stdout"}
+	want.EncoderConfig.MessageKey = "message"
+	want.Encoding = "syslog"
+	want.Syslog = log.SyslogOptions{Facility: "local0", AppName: "api"}
+	want.Redact = &log.RedactConfig{Keys: []string{"password"}}
+
+	wantJSON, _ := json.Marshal(&want)
+	if got, err := json.Marshal(&cfg); err != nil || string(got) != string(wantJSON) {
+		t.Errorf("OverlayMap() = %s, %v, want %s", got, err, wantJSON)
+	}
+
+	if err := cfg.OverlayMap(map[string]interface{}{"level": "loud"}); err == nil {
+		t.Errorf("OverlayMap() error = nil, want error")
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package log
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/agext/levenshtein"
+	"go.uber.org/zap/zapcore"
+)
+
+// registry is a concurrency-safe map of named values, e.g. encoders.
+type registry[V any] struct {
+	mu     sync.RWMutex
+	kind   string
+	values map[string]V
+}
+
+func newRegistry[V any](kind string, values map[string]V) *registry[V] {
+	return &registry[V]{kind: kind, values: values}
+}
+
+func (r *registry[V]) register(name string, v V, isNil bool) error {
+	if len(name) <= 0 {
+		return fmt.Errorf("registering %s: name must not be empty", r.kind)
+	}
+	if isNil {
+		return fmt.Errorf("registering %s %q: value must not be nil", r.kind, name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.values[name]; ok {
+		return fmt.Errorf("registering %s %q: name already registered", r.kind, name)
+	}
+	r.values[name] = v
+	return nil
+}
+
+// snapshot returns a copy of the registered values, cf. restore.
+func (r *registry[V]) snapshot() map[string]V {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	values := make(map[string]V, len(r.values))
+	for name, v := range r.values {
+		values[name] = v
+	}
+	return values
+}
+
+// restore replaces the registered values by a snapshot, e.g. in tests.
+func (r *registry[V]) restore(values map[string]V) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.values = values
+}
+
+func (r *registry[V]) lookup(name string) (V, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	v, ok := r.values[name]
+	return v, ok
+}
+
+// names returns all registered names in ascending order.
+func (r *registry[V]) names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.values))
+	for name := range r.values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// nameOf returns the name of v, which must be a function, or false, if it has
+// not been registered. If v has been registered under several names, the
+// first one in ascending order is returned. Functions are compared by their
+// code, which all instances of a closure share, hence closures, including
+// method values, are never found.
+func (r *registry[V]) nameOf(v V) (string, bool) {
+	ptr := reflect.ValueOf(v).Pointer()
+	if isClosure(ptr) {
+		return "", false
+	}
+	for _, name := range r.names() {
+		if w, ok := r.lookup(name); ok && reflect.ValueOf(w).Pointer() == ptr {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// closureName matches the names of function literals, e.g. "pkg.f.func1" or
+// "pkg.f.func1.2", and of method values, e.g. "pkg.(*T).M-fm".
+var closureName = regexp.MustCompile(`\.func\d+(\.\d+)*$|-fm$`)
+
+// isClosure reports, whether the code at ptr belongs to a function literal or
+// a method value.
+func isClosure(ptr uintptr) bool {
+	fn := runtime.FuncForPC(ptr)
+	return fn != nil && closureName.MatchString(fn.Name())
+}
+
+// known encoder names, cf. the UnmarshalText methods of zapcore's encoders,
+// which silently fall back to a default for any unknown name
+var (
+	levelEncoders = newRegistry("level encoder", map[string]zapcore.LevelEncoder{
+		"capital":      zapcore.CapitalLevelEncoder,
+		"capitalColor": zapcore.CapitalColorLevelEncoder,
+		"color":        zapcore.LowercaseColorLevelEncoder,
+		"lowercase":    zapcore.LowercaseLevelEncoder,
+	})
+	timeEncoders = newRegistry("time encoder", map[string]zapcore.TimeEncoder{
+		"rfc3339nano": zapcore.RFC3339NanoTimeEncoder,
+		"RFC3339Nano": zapcore.RFC3339NanoTimeEncoder,
+		"rfc3339":     zapcore.RFC3339TimeEncoder,
+		"RFC3339":     zapcore.RFC3339TimeEncoder,
+		"iso8601":     zapcore.ISO8601TimeEncoder,
+		"ISO8601":     zapcore.ISO8601TimeEncoder,
+		"millis":      zapcore.EpochMillisTimeEncoder,
+		"nanos":       zapcore.EpochNanosTimeEncoder,
+		"epoch":       zapcore.EpochTimeEncoder,
+
+		ConfigKeyTimeEncoderInt64Seconds: EpochInt64SecondsEncoder,
+		ConfigKeyTimeEncoderShort:        EpochShortTimeEncoder,
+	})
+	durationEncoders = newRegistry("duration encoder", map[string]zapcore.DurationEncoder{
+		"string":  zapcore.StringDurationEncoder,
+		"nanos":   zapcore.NanosDurationEncoder,
+		"ms":      zapcore.MillisDurationEncoder,
+		"seconds": zapcore.SecondsDurationEncoder,
+	})
+	callerEncoders = newRegistry("caller encoder", map[string]zapcore.CallerEncoder{
+		"full":  zapcore.FullCallerEncoder,
+		"short": zapcore.ShortCallerEncoder,
+	})
+	nameEncoders = newRegistry("name encoder", map[string]zapcore.NameEncoder{
+		"full": zapcore.FullNameEncoder,
+	})
+)
+
+// RegisterLevelEncoder makes enc available under name, e.g. for the
+// level_encoder attribute of a HCL encoder_config block or the levelEncoder
+// key of a JSON encoderConfig object. Names must be unique, built-in names
+// cannot be overridden.
+func RegisterLevelEncoder(name string, enc zapcore.LevelEncoder) error {
+	return levelEncoders.register(name, enc, enc == nil)
+}
+
+// RegisterTimeEncoder makes enc available under name, cf. RegisterLevelEncoder.
+func RegisterTimeEncoder(name string, enc zapcore.TimeEncoder) error {
+	return timeEncoders.register(name, enc, enc == nil)
+}
+
+// RegisterDurationEncoder makes enc available under name, cf.
+// RegisterLevelEncoder.
+func RegisterDurationEncoder(name string, enc zapcore.DurationEncoder) error {
+	return durationEncoders.register(name, enc, enc == nil)
+}
+
+// RegisterCallerEncoder makes enc available under name, cf.
+// RegisterLevelEncoder.
+func RegisterCallerEncoder(name string, enc zapcore.CallerEncoder) error {
+	return callerEncoders.register(name, enc, enc == nil)
+}
+
+// RegisterNameEncoder makes enc available under name, cf. RegisterLevelEncoder.
+func RegisterNameEncoder(name string, enc zapcore.NameEncoder) error {
+	return nameEncoders.register(name, enc, enc == nil)
+}
+
+// nameSuggestion tries to find a name from names that is close to given and
+// returns it if found, cf. hclsyntax's "did you mean" suggestions.
+func nameSuggestion(given string, names []string) string {
+	for _, name := range names {
+		if levenshtein.Distance(given, name, nil) < 3 {
+			return name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package log_test
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/sobchak-security/klutz/pkg/log"
+)
+
+func testHouseTimeEncoder(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+	enc.AppendString("house:" + t.UTC().Format("2006"))
+}
+
+func testHouseLevelEncoder(l zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	enc.AppendString("<" + l.String() + ">")
+}
+
+func TestRegisterEncoders(t *testing.T) {
+	log.RestoreRegistries(t)
+	if err := log.RegisterTimeEncoder("test_house", testHouseTimeEncoder); err != nil {
+		t.Fatal(err)
+	}
+	if err := log.RegisterLevelEncoder("test_house", testHouseLevelEncoder); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"failure: duplicate name", log.RegisterTimeEncoder("test_house", testHouseTimeEncoder)},
+		{"failure: built-in name", log.RegisterLevelEncoder("capital", testHouseLevelEncoder)},
+		{"failure: empty name", log.RegisterDurationEncoder("", zapcore.StringDurationEncoder)},
+		{"failure: nil caller encoder", log.RegisterCallerEncoder("test_nil", nil)},
+		{"failure: nil name encoder", log.RegisterNameEncoder("test_nil", nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.err == nil {
+				t.Errorf("Register*Encoder() error = %v, wantErr %v", tt.err, true)
+			}
+		})
+	}
+
+	wantLog := `house:\d{4}\t<warn>\twarning`
+
+	t.Run("success: HCL configuration", func(t *testing.T) {
+		f, closer := testTmpFile(t, "")
+		defer closer()
+
+		hf, diags := hclparse.NewParser().ParseHCL([]byte(fmt.Sprintf(`
+			encoding = "console"
+			output_paths = [ %q ]
+			encoder_config {
+				message_key = "M"
+				level_key = "L"
+				time_key = "T"
+				level_encoder = "test_house"
+				time_encoder = "test_house"
+			}`, f.Name())), "")
+		if diags.HasErrors() {
+			t.Fatalf("parsing config failed %v", diags)
+		}
+
+		var cfg zap.Config
+		if err := (*log.ConfigWrapper)(&cfg).UnmarshalHCL(nil, hf.Body); err != nil {
+			t.Fatal(err)
+		}
+		testEncoderOutput(t, cfg, f, wantLog)
+	})
+
+	t.Run("success: map configuration", func(t *testing.T) {
+		f, closer := testTmpFile(t, "")
+		defer closer()
+
+		var cfg zap.Config
+		if err := (*log.ConfigWrapper)(&cfg).UnmarshalMap(map[string]interface{}{
+			"encoding":    "console",
+			"level":       "info",
+			"outputPaths": []string{f.Name()},
+			"encoderConfig": map[string]interface{}{
+				"messageKey":   "M",
+				"levelKey":     "L",
+				"timeKey":      "T",
+				"levelEncoder": "test_house",
+				"timeEncoder":  "test_house",
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		testEncoderOutput(t, cfg, f, wantLog)
+	})
+
+	t.Run("failure: unknown name in map configuration", func(t *testing.T) {
+		var cfg zap.Config
+		err := (*log.ConfigWrapper)(&cfg).UnmarshalMap(map[string]interface{}{
+			"encoderConfig": map[string]interface{}{
+				"timeEncoder": "test_huose",
+			},
+		})
+		if err == nil || !strings.Contains(err.Error(), `did you mean "test_house"`) {
+			t.Errorf("UnmarshalMap() error = %v, want suggestion", err)
+		}
+	})
+}
+
+func TestRegisterEncodersConcurrently(t *testing.T) {
+	log.RestoreRegistries(t)
+	var wg sync.WaitGroup
+
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			name := fmt.Sprintf("test_concurrent_%d", i)
+			if err := log.RegisterDurationEncoder(name, zapcore.StringDurationEncoder); err != nil {
+				t.Error(err)
+			}
+
+			var cfg zap.Config
+			if err := (*log.ConfigWrapper)(&cfg).UnmarshalMap(map[string]interface{}{
+				"encoderConfig": map[string]interface{}{
+					"durationEncoder": name,
+				},
+			}); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func testEncoderOutput(t *testing.T, cfg zap.Config, f *os.File, wantLog string) {
+	t.Helper()
+
+	logger, err := cfg.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Warn("warning")
+
+	b, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testMatchLog(t, string(b), wantLog)
+}
@@ -0,0 +1,174 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package log
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// LookupEnv retrieves the value of the environment variable key like
+// os.LookupEnv. As the variable HOSTNAME is not available on every platform,
+// it falls back to the system's hostname or the variable HOST for it.
+func LookupEnv(key string) (string, bool) {
+	if value, ok := os.LookupEnv(key); ok || key != "HOSTNAME" {
+		return value, ok
+	}
+	if hostname, err := os.Hostname(); err == nil && len(hostname) > 0 {
+		return hostname, true
+	}
+	return os.LookupEnv("HOST")
+}
+
+// ExpandEnv replaces references to variables in s, which are resolved by
+// lookup. Supported are the forms $VAR and ${VAR}, which fail if the variable
+// is unset, ${VAR:-default}, which expands to default if the variable is unset
+// or empty, and ${VAR:?message}, which fails with message if the variable is
+// unset or empty; variables, which may be unset, are referenced as ${VAR:-}.
+// "$$" expands to a literal "$", as does any "$" not followed by a variable
+// reference.
+func ExpandEnv(s string, lookup func(string) (string, bool)) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 >= len(s) {
+			sb.WriteByte(s[i])
+			continue
+		}
+
+		switch next := s[i+1]; {
+		case next == '$':
+			sb.WriteByte('$')
+			i++
+		case next == '{':
+			end := strings.IndexByte(s[i+2:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("ExpandEnv(): missing closing brace in %q", s)
+			}
+			value, err := expandEnvExpr(s[i+2:i+2+end], lookup)
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(value)
+			i += end + 2
+		case isEnvNameByte(next, true):
+			end := i + 2
+			for end < len(s) && isEnvNameByte(s[end], false) {
+				end++
+			}
+			value, ok := lookup(s[i+1 : end])
+			if !ok {
+				return "", unsetEnvError(s[i+1 : end])
+			}
+			sb.WriteString(value)
+			i = end - 1
+		default:
+			sb.WriteByte('$')
+		}
+	}
+	return sb.String(), nil
+}
+
+// expandEnvExpr expands the content of a ${...} reference.
+func expandEnvExpr(expr string, lookup func(string) (string, bool)) (string, error) {
+	name, op, arg := expr, "", ""
+	if idx := strings.IndexByte(expr, ':'); idx >= 0 && idx+1 < len(expr) {
+		name, op, arg = expr[:idx], expr[idx:idx+2], expr[idx+2:]
+	}
+	if !isEnvName(name) {
+		return "", fmt.Errorf("ExpandEnv(): invalid variable reference ${%s}", expr)
+	}
+
+	value, ok := lookup(name)
+
+	switch op {
+	case "":
+		if !ok {
+			return "", unsetEnvError(name)
+		}
+		return value, nil
+	case ":-":
+		if !ok || len(value) <= 0 {
+			return arg, nil
+		}
+		return value, nil
+	case ":?":
+		if !ok || len(value) <= 0 {
+			if len(arg) <= 0 {
+				arg = "not set"
+			}
+			return "", fmt.Errorf("ExpandEnv(): variable %s: %s", name, arg)
+		}
+		return value, nil
+	default:
+		return "", fmt.Errorf("ExpandEnv(): unsupported operator %q in ${%s}", op, expr)
+	}
+}
+
+// unsetEnvError reports a reference to the unset variable name, which is
+// neither optional nor required.
+func unsetEnvError(name string) error {
+	return fmt.Errorf("ExpandEnv(): variable %s is not set, use ${%s:-} if it is optional", name, name)
+}
+
+func isEnvName(s string) bool {
+	if len(s) <= 0 || !isEnvNameByte(s[0], true) {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if !isEnvNameByte(s[i], false) {
+			return false
+		}
+	}
+	return true
+}
+
+func isEnvNameByte(c byte, first bool) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || !first && '0' <= c && c <= '9'
+}
+
+// expandEnvValue applies ExpandEnv to all strings nested in v, leaving map keys
+// untouched.
+func expandEnvValue(v interface{}, lookup func(string) (string, bool)) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		return ExpandEnv(v, lookup)
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			x, err := expandEnvValue(e, lookup)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = x
+		}
+		return m, nil
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, e := range v {
+			x, err := expandEnvValue(e, lookup)
+			if err != nil {
+				return nil, err
+			}
+			s[i] = x
+		}
+		return s, nil
+	case []string:
+		s := make([]interface{}, len(v))
+		for i, e := range v {
+			x, err := ExpandEnv(e, lookup)
+			if err != nil {
+				return nil, err
+			}
+			s[i] = x
+		}
+		return s, nil
+	default:
+		return v, nil
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package log_test
+
+import (
+	"os"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/sobchak-security/klutz/pkg/log"
+)
+
+func testLookup(vars map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := vars[key]
+		return v, ok
+	}
+}
+
+func TestExpandEnv(t *testing.T) {
+	lookup := testLookup(map[string]string{
+		"NAME":  "klutz",
+		"EMPTY": "",
+		"QUOTE": `say "hi"`,
+	})
+
+	tests := []struct {
+		name    string
+		s       string
+		want    string
+		wantErr bool
+	}{
+		{name: "success: no variables", s: "plain", want: "plain"},
+		{name: "success: simple form", s: "$NAME-log", want: "klutz-log"},
+		{name: "success: braced form", s: "${NAME}_log", want: "klutz_log"},
+		{name: "success: empty variable", s: "[${EMPTY}]", want: "[]"},
+		{name: "success: default for unset", s: "${UNSET:-fallback}", want: "fallback"},
+		{name: "success: default for empty", s: "${EMPTY:-fallback}", want: "fallback"},
+		{name: "success: default unused", s: "${NAME:-fallback}", want: "klutz"},
+		{name: "success: empty default", s: "${UNSET:-}", want: ""},
+		{name: "success: required set", s: "${NAME:?must be set}", want: "klutz"},
+		{name: "success: quotes retained", s: "${QUOTE}", want: `say "hi"`},
+		{name: "success: escaped dollar", s: "$$NAME", want: "$NAME"},
+		{name: "success: lone dollar", s: "costs 5$ or $ 6", want: "costs 5$ or $ 6"},
+		{name: "failure: unset variable", s: "[${UNSET}]", wantErr: true},
+		{name: "failure: unset variable simple form", s: "$UNSET-log", wantErr: true},
+		{name: "failure: required unset", s: "${UNSET:?must be set}", wantErr: true},
+		{name: "failure: required empty", s: "${EMPTY:?}", wantErr: true},
+		{name: "failure: missing brace", s: "${NAME", wantErr: true},
+		{name: "failure: invalid name", s: "${1NAME}", wantErr: true},
+		{name: "failure: unsupported operator", s: "${NAME:+alt}", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := log.ExpandEnv(tt.s, lookup)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExpandEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ExpandEnv(): %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalMapLookup(t *testing.T) {
+	lookup := testLookup(map[string]string{
+		"LEVEL": "warn",
+		"QUOTE": `"quoted"`,
+	})
+
+	m := map[string]interface{}{
+		"level":       "${LEVEL}",
+		"outputPaths": []string{"${OUTPUT:-stderr}"},
+		"encoderConfig": map[string]interface{}{
+			"messageKey": "$$msg",
+		},
+		"initialFields": map[string]interface{}{
+			"${KEY}": "${QUOTE}",
+			"price":  "5$",
+		},
+	}
+
+	var cfg log.Config
+	if err := cfg.UnmarshalMapLookup(m, lookup); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := cfg.Level.String(); got != "warn" {
+		t.Errorf("UnmarshalMapLookup() [Level]: %q, want %q", got, "warn")
+	}
+	if len(cfg.OutputPaths) != 1 || cfg.OutputPaths[0] != "stderr" {
+		t.Errorf("UnmarshalMapLookup() [OutputPaths]: %q, want %q", cfg.OutputPaths, []string{"stderr"})
+	}
+	if got := cfg.EncoderConfig.MessageKey; got != "$msg" {
+		t.Errorf("UnmarshalMapLookup() [MessageKey]: %q, want %q", got, "$msg")
+	}
+	if got := cfg.InitialFields["${KEY}"]; got != `"quoted"` {
+		t.Errorf("UnmarshalMapLookup() [InitialFields]: %q, want %q", got, `"quoted"`)
+	}
+	if got := cfg.InitialFields["price"]; got != "5$" {
+		t.Errorf("UnmarshalMapLookup() [InitialFields]: %q, want %q", got, "5$")
+	}
+
+	for _, m := range []map[string]interface{}{
+		{"level": "${REQUIRED_LEVEL:?log level required}"},
+		{"encoderConfig": map[string]interface{}{"messageKey": "$msg"}},
+	} {
+		if err := cfg.UnmarshalMapLookup(m, lookup); err == nil {
+			t.Errorf("UnmarshalMapLookup(%v) error = %v, wantErr %v", m, err, true)
+		}
+	}
+}
+
+func TestUnmarshalMapHostname(t *testing.T) {
+	t.Setenv("HOSTNAME", "")
+	if err := os.Unsetenv("HOSTNAME"); err != nil {
+		t.Fatal(err)
+	}
+
+	var cfg zap.Config
+	if err := (*log.ConfigWrapper)(&cfg).UnmarshalMap(map[string]interface{}{
+		"initialFields": map[string]interface{}{
+			"host": "${HOSTNAME}",
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if hostname, err := os.Hostname(); err == nil && cfg.InitialFields["host"] != hostname {
+		t.Errorf("UnmarshalMap() [InitialFields]: %q, want %q", cfg.InitialFields["host"], hostname)
+	}
+	if _, ok := os.LookupEnv("HOSTNAME"); ok {
+		t.Errorf("UnmarshalMap(): HOSTNAME set as a side effect")
+	}
+}
@@ -0,0 +1,23 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package log
+
+import "testing"
+
+// RestoreRegistries takes a snapshot of the registered encoders and sampling
+// hooks and restores it when t and its subtests complete, so that tests can
+// register the same names again, e.g. with go test -count=2. Encodings are
+// shared with zap and hence not restored.
+func RestoreRegistries(t testing.TB) {
+	levels, times, durations := levelEncoders.snapshot(), timeEncoders.snapshot(), durationEncoders.snapshot()
+	callers, names, hooks := callerEncoders.snapshot(), nameEncoders.snapshot(), samplingHooks.snapshot()
+	t.Cleanup(func() {
+		levelEncoders.restore(levels)
+		timeEncoders.restore(times)
+		durationEncoders.restore(durations)
+		callerEncoders.restore(callers)
+		nameEncoders.restore(names)
+		samplingHooks.restore(hooks)
+	})
+}
@@ -0,0 +1,161 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"gopkg.in/yaml.v3"
+
+	klib "github.com/sobchak-security/klutz/pkg/cty/function/lib"
+)
+
+// LoadFile reads a zap logger configuration from the file path. The format is
+// determined by the file extension: ".json", ".yaml", ".yml" and ".toml" files
+// are decoded into a map and processed by UnmarshalMap, ".hcl" files are
+// processed by UnmarshalHCL with an evaluation context providing the functions
+// of lib.Functions, e.g. hostname() or upper(), and of lib.FileFunctions,
+// restricted to the directory of path, e.g. secret_file("hash.key") for the
+// hash key of the redaction.
+func (c *Config) LoadFile(path string) error {
+	return loadFile("LoadFile()", path, c.UnmarshalMap, c.UnmarshalHCL)
+}
+
+// LoadFile reads a zap logger configuration from the file path, cf.
+// Config.LoadFile.
+func (cw *ConfigWrapper) LoadFile(path string) error {
+	cfg := cw.config()
+	if err := cfg.LoadFile(path); err != nil {
+		return err
+	}
+	return cw.set("LoadFile()", cfg, nil)
+}
+
+// OverlayFile overrides the settings of cw by the configuration in the file
+// path, cf. Config.OverlayFile.
+func (cw *ConfigWrapper) OverlayFile(path string) error {
+	cfg := cw.config()
+	if err := cfg.OverlayFile(path); err != nil {
+		return err
+	}
+	return cw.set("OverlayFile()", cfg, nil)
+}
+
+// OverlayFiles overlays the configurations in the files paths in the given
+// order, cf. Config.OverlayFiles.
+func (cw *ConfigWrapper) OverlayFiles(paths ...string) error {
+	cfg := cw.config()
+	if err := cfg.OverlayFiles(paths...); err != nil {
+		return err
+	}
+	return cw.set("OverlayFiles()", cfg, nil)
+}
+
+// OverlayFile works like LoadFile, but the configuration in the file path
+// only overrides the settings of c it contains, cf. OverlayMap and
+// OverlayHCL.
+func (c *Config) OverlayFile(path string) error {
+	return loadFile("OverlayFile()", path, c.OverlayMap, c.OverlayHCL)
+}
+
+// OverlayFiles overlays the configurations in the files paths in the given
+// order, cf. OverlayFile, e.g. a base, an environment specific and a local
+// configuration on top of StdConfig. Formats may be mixed. c is left
+// untouched, if an error is returned.
+func (c *Config) OverlayFiles(paths ...string) error {
+	cfg := *c
+	for _, path := range paths {
+		if err := cfg.OverlayFile(path); err != nil {
+			return err
+		}
+	}
+	*c = cfg
+	return nil
+}
+
+// loadFile reads the configuration in the file path and processes it by
+// unmarshalMap or unmarshalHCL depending on its format, cf. LoadFile; errors
+// are prefixed by name.
+func loadFile(
+	name, path string,
+	unmarshalMap func(map[string]interface{}) error,
+	unmarshalHCL func(*hcl.EvalContext, hcl.Body) error,
+) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("%s: reading %q failed - %w", name, path, err)
+	}
+
+	var m map[string]interface{}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		err = json.Unmarshal(b, &m)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &m)
+	case ".toml":
+		err = toml.Unmarshal(b, &m)
+	case ".hcl":
+		hf, diags := hclparse.NewParser().ParseHCL(b, path)
+		if diags.HasErrors() {
+			return fmt.Errorf("%s: parsing %q failed - %w", name, path, diags)
+		}
+		return unmarshalHCL(defaultEvalContext(filepath.Dir(path)), hf.Body)
+	default:
+		return fmt.Errorf("%s: unsupported file extension %q of %q", name, ext, path)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: decoding %q failed - %w", name, path, err)
+	}
+
+	return unmarshalMap(m)
+}
+
+// defaultEvalContext returns the evaluation context for HCL files in the
+// directory dir loaded by this package, cf. lib.NewEvalContext; files can
+// only be read in dir, cf. lib.FileFunctions.
+func defaultEvalContext(dir string) *hcl.EvalContext {
+	return klib.NewEvalContext(nil, klib.FileFunctions(dir))
+}
+
+// normalizeValue converts all maps nested in v to map[string]interface{}, as
+// e.g. YAML decoders produce map[interface{}]interface{}, which cannot be
+// marshaled to JSON.
+func normalizeValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = normalizeValue(e)
+		}
+		return m
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = normalizeValue(e)
+		}
+		return m
+	case []map[string]interface{}:
+		s := make([]interface{}, len(v))
+		for i, e := range v {
+			s[i] = normalizeValue(e)
+		}
+		return s
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, e := range v {
+			s[i] = normalizeValue(e)
+		}
+		return s
+	default:
+		return v
+	}
+}
@@ -0,0 +1,446 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package log_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/sobchak-security/klutz/pkg/log"
+)
+
+func TestLoadFile(t *testing.T) {
+	t.Setenv("TEST_LOAD_FILE_VERSION", "1.1")
+
+	tests := []struct {
+		name    string
+		file    string
+		conf    string
+		wantErr bool
+	}{
+		{
+			name: "success: JSON",
+			file: "log.json",
+			conf: `{
+				"level": "warn",
+				"encoding": "console",
+				"outputPaths": ["stderr"],
+				"encoderConfig": {
+					"messageKey": "M",
+					"levelEncoder": "capital",
+					"timeEncoder": "short"
+				},
+				"initialFields": {
+					"version": "${TEST_LOAD_FILE_VERSION}"
+				}
+			}`,
+		},
+		{
+			name: "success: YAML",
+			file: "log.yaml",
+			conf: `
+level: warn
+encoding: console
+outputPaths:
+  - stderr
+encoderConfig:
+  messageKey: M
+  levelEncoder: capital
+  timeEncoder: short
+initialFields:
+  version: ${TEST_LOAD_FILE_VERSION}
+`,
+		},
+		{
+			name: "success: YAML with non-string keys",
+			file: "log.yml",
+			conf: `
+level: warn
+encoding: console
+outputPaths: [stderr]
+encoderConfig:
+  messageKey: M
+  levelEncoder: capital
+  timeEncoder: short
+initialFields:
+  version: ${TEST_LOAD_FILE_VERSION}
+  1: one
+`,
+		},
+		{
+			name: "success: TOML",
+			file: "log.toml",
+			conf: `
+level = "warn"
+encoding = "console"
+outputPaths = ["stderr"]
+
+[encoderConfig]
+messageKey = "M"
+levelEncoder = "capital"
+timeEncoder = "short"
+
+[initialFields]
+version = "${TEST_LOAD_FILE_VERSION}"
+`,
+		},
+		{
+			name: "success: HCL",
+			file: "log.hcl",
+			conf: `
+level = "warn"
+encoding = "console"
+output_paths = ["stderr"]
+encoder_config {
+	message_key = "M"
+	level_encoder = "capital"
+	time_encoder = "short"
+}
+initial_fields = {
+	version = "1.1"
+	host = hostname()
+}
+`,
+		},
+		{
+			name:    "failure: unsupported extension",
+			file:    "log.ini",
+			conf:    `level = warn`,
+			wantErr: true,
+		},
+		{
+			name:    "failure: invalid YAML",
+			file:    "log.yaml",
+			conf:    "level: [warn",
+			wantErr: true,
+		},
+		{
+			name:    "failure: invalid HCL",
+			file:    "log.hcl",
+			conf:    `level = `,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.conf), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			var cfg log.Config
+			if err := cfg.LoadFile(path); err != nil {
+				if !tt.wantErr {
+					t.Errorf("LoadFile() error = %v, wantErr %v", err, false)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Fatalf("LoadFile() error = %v, wantErr %v", nil, true)
+			}
+
+			if got := cfg.Level.Level(); got != zapcore.WarnLevel {
+				t.Errorf("LoadFile() [Level]: %v, want %v", got, zapcore.WarnLevel)
+			}
+			if cfg.Encoding != "console" {
+				t.Errorf("LoadFile() [Encoding]: %q, want %q", cfg.Encoding, "console")
+			}
+			if strings.Join(cfg.OutputPaths, ",") != "stderr" {
+				t.Errorf("LoadFile() [OutputPaths]: %q, want %q", cfg.OutputPaths, []string{"stderr"})
+			}
+			if cfg.EncoderConfig.MessageKey != "M" {
+				t.Errorf("LoadFile() [MessageKey]: %q, want %q", cfg.EncoderConfig.MessageKey, "M")
+			}
+			testCompareEncoderConfig(t, zapcore.EncoderConfig{
+				MessageKey:     "M",
+				EncodeLevel:    cfg.EncoderConfig.EncodeLevel,
+				EncodeTime:     log.EpochShortTimeEncoder,
+				EncodeDuration: cfg.EncoderConfig.EncodeDuration,
+				EncodeCaller:   cfg.EncoderConfig.EncodeCaller,
+				EncodeName:     cfg.EncoderConfig.EncodeName,
+			}, cfg.EncoderConfig)
+			if got := cfg.InitialFields["version"]; got != "1.1" {
+				t.Errorf("LoadFile() [InitialFields]: %v, want %q", got, "1.1")
+			}
+
+			_, cleanup, err := cfg.Build()
+			if err != nil {
+				t.Fatalf("LoadFile(): resulting config unable to build logger - %v", err)
+			}
+			_ = cleanup()
+		})
+	}
+}
+
+func TestConfigWrapperLoadFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		conf    string
+		wantErr bool
+	}{
+		{
+			name: "success: JSON",
+			file: "log.json",
+			conf: `{"level": "warn", "encoding": "console", "encoderConfig": {"timeEncoder": "short"}}`,
+		},
+		{
+			name: "success: HCL",
+			file: "log.hcl",
+			conf: `
+level = "warn"
+encoding = "console"
+encoder_config {
+	time_encoder = "short"
+}
+`,
+		},
+		{
+			name: "failure: sampling tick",
+			file: "log.hcl",
+			conf: `
+sampling {
+	tick = "500ms"
+}
+`,
+			wantErr: true,
+		},
+		{
+			name:    "failure: missing file",
+			file:    "",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "missing.json")
+			if len(tt.file) > 0 {
+				path = filepath.Join(t.TempDir(), tt.file)
+				if err := os.WriteFile(path, []byte(tt.conf), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			zc := log.StdConfig()
+			if err := (*log.ConfigWrapper)(&zc).LoadFile(path); err != nil {
+				if !tt.wantErr {
+					t.Errorf("LoadFile() error = %v, wantErr %v", err, false)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Fatalf("LoadFile() error = %v, wantErr %v", nil, true)
+			}
+
+			if got := zc.Level.Level(); got != zapcore.WarnLevel {
+				t.Errorf("LoadFile() [Level]: %v, want %v", got, zapcore.WarnLevel)
+			}
+			if zc.Encoding != "console" {
+				t.Errorf("LoadFile() [Encoding]: %q, want %q", zc.Encoding, "console")
+			}
+			if got, want := reflect.ValueOf(zc.EncoderConfig.EncodeTime).Pointer(), reflect.ValueOf(log.EpochShortTimeEncoder).Pointer(); got != want {
+				t.Errorf("LoadFile() [EncodeTime]: %#x, want %#x", got, want)
+			}
+
+			if _, err := zc.Build(); err != nil {
+				t.Errorf("LoadFile(): resulting config unable to build logger - %v", err)
+			}
+		})
+	}
+}
+
+func TestOverlayFiles(t *testing.T) {
+	dir := t.TempDir()
+	for name, conf := range map[string]string{
+		"base.hcl": `
+			level = "info"
+			encoding = "json"
+			initial_fields = { service = "api" }
+			encoder_config {
+				message_key = "message"
+			}`,
+		"prod.yaml": `
+level: warn
+initialFields:
+  env: prod
+`,
+		"local.json": `{"outputPaths": ["stdout"]}`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(conf), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	paths := func(names ...string) []string {
+		for i, name := range names {
+			names[i] = filepath.Join(dir, name)
+		}
+		return names
+	}
+
+	cfg := log.Config{Config: log.StdConfig()}
+	if err := cfg.OverlayFiles(paths("base.hcl", "prod.yaml", "local.json")...); err != nil {
+		t.Fatalf("OverlayFiles() error = %v", err)
+	}
+
+	if got := cfg.Level.Level(); got != zapcore.WarnLevel {
+		t.Errorf("OverlayFiles() [Level]: %v, want %v", got, zapcore.WarnLevel)
+	}
+	if cfg.Encoding != "json" {
+		t.Errorf("OverlayFiles() [Encoding]: %q, want %q", cfg.Encoding, "json")
+	}
+	if strings.Join(cfg.OutputPaths, ",") != "stdout" || strings.Join(cfg.ErrorOutputPaths, ",") != "stderr" {
+		t.Errorf("OverlayFiles() [OutputPaths]: %q and %q, want %q and %q",
+			cfg.OutputPaths, cfg.ErrorOutputPaths, []string{"stdout"}, []string{"stderr"})
+	}
+	if cfg.EncoderConfig.MessageKey != "message" || cfg.EncoderConfig.TimeKey != "ts" {
+		t.Errorf("OverlayFiles() [EncoderConfig]: keys %q and %q, want %q and %q",
+			cfg.EncoderConfig.MessageKey, cfg.EncoderConfig.TimeKey, "message", "ts")
+	}
+	if got, want := cfg.InitialFields, map[string]interface{}{"service": "api", "env": "prod"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("OverlayFiles() [InitialFields]: %v, want %v", got, want)
+	}
+	if !cfg.DisableCaller {
+		t.Errorf("OverlayFiles() [DisableCaller]: %t, want %t", cfg.DisableCaller, true)
+	}
+
+	// a failing layer leaves the configuration untouched
+	before := cfg.Level.Level()
+	if err := cfg.OverlayFiles(paths("base.hcl", "missing.hcl")...); err == nil {
+		t.Errorf("OverlayFiles() error = nil, want error")
+	}
+	if got := cfg.Level.Level(); got != before {
+		t.Errorf("OverlayFiles() [Level] after failure: %v, want %v", got, before)
+	}
+}
+
+func TestConfigWrapperOverlayFiles(t *testing.T) {
+	dir := t.TempDir()
+	for name, conf := range map[string]string{
+		"base.hcl": `
+			encoding = "console"
+			encoder_config {
+				message_key = "message"
+			}`,
+		"prod.yaml": `
+level: warn
+encoderConfig:
+  levelKey: severity
+`,
+		"tick.hcl": `
+			sampling {
+				tick = "10s"
+			}`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(conf), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	base := log.StdConfig()
+	zc := base
+	cw := (*log.ConfigWrapper)(&zc)
+	if err := cw.OverlayFiles(filepath.Join(dir, "base.hcl"), filepath.Join(dir, "prod.yaml")); err != nil {
+		t.Fatalf("OverlayFiles() error = %v", err)
+	}
+	if err := cw.OverlayMap(map[string]interface{}{"outputPaths": []interface{}{"stdout"}}); err != nil {
+		t.Fatalf("OverlayMap() error = %v", err)
+	}
+
+	if got := zc.Level.Level(); got != zapcore.WarnLevel {
+		t.Errorf("OverlayFiles() [Level]: %v, want %v", got, zapcore.WarnLevel)
+	}
+	if got := base.Level.Level(); got != zapcore.InfoLevel {
+		t.Errorf("OverlayFiles() [Level] of base: %v, want %v", got, zapcore.InfoLevel)
+	}
+	if zc.EncoderConfig.TimeKey != "ts" || strings.Join(zc.OutputPaths, ",") != "stdout" {
+		t.Errorf("OverlayMap(): time key %q and output paths %q, want %q and %q",
+			zc.EncoderConfig.TimeKey, zc.OutputPaths, "ts", []string{"stdout"})
+	}
+	if zc.Encoding != "console" || zc.EncoderConfig.MessageKey != "message" || zc.EncoderConfig.LevelKey != "severity" {
+		t.Errorf("OverlayFiles(): encoding %q with message key %q and level key %q, want the settings of both layers",
+			zc.Encoding, zc.EncoderConfig.MessageKey, zc.EncoderConfig.LevelKey)
+	}
+
+	// a failing layer leaves the configuration untouched
+	before := zc.Encoding
+	if err := cw.OverlayFile(filepath.Join(dir, "tick.hcl")); err == nil {
+		t.Errorf("OverlayFile() error = nil, want error")
+	}
+	if err := cw.OverlayHCL(nil, hcl.EmptyBody()); err != nil {
+		t.Errorf("OverlayHCL() error = %v", err)
+	}
+	if zc.Encoding != before {
+		t.Errorf("OverlayFile() after failure: encoding %q, want %q", zc.Encoding, before)
+	}
+}
+
+func TestLoadFileSecretFile(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "app.log")
+	for name, conf := range map[string]string{
+		"hash.key": "k3y\n",
+		"log.hcl": `
+			level        = "info"
+			encoding     = "json"
+			output_paths = ["` + out + `"]
+			encoder_config {
+				message_key = "msg"
+			}
+			redact {
+				keys     = ["password"]
+				action   = "hash"
+				hash_key = secret_file("hash.key")
+			}`,
+		"conf/outside.hcl": `
+			redact {
+				action   = "hash"
+				hash_key = secret_file("../hash.key")
+			}`,
+	} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(conf), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var cfg log.Config
+	if err := cfg.LoadFile(filepath.Join(dir, "log.hcl")); err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+	if cfg.Redact == nil || cfg.Redact.HashKey != "k3y" {
+		t.Errorf("LoadFile() [Redact]: %+v, want hash key read from hash.key", cfg.Redact)
+	}
+
+	// files are read relative to the configuration file, but not outside of
+	// its directory
+	if err := cfg.LoadFile(filepath.Join(dir, "conf", "outside.hcl")); err == nil {
+		t.Errorf("LoadFile() error = nil, want error")
+	}
+
+	l, cleanup, err := cfg.Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	defer func() { _ = cleanup() }()
+	l.Info("login", zap.String("password", "s3cr3t"))
+	_ = l.Sync()
+	testMatchLog(t, testReadLog(t, out), `^\{"msg":"login","password":"hmac:[0-9a-f]{16}"\}\n$`)
+
+	// zap.Config cannot hold the redaction
+	var zc zap.Config
+	if err := (*log.ConfigWrapper)(&zc).LoadFile(filepath.Join(dir, "log.hcl")); err == nil {
+		t.Errorf("LoadFile() error = nil, want error")
+	}
+}
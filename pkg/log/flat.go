@@ -0,0 +1,233 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package log
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// bufferPool provides the buffers of the encoders of this package.
+var bufferPool = buffer.NewPool()
+
+// flatField is a field with its textual value; the keys of nested objects are
+// joined by the separator of the flatEncoder.
+type flatField struct {
+	key   string
+	value string
+}
+
+// flatEncoder is a zapcore.ObjectEncoder collecting fields as flat list of
+// key-value pairs for line-based formats, e.g. syslog or logfmt. Times and
+// durations are formatted by the encoders of ec, if set. Arrays and reflected
+// values are serialized as JSON.
+type flatEncoder struct {
+	ec     *zapcore.EncoderConfig
+	sep    string
+	prefix string
+	fields []flatField
+}
+
+func newFlatEncoder(ec *zapcore.EncoderConfig, sep string) *flatEncoder {
+	return &flatEncoder{ec: ec, sep: sep}
+}
+
+func (e *flatEncoder) clone() *flatEncoder {
+	return &flatEncoder{
+		ec:     e.ec,
+		sep:    e.sep,
+		prefix: e.prefix,
+		fields: append(make([]flatField, 0, len(e.fields)+8), e.fields...),
+	}
+}
+
+func (e *flatEncoder) add(key, value string) {
+	e.fields = append(e.fields, flatField{key: e.prefix + key, value: value})
+}
+
+func (e *flatEncoder) addJSON(key string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	e.add(key, string(b))
+	return nil
+}
+
+// AddArray implements the zapcore.ObjectEncoder interface.
+func (e *flatEncoder) AddArray(key string, arr zapcore.ArrayMarshaler) error {
+	m := zapcore.NewMapObjectEncoder()
+	if err := m.AddArray(key, arr); err != nil {
+		return err
+	}
+	return e.addJSON(key, m.Fields[key])
+}
+
+// AddObject implements the zapcore.ObjectEncoder interface.
+func (e *flatEncoder) AddObject(key string, obj zapcore.ObjectMarshaler) error {
+	prefix := e.prefix
+	e.prefix += key + e.sep
+	defer func() { e.prefix = prefix }()
+
+	return obj.MarshalLogObject(e)
+}
+
+// AddReflected implements the zapcore.ObjectEncoder interface.
+func (e *flatEncoder) AddReflected(key string, obj interface{}) error {
+	if s, ok := obj.(string); ok {
+		e.add(key, s)
+		return nil
+	}
+	return e.addJSON(key, obj)
+}
+
+// OpenNamespace implements the zapcore.ObjectEncoder interface. Subsequent
+// fields are prefixed with the namespace.
+func (e *flatEncoder) OpenNamespace(key string) {
+	e.prefix += key + e.sep
+}
+
+// AddBinary implements the zapcore.ObjectEncoder interface.
+func (e *flatEncoder) AddBinary(key string, v []byte) {
+	e.add(key, base64.StdEncoding.EncodeToString(v))
+}
+
+// AddByteString implements the zapcore.ObjectEncoder interface.
+func (e *flatEncoder) AddByteString(key string, v []byte) { e.add(key, string(v)) }
+
+// AddBool implements the zapcore.ObjectEncoder interface.
+func (e *flatEncoder) AddBool(key string, v bool) { e.add(key, strconv.FormatBool(v)) }
+
+// AddComplex128 implements the zapcore.ObjectEncoder interface.
+func (e *flatEncoder) AddComplex128(key string, v complex128) {
+	e.add(key, strconv.FormatComplex(v, 'g', -1, 128))
+}
+
+// AddComplex64 implements the zapcore.ObjectEncoder interface.
+func (e *flatEncoder) AddComplex64(key string, v complex64) {
+	e.add(key, strconv.FormatComplex(complex128(v), 'g', -1, 64))
+}
+
+// AddDuration implements the zapcore.ObjectEncoder interface.
+func (e *flatEncoder) AddDuration(key string, v time.Duration) {
+	if e.ec != nil && e.ec.EncodeDuration != nil {
+		if s, ok := encodeText(func(enc zapcore.PrimitiveArrayEncoder) { e.ec.EncodeDuration(v, enc) }); ok {
+			e.add(key, s)
+			return
+		}
+	}
+	e.add(key, v.String())
+}
+
+// AddFloat64 implements the zapcore.ObjectEncoder interface.
+func (e *flatEncoder) AddFloat64(key string, v float64) {
+	e.add(key, strconv.FormatFloat(v, 'f', -1, 64))
+}
+
+// AddFloat32 implements the zapcore.ObjectEncoder interface.
+func (e *flatEncoder) AddFloat32(key string, v float32) {
+	e.add(key, strconv.FormatFloat(float64(v), 'f', -1, 32))
+}
+
+// AddInt implements the zapcore.ObjectEncoder interface.
+func (e *flatEncoder) AddInt(key string, v int) { e.AddInt64(key, int64(v)) }
+
+// AddInt64 implements the zapcore.ObjectEncoder interface.
+func (e *flatEncoder) AddInt64(key string, v int64) { e.add(key, strconv.FormatInt(v, 10)) }
+
+// AddInt32 implements the zapcore.ObjectEncoder interface.
+func (e *flatEncoder) AddInt32(key string, v int32) { e.AddInt64(key, int64(v)) }
+
+// AddInt16 implements the zapcore.ObjectEncoder interface.
+func (e *flatEncoder) AddInt16(key string, v int16) { e.AddInt64(key, int64(v)) }
+
+// AddInt8 implements the zapcore.ObjectEncoder interface.
+func (e *flatEncoder) AddInt8(key string, v int8) { e.AddInt64(key, int64(v)) }
+
+// AddString implements the zapcore.ObjectEncoder interface.
+func (e *flatEncoder) AddString(key, v string) { e.add(key, v) }
+
+// AddTime implements the zapcore.ObjectEncoder interface.
+func (e *flatEncoder) AddTime(key string, v time.Time) {
+	if e.ec != nil && e.ec.EncodeTime != nil {
+		if s, ok := encodeText(func(enc zapcore.PrimitiveArrayEncoder) { e.ec.EncodeTime(v, enc) }); ok {
+			e.add(key, s)
+			return
+		}
+	}
+	e.add(key, v.Format(time.RFC3339Nano))
+}
+
+// AddUint implements the zapcore.ObjectEncoder interface.
+func (e *flatEncoder) AddUint(key string, v uint) { e.AddUint64(key, uint64(v)) }
+
+// AddUint64 implements the zapcore.ObjectEncoder interface.
+func (e *flatEncoder) AddUint64(key string, v uint64) { e.add(key, strconv.FormatUint(v, 10)) }
+
+// AddUint32 implements the zapcore.ObjectEncoder interface.
+func (e *flatEncoder) AddUint32(key string, v uint32) { e.AddUint64(key, uint64(v)) }
+
+// AddUint16 implements the zapcore.ObjectEncoder interface.
+func (e *flatEncoder) AddUint16(key string, v uint16) { e.AddUint64(key, uint64(v)) }
+
+// AddUint8 implements the zapcore.ObjectEncoder interface.
+func (e *flatEncoder) AddUint8(key string, v uint8) { e.AddUint64(key, uint64(v)) }
+
+// AddUintptr implements the zapcore.ObjectEncoder interface.
+func (e *flatEncoder) AddUintptr(key string, v uintptr) { e.AddUint64(key, uint64(v)) }
+
+// encodeText returns the text appended by encode, e.g. a zapcore.TimeEncoder,
+// and whether anything has been appended at all.
+func encodeText(encode func(zapcore.PrimitiveArrayEncoder)) (string, bool) {
+	var enc textArrayEncoder
+	encode(&enc)
+	if len(enc.elems) <= 0 {
+		return "", false
+	}
+	s := enc.elems[0]
+	for _, elem := range enc.elems[1:] {
+		s += " " + elem
+	}
+	return s, true
+}
+
+// textArrayEncoder is a zapcore.PrimitiveArrayEncoder collecting the textual
+// representation of the appended values.
+type textArrayEncoder struct {
+	elems []string
+}
+
+func (enc *textArrayEncoder) append(s string) { enc.elems = append(enc.elems, s) }
+
+func (enc *textArrayEncoder) AppendBool(v bool)         { enc.append(strconv.FormatBool(v)) }
+func (enc *textArrayEncoder) AppendByteString(v []byte) { enc.append(string(v)) }
+func (enc *textArrayEncoder) AppendComplex128(v complex128) {
+	enc.append(strconv.FormatComplex(v, 'g', -1, 128))
+}
+func (enc *textArrayEncoder) AppendComplex64(v complex64) {
+	enc.append(strconv.FormatComplex(complex128(v), 'g', -1, 64))
+}
+func (enc *textArrayEncoder) AppendFloat64(v float64) {
+	enc.append(strconv.FormatFloat(v, 'f', -1, 64))
+}
+func (enc *textArrayEncoder) AppendFloat32(v float32) {
+	enc.append(strconv.FormatFloat(float64(v), 'f', -1, 32))
+}
+func (enc *textArrayEncoder) AppendInt(v int)         { enc.append(strconv.Itoa(v)) }
+func (enc *textArrayEncoder) AppendInt64(v int64)     { enc.append(strconv.FormatInt(v, 10)) }
+func (enc *textArrayEncoder) AppendInt32(v int32)     { enc.AppendInt64(int64(v)) }
+func (enc *textArrayEncoder) AppendInt16(v int16)     { enc.AppendInt64(int64(v)) }
+func (enc *textArrayEncoder) AppendInt8(v int8)       { enc.AppendInt64(int64(v)) }
+func (enc *textArrayEncoder) AppendString(v string)   { enc.append(v) }
+func (enc *textArrayEncoder) AppendUint(v uint)       { enc.AppendUint64(uint64(v)) }
+func (enc *textArrayEncoder) AppendUint64(v uint64)   { enc.append(strconv.FormatUint(v, 10)) }
+func (enc *textArrayEncoder) AppendUint32(v uint32)   { enc.AppendUint64(uint64(v)) }
+func (enc *textArrayEncoder) AppendUint16(v uint16)   { enc.AppendUint64(uint64(v)) }
+func (enc *textArrayEncoder) AppendUint8(v uint8)     { enc.AppendUint64(uint64(v)) }
+func (enc *textArrayEncoder) AppendUintptr(v uintptr) { enc.AppendUint64(uint64(v)) }
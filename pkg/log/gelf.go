@@ -0,0 +1,379 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package log
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// GELF related encoding and sink schemes, cf.
+// https://go2docs.graylog.org/current/getting_in_log_data/gelf.html
+const (
+	GELFEncoding     = "gelf"
+	GELFUDPScheme    = "gelf+udp"
+	GELFTCPScheme    = "gelf+tcp"
+	gelfVersion      = "1.1"
+	gelfChunkSizeMax = 8192
+	gelfChunksMax    = 128
+)
+
+func init() {
+	if err := RegisterEncoding(GELFEncoding, func(ec zapcore.EncoderConfig) (zapcore.Encoder, error) {
+		return NewGELFEncoder(ec), nil
+	}); err != nil {
+		panic(fmt.Sprintf("registering encoding %q failed - %v", GELFEncoding, err))
+	}
+	for _, scheme := range []string{GELFUDPScheme, GELFTCPScheme} {
+		if err := zap.RegisterSink(scheme, newGELFSink); err != nil {
+			panic(fmt.Sprintf("registering sink %q failed - %v", scheme, err))
+		}
+	}
+}
+
+// syslogSeverity maps a zap level to a syslog severity, cf. RFC 5424.
+func syslogSeverity(lvl zapcore.Level) int {
+	switch lvl {
+	case zapcore.DebugLevel:
+		return 7 // debug
+	case zapcore.InfoLevel:
+		return 6 // informational
+	case zapcore.WarnLevel:
+		return 4 // warning
+	case zapcore.ErrorLevel:
+		return 3 // error
+	case zapcore.DPanicLevel, zapcore.PanicLevel:
+		return 2 // critical
+	case zapcore.FatalLevel:
+		return 1 // alert
+	default:
+		if lvl < zapcore.DebugLevel {
+			return 7
+		}
+		return 0 // emergency
+	}
+}
+
+// gelfLevelEncoder serializes a zap level to its syslog severity.
+func gelfLevelEncoder(lvl zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	enc.AppendInt(syslogSeverity(lvl))
+}
+
+// gelfEncoder is a zapcore.Encoder producing GELF 1.1 payloads. Additional
+// fields are prefixed with an underscore and nested objects are flattened,
+// e.g. the field "b" of the object "a" is added as "_a_b". As GELF supports
+// only strings and numbers, other values are serialized as strings.
+type gelfEncoder struct {
+	zapcore.Encoder
+	host   string
+	prefix string
+}
+
+// NewGELFEncoder creates a GELF encoder. The keys and the level and time
+// encoders of ec are replaced as required by GELF; caller, function and stack
+// trace are omitted, if their keys are empty, cf. zapcore.EncoderConfig. The
+// host defaults to the system's hostname and can be set by a field named
+// "host".
+func NewGELFEncoder(ec zapcore.EncoderConfig) zapcore.Encoder {
+	ec.MessageKey = "short_message"
+	ec.LevelKey = "level"
+	ec.TimeKey = "timestamp"
+	ec.NameKey = "_logger"
+	if len(ec.CallerKey) > 0 {
+		ec.CallerKey = "_caller"
+	}
+	if len(ec.FunctionKey) > 0 {
+		ec.FunctionKey = "_function"
+	}
+	if len(ec.StacktraceKey) > 0 {
+		ec.StacktraceKey = "full_message"
+	}
+	ec.EncodeLevel = gelfLevelEncoder
+	ec.EncodeTime = zapcore.EpochTimeEncoder
+	if ec.EncodeCaller == nil {
+		ec.EncodeCaller = zapcore.ShortCallerEncoder
+	}
+
+	host, _ := LookupEnv("HOSTNAME")
+	return &gelfEncoder{
+		Encoder: zapcore.NewJSONEncoder(ec),
+		host:    host,
+	}
+}
+
+// key returns the GELF name of the additional field key.
+func (e *gelfEncoder) key(key string) string {
+	var sb strings.Builder
+	sb.WriteByte('_')
+	for _, r := range e.prefix + key {
+		switch {
+		case r == '_' || r == '.' || r == '-' ||
+			'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9':
+			sb.WriteRune(r)
+		default:
+			sb.WriteByte('_')
+		}
+	}
+	if k := sb.String(); k != "_id" {
+		return k
+	}
+	// the additional field _id is reserved
+	return "__id"
+}
+
+// reserved handles the GELF fields host and version, which are set by the
+// encoder rather than added as additional fields.
+func (e *gelfEncoder) reserved(key, value string) bool {
+	if len(e.prefix) > 0 {
+		return false
+	}
+	switch key {
+	case "host":
+		e.host = value
+		return true
+	case "version":
+		return true
+	}
+	return false
+}
+
+// addJSONString adds v serialized as JSON string.
+func (e *gelfEncoder) addJSONString(key string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	e.Encoder.AddString(e.key(key), string(b))
+	return nil
+}
+
+// AddArray implements the zapcore.ObjectEncoder interface.
+func (e *gelfEncoder) AddArray(key string, arr zapcore.ArrayMarshaler) error {
+	m := zapcore.NewMapObjectEncoder()
+	if err := m.AddArray(key, arr); err != nil {
+		return err
+	}
+	return e.addJSONString(key, m.Fields[key])
+}
+
+// AddObject implements the zapcore.ObjectEncoder interface.
+func (e *gelfEncoder) AddObject(key string, obj zapcore.ObjectMarshaler) error {
+	nested := *e
+	nested.prefix = e.prefix + key + "_"
+	return obj.MarshalLogObject(&nested)
+}
+
+// AddReflected implements the zapcore.ObjectEncoder interface.
+func (e *gelfEncoder) AddReflected(key string, obj interface{}) error {
+	if s, ok := obj.(string); ok {
+		e.AddString(key, s)
+		return nil
+	}
+	return e.addJSONString(key, obj)
+}
+
+// OpenNamespace implements the zapcore.ObjectEncoder interface. Subsequent
+// fields are prefixed with the namespace.
+func (e *gelfEncoder) OpenNamespace(key string) {
+	e.prefix += key + "_"
+}
+
+// AddBinary implements the zapcore.ObjectEncoder interface.
+func (e *gelfEncoder) AddBinary(key string, v []byte) { e.Encoder.AddBinary(e.key(key), v) }
+
+// AddByteString implements the zapcore.ObjectEncoder interface.
+func (e *gelfEncoder) AddByteString(key string, v []byte) { e.AddString(key, string(v)) }
+
+// AddBool implements the zapcore.ObjectEncoder interface.
+func (e *gelfEncoder) AddBool(key string, v bool) {
+	e.Encoder.AddString(e.key(key), strconv.FormatBool(v))
+}
+
+// AddComplex128 implements the zapcore.ObjectEncoder interface.
+func (e *gelfEncoder) AddComplex128(key string, v complex128) { e.Encoder.AddComplex128(e.key(key), v) }
+
+// AddComplex64 implements the zapcore.ObjectEncoder interface.
+func (e *gelfEncoder) AddComplex64(key string, v complex64) { e.Encoder.AddComplex64(e.key(key), v) }
+
+// AddDuration implements the zapcore.ObjectEncoder interface.
+func (e *gelfEncoder) AddDuration(key string, v time.Duration) { e.Encoder.AddDuration(e.key(key), v) }
+
+// AddFloat64 implements the zapcore.ObjectEncoder interface.
+func (e *gelfEncoder) AddFloat64(key string, v float64) { e.Encoder.AddFloat64(e.key(key), v) }
+
+// AddFloat32 implements the zapcore.ObjectEncoder interface.
+func (e *gelfEncoder) AddFloat32(key string, v float32) { e.Encoder.AddFloat32(e.key(key), v) }
+
+// AddInt implements the zapcore.ObjectEncoder interface.
+func (e *gelfEncoder) AddInt(key string, v int) { e.Encoder.AddInt(e.key(key), v) }
+
+// AddInt64 implements the zapcore.ObjectEncoder interface.
+func (e *gelfEncoder) AddInt64(key string, v int64) { e.Encoder.AddInt64(e.key(key), v) }
+
+// AddInt32 implements the zapcore.ObjectEncoder interface.
+func (e *gelfEncoder) AddInt32(key string, v int32) { e.Encoder.AddInt32(e.key(key), v) }
+
+// AddInt16 implements the zapcore.ObjectEncoder interface.
+func (e *gelfEncoder) AddInt16(key string, v int16) { e.Encoder.AddInt16(e.key(key), v) }
+
+// AddInt8 implements the zapcore.ObjectEncoder interface.
+func (e *gelfEncoder) AddInt8(key string, v int8) { e.Encoder.AddInt8(e.key(key), v) }
+
+// AddString implements the zapcore.ObjectEncoder interface.
+func (e *gelfEncoder) AddString(key, v string) {
+	if !e.reserved(key, v) {
+		e.Encoder.AddString(e.key(key), v)
+	}
+}
+
+// AddTime implements the zapcore.ObjectEncoder interface.
+func (e *gelfEncoder) AddTime(key string, v time.Time) { e.Encoder.AddTime(e.key(key), v) }
+
+// AddUint implements the zapcore.ObjectEncoder interface.
+func (e *gelfEncoder) AddUint(key string, v uint) { e.Encoder.AddUint(e.key(key), v) }
+
+// AddUint64 implements the zapcore.ObjectEncoder interface.
+func (e *gelfEncoder) AddUint64(key string, v uint64) { e.Encoder.AddUint64(e.key(key), v) }
+
+// AddUint32 implements the zapcore.ObjectEncoder interface.
+func (e *gelfEncoder) AddUint32(key string, v uint32) { e.Encoder.AddUint32(e.key(key), v) }
+
+// AddUint16 implements the zapcore.ObjectEncoder interface.
+func (e *gelfEncoder) AddUint16(key string, v uint16) { e.Encoder.AddUint16(e.key(key), v) }
+
+// AddUint8 implements the zapcore.ObjectEncoder interface.
+func (e *gelfEncoder) AddUint8(key string, v uint8) { e.Encoder.AddUint8(e.key(key), v) }
+
+// AddUintptr implements the zapcore.ObjectEncoder interface.
+func (e *gelfEncoder) AddUintptr(key string, v uintptr) { e.Encoder.AddUintptr(e.key(key), v) }
+
+// Clone implements the zapcore.Encoder interface.
+func (e *gelfEncoder) Clone() zapcore.Encoder {
+	return e.clone()
+}
+
+func (e *gelfEncoder) clone() *gelfEncoder {
+	return &gelfEncoder{
+		Encoder: e.Encoder.Clone(),
+		host:    e.host,
+		prefix:  e.prefix,
+	}
+}
+
+// EncodeEntry implements the zapcore.Encoder interface.
+func (e *gelfEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	final := e.clone()
+	for _, f := range fields {
+		f.AddTo(final)
+	}
+
+	final.Encoder.AddString("version", gelfVersion)
+	final.Encoder.AddString("host", final.host)
+
+	return final.Encoder.EncodeEntry(ent, nil)
+}
+
+// gelfSink is a zap.Sink shipping GELF payloads via UDP, chunked if
+// necessary, or via TCP, delimited by null bytes.
+type gelfSink struct {
+	netSink
+	chunkSize int
+}
+
+func newGELFSink(u *url.URL) (zap.Sink, error) {
+	if len(u.Host) <= 0 || len(u.Port()) <= 0 {
+		return nil, fmt.Errorf("missing host or port of %s URL: got %v", u.Scheme, u)
+	}
+
+	s := &gelfSink{
+		netSink:   netSink{network: strings.TrimPrefix(u.Scheme, "gelf+"), address: u.Host},
+		chunkSize: 1420,
+	}
+	for k, vs := range u.Query() {
+		v := vs[len(vs)-1]
+		switch k {
+		case "chunk_size":
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 12 || n > gelfChunkSizeMax || s.network != "udp" {
+				return nil, fmt.Errorf("invalid query parameter %s=%q of %s URL", k, v, u.Scheme)
+			}
+			s.chunkSize = n
+		default:
+			return nil, fmt.Errorf("unknown query parameter %s=%q of %s URL", k, v, u.Scheme)
+		}
+	}
+
+	if err := s.dial(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Write implements the zap.Sink interface. p is expected to contain a single
+// payload, as written by zap's cores.
+func (s *gelfSink) Write(p []byte) (int, error) {
+	msg := []byte(strings.TrimRight(string(p), "\r\n"))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	if s.network == "tcp" {
+		err = s.writeTCP(msg)
+	} else {
+		err = s.writeUDP(msg)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (s *gelfSink) writeTCP(msg []byte) error {
+	return s.write(append(msg, 0))
+}
+
+func (s *gelfSink) writeUDP(msg []byte) error {
+	if len(msg) <= s.chunkSize {
+		return s.write(msg)
+	}
+
+	// chunk header: magic bytes, message id, sequence number and count
+	const headerSize = 12
+	dataSize := s.chunkSize - headerSize
+	count := (len(msg) + dataSize - 1) / dataSize
+	if count > gelfChunksMax {
+		return fmt.Errorf("GELF message of %d bytes exceeds %d chunks", len(msg), gelfChunksMax)
+	}
+
+	header := make([]byte, headerSize)
+	header[0], header[1] = 0x1e, 0x0f
+	if _, err := rand.Read(header[2:10]); err != nil {
+		return err
+	}
+	header[11] = byte(count)
+
+	chunk := make([]byte, 0, s.chunkSize)
+	for i := 0; i < count; i++ {
+		header[10] = byte(i)
+		end := (i + 1) * dataSize
+		if end > len(msg) {
+			end = len(msg)
+		}
+		chunk = append(append(chunk[:0], header...), msg[i*dataSize:end]...)
+		if err := s.write(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
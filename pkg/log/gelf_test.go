@@ -0,0 +1,263 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package log_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/sobchak-security/klutz/pkg/log"
+)
+
+type testObject struct{}
+
+func (testObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("b", "c")
+	enc.AddInt("d", 1)
+	return nil
+}
+
+func TestGELFEncoder(t *testing.T) {
+	ent := zapcore.Entry{
+		Level:      zapcore.WarnLevel,
+		Time:       time.Unix(1672531200, 500000000),
+		LoggerName: "test",
+		Message:    "hello",
+		Caller:     zapcore.NewEntryCaller(0, "/src/pkg/file.go", 42, true),
+		Stack:      "stack trace",
+	}
+	tests := []struct {
+		name   string
+		ec     zapcore.EncoderConfig
+		fields []zap.Field
+		want   map[string]interface{}
+	}{
+		{
+			name: "success: caller and stack trace omitted",
+			want: map[string]interface{}{
+				"version":       "1.1",
+				"host":          hostname(t),
+				"short_message": "hello",
+				"level":         float64(4),
+				"timestamp":     1672531200.5,
+				"_logger":       "test",
+			},
+		},
+		{
+			name: "success: caller and stack trace",
+			ec:   zapcore.EncoderConfig{CallerKey: "caller", StacktraceKey: "stacktrace"},
+			want: map[string]interface{}{
+				"version":       "1.1",
+				"host":          hostname(t),
+				"short_message": "hello",
+				"full_message":  "stack trace",
+				"level":         float64(4),
+				"timestamp":     1672531200.5,
+				"_logger":       "test",
+				"_caller":       "pkg/file.go:42",
+			},
+		},
+		{
+			name: "success: fixed fields",
+			ec:   zapcore.EncoderConfig{StacktraceKey: "stacktrace"},
+			want: map[string]interface{}{
+				"version":       "1.1",
+				"host":          hostname(t),
+				"short_message": "hello",
+				"full_message":  "stack trace",
+				"level":         float64(4),
+				"timestamp":     1672531200.5,
+				"_logger":       "test",
+			},
+		},
+		{
+			name: "success: additional fields",
+			ec:   zapcore.EncoderConfig{StacktraceKey: "stacktrace"},
+			fields: []zap.Field{
+				zap.String("host", "example.org"),
+				zap.String("version", "2"),
+				zap.String("id", "x"),
+				zap.Int("count", 3),
+				zap.Bool("ok", true),
+				zap.Strings("list", []string{"a", "b"}),
+				zap.Object("a", testObject{}),
+				zap.Namespace("ns"),
+				zap.String("key with space", "v"),
+			},
+			want: map[string]interface{}{
+				"version":            "1.1",
+				"host":               "example.org",
+				"short_message":      "hello",
+				"full_message":       "stack trace",
+				"level":              float64(4),
+				"timestamp":          1672531200.5,
+				"_logger":            "test",
+				"__id":               "x",
+				"_count":             float64(3),
+				"_ok":                "true",
+				"_list":              `["a","b"]`,
+				"_a_b":               "c",
+				"_a_d":               float64(1),
+				"_ns_key_with_space": "v",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf, err := log.NewGELFEncoder(tt.ec).EncodeEntry(ent, tt.fields)
+			if err != nil {
+				t.Fatalf("EncodeEntry() error = %v", err)
+			}
+			defer buf.Free()
+
+			var got map[string]interface{}
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("EncodeEntry(): invalid JSON %q - %v", buf.String(), err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("EncodeEntry(): %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGELFSinkUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket() error = %v", err)
+	}
+	defer conn.Close()
+
+	tests := []struct {
+		name    string
+		query   string
+		message string
+		chunked bool
+	}{
+		{name: "success: single datagram", message: "short"},
+		{name: "success: chunked datagrams", query: "?chunk_size=100", message: strings.Repeat("long ", 50), chunked: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := zap.NewProductionConfig()
+			cfg.Encoding = log.GELFEncoding
+			cfg.OutputPaths = []string{"gelf+udp://" + conn.LocalAddr().String() + tt.query}
+			l, err := cfg.Build()
+			if err != nil {
+				t.Fatalf("Build() error = %v", err)
+			}
+			l.Info(tt.message)
+
+			chunks := map[byte][]byte{}
+			count := 1
+			buf := make([]byte, 8192)
+			for i := 0; i < count; i++ {
+				_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+				n, _, err := conn.ReadFrom(buf)
+				if err != nil {
+					t.Fatalf("ReadFrom() error = %v", err)
+				}
+				p := append([]byte{}, buf[:n]...)
+				if !tt.chunked {
+					chunks[0] = p
+					continue
+				}
+				if p[0] != 0x1e || p[1] != 0x0f || p[11] < 2 {
+					t.Fatalf("ReadFrom(): invalid chunk header %x", p[:12])
+				}
+				count = int(p[11])
+				chunks[p[10]] = p[12:]
+			}
+
+			var payload []byte
+			for i := 0; i < count; i++ {
+				payload = append(payload, chunks[byte(i)]...)
+			}
+			var got map[string]interface{}
+			if err := json.Unmarshal(payload, &got); err != nil {
+				t.Fatalf("ReadFrom(): invalid JSON %q - %v", payload, err)
+			}
+			if got["short_message"] != tt.message || got["level"] != float64(6) {
+				t.Errorf("ReadFrom(): %v, want short_message %q and level 6", got, tt.message)
+			}
+		})
+	}
+}
+
+func TestGELFSinkTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer ln.Close()
+
+	messages := make(chan string, 2)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			frame, err := r.ReadString(0)
+			if err != nil {
+				return
+			}
+			messages <- strings.TrimSuffix(frame, "\x00")
+		}
+	}()
+
+	p := hclparse.NewParser()
+	f, diags := p.ParseHCL([]byte(`
+level    = "info"
+encoding = "gelf"
+output_paths = ["gelf+tcp://`+ln.Addr().String()+`"]
+`), "gelf.hcl")
+	if diags.HasErrors() {
+		t.Fatalf("ParseHCL() error = %v", diags)
+	}
+	var cfg zap.Config
+	if err := (*log.ConfigWrapper)(&cfg).UnmarshalHCL(nil, f.Body); err != nil {
+		t.Fatalf("UnmarshalHCL() error = %v", err)
+	}
+	l, err := cfg.Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	defer l.Sync()
+
+	for _, msg := range []string{"first", "second"} {
+		l.Error(msg, zap.String("user", "alice"))
+	}
+	for _, want := range []string{"first", "second"} {
+		select {
+		case frame := <-messages:
+			var got map[string]interface{}
+			if err := json.Unmarshal([]byte(frame), &got); err != nil {
+				t.Fatalf("ReadString(): invalid JSON %q - %v", frame, err)
+			}
+			if got["short_message"] != want || got["_user"] != "alice" || got["level"] != float64(3) {
+				t.Errorf("ReadString(): %v, want short_message %q", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("ReadString(): timeout waiting for %q", want)
+		}
+	}
+}
+
+func hostname(t *testing.T) string {
+	t.Helper()
+	host, _ := log.LookupEnv("HOSTNAME")
+	return host
+}
@@ -2,7 +2,14 @@ package log
 
 import (
 	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/zclconf/go-cty/cty"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -19,11 +26,19 @@ type encoderConfigHCL struct {
 	LineEnding       string `hcl:"line_ending,optional"`
 	EncodeLevel      string `hcl:"level_encoder,optional"`
 	EncodeTime       string `hcl:"time_encoder,optional"`
+	TimeLayout       string `hcl:"time_layout,optional"`
+	TimeZone         string `hcl:"time_zone,optional"`
 	EncodeDuration   string `hcl:"duration_encoder,optional"`
 	EncodeCaller     string `hcl:"caller_encoder,optional"`
 	EncodeName       string `hcl:"name_encoder,optional"`
 	ConsoleSeparator string `hcl:"console_separator,optional"`
 	SkipLineEnding   bool   `hcl:"skip_line_ending,optional"`
+	SyslogFacility   string `hcl:"syslog_facility,optional"`
+	SyslogAppName    string `hcl:"syslog_app_name,optional"`
+	SyslogHostname   string `hcl:"syslog_hostname,optional"`
+	SyslogFormat     string `hcl:"syslog_format,optional"`
+
+	Body hcl.Body `hcl:",body"`
 }
 
 func defaultZapEncoderConfig() zapcore.EncoderConfig {
@@ -37,20 +52,8 @@ func defaultZapEncoderConfig() zapcore.EncoderConfig {
 		EncodeTime:     defaultEncoderConfig.EncodeTime,
 	}
 }
-func (ech encoderConfigHCL) initZapEncoderConfig(zec *zapcore.EncoderConfig) {
-	defaultEncoderConfig := defaultZapEncoderConfig()
-
-	zec.MessageKey = ech.MessageKey
-	zec.LevelKey = ech.LevelKey
-	zec.TimeKey = ech.TimeKey
-	zec.NameKey = ech.NameKey
-	zec.CallerKey = ech.CallerKey
-	zec.FunctionKey = ech.FunctionKey
-	zec.StacktraceKey = ech.StacktraceKey
-	zec.SkipLineEnding = ech.SkipLineEnding
-	zec.LineEnding = ech.LineEnding
-	zec.ConsoleSeparator = ech.ConsoleSeparator
 
+func (ech encoderConfigHCL) initZapEncoderConfig(zec *zapcore.EncoderConfig) hcl.Diagnostics {
 	// see zap/zapcore/encoder.go
 	// > Configure the primitive representations of common complex types. For
 	// > example, some users may want all time.Times serialized as floating-point
@@ -59,91 +62,846 @@ func (ech encoderConfigHCL) initZapEncoderConfig(zec *zapcore.EncoderConfig) {
 	// > Unlike the other primitive type encoders, EncodeName is optional. The
 	// > zero value falls back to FullNameEncoder.
 
-	zec.EncodeCaller = defaultEncoderConfig.EncodeCaller
-	zec.EncodeDuration = defaultEncoderConfig.EncodeDuration
-	zec.EncodeLevel = defaultEncoderConfig.EncodeLevel
-	// zec.EncodeName = defaultEncoderConfig.EncodeName
-	zec.EncodeTime = defaultEncoderConfig.EncodeTime
+	defaultEncoderConfig := defaultZapEncoderConfig()
+	defaultEncoderConfig.EncodeName = zec.EncodeName
+	defaultEncoderConfig.NewReflectedEncoder = zec.NewReflectedEncoder
+	*zec = defaultEncoderConfig
+
+	return ech.overlayZapEncoderConfig(zec)
+}
+
+// overlayZapEncoderConfig sets the keys and encoders of zec, whose attributes
+// are present in the body of ech, and leaves the others untouched.
+func (ech encoderConfigHCL) overlayZapEncoderConfig(zec *zapcore.EncoderConfig) hcl.Diagnostics {
+	ranges := attributeRanges(ech.Body, &ech)
+
+	for _, attr := range []struct {
+		name string
+		dst  *string
+		v    string
+	}{
+		{"message_key", &zec.MessageKey, ech.MessageKey},
+		{"level_key", &zec.LevelKey, ech.LevelKey},
+		{"time_key", &zec.TimeKey, ech.TimeKey},
+		{"name_key", &zec.NameKey, ech.NameKey},
+		{"caller_key", &zec.CallerKey, ech.CallerKey},
+		{"function_key", &zec.FunctionKey, ech.FunctionKey},
+		{"stacktrace_key", &zec.StacktraceKey, ech.StacktraceKey},
+		{"line_ending", &zec.LineEnding, ech.LineEnding},
+		{"console_separator", &zec.ConsoleSeparator, ech.ConsoleSeparator},
+	} {
+		if ranges.present(attr.name) {
+			*attr.dst = attr.v
+		}
+	}
+	if ranges.present("skip_line_ending") {
+		zec.SkipLineEnding = ech.SkipLineEnding
+	}
+
+	var diags hcl.Diagnostics
 
 	if len(ech.EncodeLevel) > 0 {
-		_ = (&zec.EncodeLevel).UnmarshalText([]byte(ech.EncodeLevel))
+		if enc, d := lookupEncoder(levelEncoders, ech.EncodeLevel, ranges.get("level_encoder")); d.HasErrors() {
+			diags = append(diags, d...)
+		} else {
+			zec.EncodeLevel = enc
+		}
 	}
 	if len(ech.EncodeTime) > 0 {
-		switch ech.EncodeTime {
-		case ConfigKeyTimeEncoderShort:
-			zec.EncodeTime = EpochShortTimeEncoder
-		case ConfigKeyTimeEncoderInt64Seconds:
-			zec.EncodeTime = EpochInt64SecondsEncoder
-		default:
-			_ = (&zec.EncodeTime).UnmarshalText([]byte(ech.EncodeTime))
+		if enc, d := lookupEncoder(timeEncoders, ech.EncodeTime, ranges.get("time_encoder")); d.HasErrors() {
+			diags = append(diags, d...)
+		} else {
+			zec.EncodeTime = enc
 		}
 	}
 	if len(ech.EncodeDuration) > 0 {
-		_ = (&zec.EncodeDuration).UnmarshalText([]byte(ech.EncodeDuration))
+		if enc, d := lookupEncoder(durationEncoders, ech.EncodeDuration, ranges.get("duration_encoder")); d.HasErrors() {
+			diags = append(diags, d...)
+		} else {
+			zec.EncodeDuration = enc
+		}
 	}
 	if len(ech.EncodeCaller) > 0 {
-		_ = (&zec.EncodeCaller).UnmarshalText([]byte(ech.EncodeCaller))
+		if enc, d := lookupEncoder(callerEncoders, ech.EncodeCaller, ranges.get("caller_encoder")); d.HasErrors() {
+			diags = append(diags, d...)
+		} else {
+			zec.EncodeCaller = enc
+		}
 	}
 	if len(ech.EncodeName) > 0 {
-		_ = (&zec.EncodeName).UnmarshalText([]byte(ech.EncodeName))
+		if enc, d := lookupEncoder(nameEncoders, ech.EncodeName, ranges.get("name_encoder")); d.HasErrors() {
+			diags = append(diags, d...)
+		} else {
+			zec.EncodeName = enc
+		}
+	}
+
+	return diags
+}
+
+// timeFormat returns the time layout and zone configured by ech, cf.
+// Config.TimeLayout; a time encoder or layout set by ech overrides both layout
+// and zone of base, a time zone set by ech only the zone.
+func (ech encoderConfigHCL) timeFormat(baseLayout, baseZone string) (layout, zone string, diags hcl.Diagnostics) {
+	ranges := attributeRanges(ech.Body, &ech)
+
+	layout, zone = baseLayout, baseZone
+	if len(ech.EncodeTime) > 0 && len(ech.TimeLayout) > 0 {
+		return "", "", hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Conflicting time encoder configuration",
+			Detail:   "Only one of time_encoder and time_layout may be set.",
+			Subject:  ranges.get("time_layout"),
+		}}
+	}
+	if len(ech.EncodeTime) > 0 || len(ech.TimeLayout) > 0 {
+		layout, zone = ech.TimeLayout, ""
+	}
+	if len(ech.TimeZone) > 0 {
+		if _, err := time.LoadLocation(ech.TimeZone); err != nil {
+			return "", "", hcl.Diagnostics{{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid time zone",
+				Detail:   fmt.Sprintf("The time zone %q is unknown, e.g. use \"UTC\", \"Local\" or an IANA time zone name - %v.", ech.TimeZone, err),
+				Subject:  ranges.get("time_zone"),
+			}}
+		}
+		zone = ech.TimeZone
+	}
+	return layout, zone, nil
+}
+
+// syslogOptions returns the syslog options of a logger, whose encoding is
+// given by encoding, which have been configured by ech; options set by ech
+// override those of base. If syslog options have been set, the encoding has
+// to be "syslog".
+func (ech encoderConfigHCL) syslogOptions(encoding string, base SyslogOptions) (SyslogOptions, hcl.Diagnostics) {
+	opts := base
+	for _, opt := range []struct {
+		dst *string
+		v   string
+	}{
+		{&opts.Facility, ech.SyslogFacility},
+		{&opts.AppName, ech.SyslogAppName},
+		{&opts.Hostname, ech.SyslogHostname},
+		{&opts.Format, ech.SyslogFormat},
+	} {
+		if len(opt.v) > 0 {
+			*opt.dst = opt.v
+		}
+	}
+	if opts == (SyslogOptions{}) {
+		return opts, nil
+	}
+
+	ranges := attributeRanges(ech.Body, &ech)
+
+	if encoding != SyslogEncoding {
+		subject := ranges.get("syslog_facility")
+		for _, name := range []string{"syslog_facility", "syslog_app_name", "syslog_hostname", "syslog_format"} {
+			if _, ok := ranges.attrs[name]; ok {
+				subject = ranges.get(name)
+				break
+			}
+		}
+		return SyslogOptions{}, hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid syslog configuration",
+			Detail:   fmt.Sprintf("Syslog options require the encoding %q, got %q.", SyslogEncoding, encoding),
+			Subject:  subject,
+		}}
+	}
+
+	if err := opts.validate(); err != nil {
+		subject := ranges.get("syslog_format")
+		if _, ok := syslogFacilities[opts.Facility]; len(opts.Facility) > 0 && !ok {
+			subject = ranges.get("syslog_facility")
+		}
+		return SyslogOptions{}, hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid syslog configuration",
+			Detail:   fmt.Sprintf("The %s.", err),
+			Subject:  subject,
+		}}
+	}
+	return opts, nil
+}
+
+// lookupEncoder resolves the encoder name in the given registry, returning an
+// error diagnostic located at subject, if the name is unknown.
+func lookupEncoder[E any](encoders *registry[E], name string, subject *hcl.Range) (E, hcl.Diagnostics) {
+	if enc, ok := encoders.lookup(name); ok {
+		return enc, nil
+	}
+
+	var zero E
+	names := encoders.names()
+	detail := fmt.Sprintf("The %s %q is unknown; valid names are %q.", encoders.kind, name, names)
+	if suggestion := nameSuggestion(name, names); len(suggestion) > 0 {
+		detail = fmt.Sprintf("The %s %q is unknown. Did you mean %q?", encoders.kind, name, suggestion)
+	}
+	return zero, hcl.Diagnostics{{
+		Severity: hcl.DiagError,
+		Summary:  fmt.Sprintf("Invalid %s", encoders.kind),
+		Detail:   detail,
+		Subject:  subject,
+	}}
+}
+
+// samplingConfigHCL is a HCL-compatible representation of zap.SamplingConfig.
+// The tick is a duration like "500ms"; ConfigWrapper rejects any tick but
+// "1s", as zap.Config always samples per second.
+type samplingConfigHCL struct {
+	Initial    int    `hcl:"initial,optional" hclwrite:"zero"`
+	Thereafter int    `hcl:"thereafter,optional" hclwrite:"zero"`
+	Tick       string `hcl:"tick,optional"`
+	Hook       string `hcl:"hook,optional"`
+
+	Body hcl.Body `hcl:",body"`
+}
+
+// overlayZapSamplingConfig returns a copy of base and the tick baseTick, whose
+// settings are overridden by the attributes present in the body of sch; base
+// is left untouched. Without base, initial and thereafter default to the
+// values of zap's production configuration. A thereafter of 0, which drops
+// all entries per tick after the initial ones, has to be set explicitly.
+func (sch samplingConfigHCL) overlayZapSamplingConfig(base *zap.SamplingConfig, baseTick time.Duration) (*zap.SamplingConfig, time.Duration, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+
+	ranges := attributeRanges(sch.Body, &sch)
+
+	sc := &zap.SamplingConfig{}
+	if base != nil {
+		*sc = *base
+	} else if def := zap.NewProductionConfig().Sampling; def != nil {
+		sc.Initial, sc.Thereafter = def.Initial, def.Thereafter
+	}
+	tick := baseTick
+
+	if ranges.present("initial") {
+		sc.Initial = sch.Initial
+	}
+	if ranges.present("thereafter") {
+		sc.Thereafter = sch.Thereafter
+	}
+	if sc.Initial < 0 {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid sampling configuration",
+			Detail:   fmt.Sprintf("The initial number of entries must not be negative, got %d.", sc.Initial),
+			Subject:  ranges.get("initial"),
+		})
+	}
+	if sc.Thereafter < 0 {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid sampling configuration",
+			Detail:   fmt.Sprintf("The number of entries thereafter must not be negative, got %d.", sc.Thereafter),
+			Subject:  ranges.get("thereafter"),
+		})
+	}
+	if sc.Thereafter == 0 && !ranges.present("thereafter") {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid sampling configuration",
+			Detail:   "The number of entries thereafter is 0, which drops all entries per tick after the initial ones; set thereafter = 0 explicitly, if intended.",
+			Subject:  ranges.get("thereafter"),
+		})
+	}
+
+	if len(sch.Tick) > 0 {
+		var err error
+		if tick, err = time.ParseDuration(sch.Tick); err != nil || tick <= 0 {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid sampling configuration",
+				Detail:   fmt.Sprintf("The tick %q is not a positive duration, e.g. \"1s\" or \"500ms\".", sch.Tick),
+				Subject:  ranges.get("tick"),
+			})
+		}
+	}
+
+	if len(sch.Hook) > 0 {
+		hook, ok := samplingHooks.lookup(sch.Hook)
+		if !ok {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid sampling configuration",
+				Detail:   fmt.Sprintf("The sampling hook %q has not been registered.", sch.Hook),
+				Subject:  ranges.get("hook"),
+			})
+		}
+		sc.Hook = hook
+	}
+
+	if diags.HasErrors() {
+		return nil, 0, diags
+	}
+
+	return sc, tick, nil
+}
+
+// sinkConfigHCL is a HCL-compatible representation of a rotating file sink,
+// cf. RotateOptions.
+type sinkConfigHCL struct {
+	Path           string `hcl:"path"`
+	MaxSize        int    `hcl:"max_size,optional"`
+	MaxAge         int    `hcl:"max_age,optional"`
+	MaxBackups     int    `hcl:"max_backups,optional"`
+	Compress       bool   `hcl:"compress,optional"`
+	LocalTime      bool   `hcl:"local_time,optional"`
+	RotateOnSIGHUP bool   `hcl:"rotate_on_sighup,optional"`
+
+	Body hcl.Body `hcl:",body"`
+}
+
+func (sch sinkConfigHCL) rotateURL() (string, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+
+	ranges := attributeRanges(sch.Body, &sch)
+
+	if len(sch.Path) <= 0 {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid sink configuration",
+			Detail:   "The path of a sink must not be empty.",
+			Subject:  ranges.get("path"),
+		})
+	}
+	for _, attr := range []struct {
+		name string
+		v    int
+	}{
+		{"max_size", sch.MaxSize},
+		{"max_age", sch.MaxAge},
+		{"max_backups", sch.MaxBackups},
+	} {
+		if name, v := attr.name, attr.v; v < 0 {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid sink configuration",
+				Detail:   fmt.Sprintf("The value of %s must not be negative, got %d.", name, v),
+				Subject:  ranges.get(name),
+			})
+		}
+	}
+	if diags.HasErrors() {
+		return "", diags
+	}
+
+	return RotateURL(sch.Path, RotateOptions{
+		MaxSize:        sch.MaxSize,
+		MaxAge:         sch.MaxAge,
+		MaxBackups:     sch.MaxBackups,
+		Compress:       sch.Compress,
+		LocalTime:      sch.LocalTime,
+		RotateOnSIGHUP: sch.RotateOnSIGHUP,
+	}), nil
+}
+
+// redactConfigHCL is a HCL-compatible representation of RedactConfig.
+type redactConfigHCL struct {
+	Keys        []string `hcl:"keys,optional"`
+	Values      []string `hcl:"values,optional"`
+	Presets     []string `hcl:"presets,optional"`
+	Action      string   `hcl:"action,optional"`
+	Replacement string   `hcl:"replacement,optional"`
+	HashKey     string   `hcl:"hash_key,optional"`
+
+	Body hcl.Body `hcl:",body"`
+}
+
+func (rch redactConfigHCL) redactConfig() (RedactConfig, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+
+	ranges := attributeRanges(rch.Body, &rch)
+
+	// attributes are validated separately, so errors point at the offending
+	// attribute
+	for _, attr := range []struct {
+		name string
+		cfg  RedactConfig
+	}{
+		{"keys", RedactConfig{Keys: rch.Keys}},
+		{"values", RedactConfig{Values: rch.Values}},
+		{"presets", RedactConfig{Presets: rch.Presets}},
+		{"action", RedactConfig{Action: rch.Action, HashKey: rch.HashKey}},
+	} {
+		if _, err := newRedactor(attr.cfg); err != nil {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid redact configuration",
+				Detail:   fmt.Sprintf("The %s.", err),
+				Subject:  ranges.get(attr.name),
+			})
+		}
+	}
+	if diags.HasErrors() {
+		return RedactConfig{}, diags
+	}
+
+	return RedactConfig{
+		Keys:        rch.Keys,
+		Values:      rch.Values,
+		Presets:     rch.Presets,
+		Action:      rch.Action,
+		Replacement: rch.Replacement,
+		HashKey:     rch.HashKey,
+	}, nil
+}
+
+// outputConfigHCL is a HCL-compatible representation of OutputConfig.
+type outputConfigHCL struct {
+	Name          string            `hcl:"name,label"`
+	Path          string            `hcl:"path"`
+	Level         string            `hcl:"level,optional"`
+	Encoding      string            `hcl:"encoding,optional"`
+	EncoderConfig *encoderConfigHCL `hcl:"encoder_config,block"`
+
+	Body hcl.Body `hcl:",body"`
+}
+
+// initOutputConfig initializes the output configured by och; level, encoding,
+// encoder configuration and syslog options default to those of base.
+func (och outputConfigHCL) initOutputConfig(base Config) (OutputConfig, hcl.Diagnostics) {
+	ranges := attributeRanges(och.Body, &och)
+
+	oc := OutputConfig{
+		Name:          och.Name,
+		Level:         zap.NewAtomicLevelAt(base.Level.Level()),
+		Encoding:      och.Encoding,
+		EncoderConfig: base.EncoderConfig,
+		TimeLayout:    base.TimeLayout,
+		TimeZone:      base.TimeZone,
+		OutputPaths:   []string{och.Path},
+	}
+
+	if len(och.Path) <= 0 {
+		return OutputConfig{}, hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid output configuration",
+			Detail:   fmt.Sprintf("The path of output %q must not be empty.", och.Name),
+			Subject:  ranges.get("path"),
+		}}
+	}
+	if len(och.Level) > 0 {
+		lvl, diags := parseLevelHCL(och.Level, ranges.get("level"))
+		if diags.HasErrors() {
+			return OutputConfig{}, diags
+		}
+		oc.Level.SetLevel(lvl)
+	}
+	if len(oc.Encoding) <= 0 || oc.Encoding == base.Encoding {
+		// syslog options of another encoding do not apply
+		oc.Encoding, oc.Syslog = base.Encoding, base.Syslog
+	}
+
+	if ech := och.EncoderConfig; ech != nil {
+		if diags := ech.overlayZapEncoderConfig(&oc.EncoderConfig); diags.HasErrors() {
+			return OutputConfig{}, diags
+		}
+		layout, zone, diags := ech.timeFormat(oc.TimeLayout, oc.TimeZone)
+		if diags.HasErrors() {
+			return OutputConfig{}, diags
+		}
+		oc.TimeLayout, oc.TimeZone = layout, zone
+		opts, diags := ech.syslogOptions(oc.Encoding, oc.Syslog)
+		if diags.HasErrors() {
+			return OutputConfig{}, diags
+		}
+		oc.Syslog = opts
 	}
+
+	return oc, nil
 }
 
 // configHCL is a HCL-compatible representation of zap.configHCL.
 type configHCL struct {
-	// Sampling *zap.SamplingConfig `json:"sampling" yaml:"sampling"`
-	Level    string `hcl:"level,optional"`
-	Encoding string `hcl:"encoding,optional"`
+	Sampling *samplingConfigHCL `hcl:"sampling,block"`
+	Level    string             `hcl:"level,optional"`
+	Encoding string             `hcl:"encoding,optional"`
 	// EncoderConfig hcl.Body `hcl:"encoder_config,remain"`
 	EncoderConfig     *encoderConfigHCL `hcl:"encoder_config,block"`
+	Sinks             []sinkConfigHCL   `hcl:"sink,block"`
+	Redact            *redactConfigHCL  `hcl:"redact,block"`
+	Outputs           []outputConfigHCL `hcl:"output,block"`
 	OutputPaths       []string          `hcl:"output_paths,optional"`
 	ErrorOutputPaths  []string          `hcl:"error_output_paths,optional"`
-	InitialFields     map[string]string `hcl:"initial_fields,optional"`
+	InitialFields     cty.Value         `hcl:"initial_fields,optional"`
 	Development       bool              `hcl:"development,optional"`
 	DisableCaller     bool              `hcl:"disable_caller,optional"`
 	DisableStacktrace bool              `hcl:"disable_stacktrace,optional"`
-}
 
-func (ec configHCL) initZapConfig(zc *zap.Config) error {
-	zc.OutputPaths = ec.OutputPaths
-	zc.ErrorOutputPaths = ec.ErrorOutputPaths
-	zc.Development = ec.Development
-	zc.DisableCaller = ec.DisableCaller
-	zc.DisableStacktrace = ec.DisableStacktrace
+	Body hcl.Body `hcl:",body"`
+}
 
-	if len(ec.Encoding) > 0 {
-		zc.Encoding = ec.Encoding
-	} else {
+func (ec configHCL) initConfig(c *Config) hcl.Diagnostics {
+	*c = Config{Config: zap.Config{
 		// encoding has to be present for the config to be "buildable"
-		zc.Encoding = zap.NewProductionConfig().Encoding
+		Encoding: zap.NewProductionConfig().Encoding,
+		// it is tricky to figure out, whether an AtomicLevel is properly
+		// initialized; better to always make sure a zap.Config contains
+		// one
+		Level:         zap.NewAtomicLevel(),
+		EncoderConfig: defaultZapEncoderConfig(),
+	}}
+	return ec.overlayConfig(c)
+}
+
+// overlayConfig overrides the settings of c by the attributes and blocks
+// present in the body of ec: initial fields are merged, sinks are appended to
+// the output paths, the attributes of the sampling and encoder_config blocks
+// override those of c one by one, and a redact block replaces the redaction.
+// Slices, maps, the level and the sampling configuration are replaced rather
+// than modified, as c might share them with the configuration it was copied
+// from.
+func (ec configHCL) overlayConfig(c *Config) hcl.Diagnostics {
+	zc := &c.Config
+	ranges := attributeRanges(ec.Body, &ec)
+
+	encoding, opts := zc.Encoding, c.Syslog
+	if len(ec.Encoding) > 0 && ec.Encoding != encoding {
+		// syslog options of another encoding do not apply
+		encoding, opts = ec.Encoding, SyslogOptions{}
+	}
+
+	if ranges.present("output_paths") {
+		zc.OutputPaths = append([]string(nil), ec.OutputPaths...)
+	}
+	if len(ec.Sinks) > 0 {
+		// rotating file sinks are appended to the output paths
+		zc.OutputPaths = append([]string{}, zc.OutputPaths...)
+		for _, sink := range ec.Sinks {
+			u, diags := sink.rotateURL()
+			if diags.HasErrors() {
+				return diags
+			}
+			zc.OutputPaths = append(zc.OutputPaths, u)
+		}
+	}
+	if ranges.present("error_output_paths") {
+		zc.ErrorOutputPaths = append([]string(nil), ec.ErrorOutputPaths...)
+	}
+	if ranges.present("development") {
+		zc.Development = ec.Development
+	}
+	if ranges.present("disable_caller") {
+		zc.DisableCaller = ec.DisableCaller
+	}
+	if ranges.present("disable_stacktrace") {
+		zc.DisableStacktrace = ec.DisableStacktrace
 	}
 
 	if len(ec.Level) > 0 {
-		lvl, err := zapcore.ParseLevel(ec.Level)
-		if err != nil {
-			return fmt.Errorf(
-				"UnmarshalHCL(): parsing log level %q failed - %w", ec.Level, err)
+		lvl, diags := parseLevelHCL(ec.Level, ranges.get("level"))
+		if diags.HasErrors() {
+			return diags
 		}
 		zc.Level = zap.NewAtomicLevelAt(lvl)
-	} else {
-		// it is tricky to figure out, whether an AtomicLevel is properly
-		// initialized; better to always make sure a zap.Config contains
-		// one
+	} else if zc.Level == (zap.AtomicLevel{}) {
 		zc.Level = zap.NewAtomicLevel()
 	}
 
-	if len(ec.InitialFields) > 0 {
-		zc.InitialFields = make(map[string]interface{}, len(ec.InitialFields))
-		for k, v := range ec.InitialFields {
-			zc.InitialFields[k] = v
+	initialFields, diags := initialFieldsHCL(ec.InitialFields, ranges.get("initial_fields"))
+	if diags.HasErrors() {
+		return diags
+	}
+	if len(initialFields) > 0 {
+		fields := make(map[string]interface{}, len(zc.InitialFields)+len(initialFields))
+		for k, v := range zc.InitialFields {
+			fields[k] = v
+		}
+		for k, v := range initialFields {
+			fields[k] = v
+		}
+		zc.InitialFields = fields
+	}
+
+	if ec.Sampling != nil {
+		if zc.Sampling, c.SamplingTick, diags = ec.Sampling.overlayZapSamplingConfig(zc.Sampling, c.SamplingTick); diags.HasErrors() {
+			return diags
 		}
 	}
 
 	if ec.EncoderConfig != nil {
-		ec.EncoderConfig.initZapEncoderConfig(&zc.EncoderConfig)
-	} else {
-		zc.EncoderConfig = defaultZapEncoderConfig()
+		if diags := ec.EncoderConfig.overlayZapEncoderConfig(&zc.EncoderConfig); diags.HasErrors() {
+			return diags
+		}
+		if c.TimeLayout, c.TimeZone, diags = ec.EncoderConfig.timeFormat(c.TimeLayout, c.TimeZone); diags.HasErrors() {
+			return diags
+		}
+		if opts, diags = ec.EncoderConfig.syslogOptions(encoding, opts); diags.HasErrors() {
+			return diags
+		}
 	}
 
+	if ec.Redact != nil {
+		rc, diags := ec.Redact.redactConfig()
+		if diags.HasErrors() {
+			return diags
+		}
+		c.Redact = &rc
+	}
+	zc.Encoding = encoding
+	c.Syslog = opts
+
 	return nil
 }
+
+// initialFieldsHCL converts the value of the attribute initial_fields, an
+// object or a map, to initial fields, returning an error diagnostic located
+// at subject, if the value or one of its elements is not supported. Values
+// are converted by ctyToGo; null and absent values result in no fields.
+func initialFieldsHCL(v cty.Value, subject *hcl.Range) (map[string]interface{}, hcl.Diagnostics) {
+	diag := func(detail string) hcl.Diagnostics {
+		return hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid initial fields",
+			Detail:   detail,
+			Subject:  subject,
+		}}
+	}
+
+	switch {
+	case v == cty.NilVal:
+		return nil, nil
+	case v.IsNull():
+		return nil, nil
+	case !v.Type().IsObjectType() && !v.Type().IsMapType():
+		return nil, diag(fmt.Sprintf("Initial fields must be an object, got %s.", v.Type().FriendlyName()))
+	}
+
+	fields, err := ctyToGo(v, "initial_fields")
+	if err != nil {
+		return nil, diag(fmt.Sprintf("The %s.", err))
+	}
+	return fields.(map[string]interface{}), nil
+}
+
+// ctyToGo converts v to nil, a string, a bool, an int64, a float64, a
+// []interface{} or a map[string]interface{}, recursively; numbers are
+// converted to int64, if they are integers in its range. path names v in
+// errors.
+func ctyToGo(v cty.Value, path string) (interface{}, error) {
+	if !v.IsKnown() {
+		return nil, fmt.Errorf("value of %s is unknown", path)
+	}
+	if v.IsNull() {
+		return nil, nil
+	}
+
+	ty := v.Type()
+	switch {
+	case ty == cty.String:
+		return v.AsString(), nil
+	case ty == cty.Bool:
+		return v.True(), nil
+	case ty == cty.Number:
+		bf := v.AsBigFloat()
+		if i, acc := bf.Int64(); acc == big.Exact {
+			return i, nil
+		}
+		f, _ := bf.Float64()
+		return f, nil
+	case ty.IsListType(), ty.IsSetType(), ty.IsTupleType():
+		s := make([]interface{}, 0, v.LengthInt())
+		for it := v.ElementIterator(); it.Next(); {
+			_, e := it.Element()
+			ge, err := ctyToGo(e, fmt.Sprintf("%s[%d]", path, len(s)))
+			if err != nil {
+				return nil, err
+			}
+			s = append(s, ge)
+		}
+		return s, nil
+	case ty.IsMapType(), ty.IsObjectType():
+		m := make(map[string]interface{}, v.LengthInt())
+		for it := v.ElementIterator(); it.Next(); {
+			k, e := it.Element()
+			ge, err := ctyToGo(e, path+"."+k.AsString())
+			if err != nil {
+				return nil, err
+			}
+			m[k.AsString()] = ge
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("type %s of %s is not supported", ty.FriendlyName(), path)
+	}
+}
+
+// parseLevelHCL parses the log level lvl, returning an error diagnostic located
+// at subject, if the level is unknown.
+func parseLevelHCL(lvl string, subject *hcl.Range) (zapcore.Level, hcl.Diagnostics) {
+	level, err := zapcore.ParseLevel(lvl)
+	if err != nil {
+		detail := fmt.Sprintf("The log level %q is unknown; valid levels are %q.", lvl, Levels)
+		if suggestion := nameSuggestion(lvl, Levels); len(suggestion) > 0 {
+			detail = fmt.Sprintf("The log level %q is unknown. Did you mean %q?", lvl, suggestion)
+		}
+		return zapcore.InvalidLevel, hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid log level",
+			Detail:   detail,
+			Subject:  subject,
+		}}
+	}
+	return level, nil
+}
+
+// hclRanges maps attribute names to the source ranges of their expressions.
+type hclRanges struct {
+	attrs   map[string]hcl.Range
+	missing hcl.Range
+}
+
+// attributeRanges determines the source ranges of all attributes of body,
+// which are part of the schema implied by val.
+func attributeRanges(body hcl.Body, val interface{}) hclRanges {
+	if body == nil {
+		return hclRanges{}
+	}
+
+	ranges := hclRanges{
+		attrs:   map[string]hcl.Range{},
+		missing: body.MissingItemRange(),
+	}
+	schema, _ := gohcl.ImpliedBodySchema(val)
+	content, _, _ := body.PartialContent(schema)
+	if content != nil {
+		for name, attr := range content.Attributes {
+			ranges.attrs[name] = attr.Expr.Range()
+		}
+	}
+	return ranges
+}
+
+// present reports, whether the attribute name is present. Without a body, all
+// attributes are considered to be present.
+func (r hclRanges) present(name string) bool {
+	if r.attrs == nil {
+		return true
+	}
+	_, ok := r.attrs[name]
+	return ok
+}
+
+// get returns the range of the attribute name or, if it is not present, the
+// range of the enclosing body.
+func (r hclRanges) get(name string) *hcl.Range {
+	if rng, ok := r.attrs[name]; ok {
+		return &rng
+	}
+	if r.attrs == nil {
+		return nil
+	}
+	return r.missing.Ptr()
+}
+
+// markedAttributes are the paths of the attributes, which accept marked
+// values, e.g. the sensitive ones of secret_file(), cf. lib.FileFunctions.
+// The values of all other attributes would be disclosed in log entries or by
+// Config.MarshalHCL, which never renders the attributes listed here.
+var markedAttributes = []string{"redact.hash_key"}
+
+// unmarkedBody wraps a body, whose attribute values are unmarked, as gohcl
+// cannot decode marked values. Marked values of attributes other than the
+// markedAttributes are reported as diagnostics instead; path is the path of
+// the body's block, e.g. "redact", or empty for the root body.
+type unmarkedBody struct {
+	hcl.Body
+	path string
+}
+
+// unmarkBody wraps the root body, cf. unmarkedBody.
+func unmarkBody(body hcl.Body) hcl.Body {
+	return unmarkedBody{Body: body}
+}
+
+// Content implements hcl.Body.
+func (b unmarkedBody) Content(schema *hcl.BodySchema) (*hcl.BodyContent, hcl.Diagnostics) {
+	content, diags := b.Body.Content(schema)
+	return b.unmarkContent(content), diags
+}
+
+// PartialContent implements hcl.Body.
+func (b unmarkedBody) PartialContent(schema *hcl.BodySchema) (*hcl.BodyContent, hcl.Body, hcl.Diagnostics) {
+	content, remain, diags := b.Body.PartialContent(schema)
+	if remain != nil {
+		remain = unmarkedBody{Body: remain, path: b.path}
+	}
+	return b.unmarkContent(content), remain, diags
+}
+
+// JustAttributes implements hcl.Body.
+func (b unmarkedBody) JustAttributes() (hcl.Attributes, hcl.Diagnostics) {
+	attrs, diags := b.Body.JustAttributes()
+	return b.unmarkAttributes(attrs), diags
+}
+
+func (b unmarkedBody) unmarkContent(content *hcl.BodyContent) *hcl.BodyContent {
+	if content == nil {
+		return nil
+	}
+	unmarked := *content
+	unmarked.Attributes = b.unmarkAttributes(content.Attributes)
+	unmarked.Blocks = make(hcl.Blocks, 0, len(content.Blocks))
+	for _, block := range content.Blocks {
+		block := *block
+		block.Body = unmarkedBody{Body: block.Body, path: b.join(block.Type)}
+		unmarked.Blocks = append(unmarked.Blocks, &block)
+	}
+	return &unmarked
+}
+
+func (b unmarkedBody) unmarkAttributes(attrs hcl.Attributes) hcl.Attributes {
+	if attrs == nil {
+		return nil
+	}
+	unmarked := make(hcl.Attributes, len(attrs))
+	for name, attr := range attrs {
+		attr := *attr
+		path := b.join(name)
+		attr.Expr = unmarkedExpression{Expression: attr.Expr, path: path, allowed: slices.Contains(markedAttributes, path)}
+		unmarked[name] = &attr
+	}
+	return unmarked
+}
+
+// join returns the path of the attribute or block name within b.
+func (b unmarkedBody) join(name string) string {
+	if len(b.path) <= 0 {
+		return name
+	}
+	return b.path + "." + name
+}
+
+// unmarkedExpression wraps an expression, whose value is unmarked, if marked
+// values are allowed, and rejected otherwise.
+type unmarkedExpression struct {
+	hcl.Expression
+	path    string
+	allowed bool
+}
+
+// Value implements hcl.Expression.
+func (e unmarkedExpression) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	v, diags := e.Expression.Value(ctx)
+	if !v.ContainsMarked() {
+		return v, diags
+	}
+	if !e.allowed {
+		// the value must not be disclosed by the diagnostic; it is unmarked
+		// nonetheless, so decoding reports no further errors, and discarded
+		// together with the decoded configuration
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid marked value",
+			Detail: fmt.Sprintf("The attribute %s must not contain marked values, e.g. sensitive ones, which are only accepted by %s.",
+				e.path, strings.Join(markedAttributes, ", ")),
+			Subject: e.Expression.Range().Ptr(),
+		})
+	}
+	v, _ = v.UnmarkDeep()
+	return v, diags
+}
@@ -0,0 +1,354 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	stdlog "log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// hclogLogger implements the hclog.Logger interface on top of a zap.Logger.
+type hclogLogger struct {
+	// base is the logger with all implied arguments, but without the name
+	base    *zap.Logger
+	logger  *zap.Logger
+	level   zap.AtomicLevel
+	name    string
+	implied []interface{}
+}
+
+// NewHclogLogger creates a hclog.Logger, e.g. for HashiCorp plugins, which
+// logs to l. Arguments are converted to fields, and the levels trace and
+// debug are both logged at debug level. The names of hclog loggers are
+// appended to the name of l.
+//
+// SetLevel changes lvl, which should be the atomic level of l, e.g. as
+// returned by New; if lvl is the zero value, SetLevel is a no-op.
+func NewHclogLogger(l *zap.Logger, lvl zap.AtomicLevel) hclog.Logger {
+	// skip hclogLogger.log and its exported caller, e.g. hclogLogger.Info
+	l = l.WithOptions(zap.AddCallerSkip(2))
+	return &hclogLogger{base: l, logger: l, level: lvl}
+}
+
+// Log implements the hclog.Logger interface.
+func (h *hclogLogger) Log(level hclog.Level, msg string, args ...interface{}) {
+	h.log(level, msg, args)
+}
+
+// Trace implements the hclog.Logger interface.
+func (h *hclogLogger) Trace(msg string, args ...interface{}) {
+	h.log(hclog.Trace, msg, args)
+}
+
+// Debug implements the hclog.Logger interface.
+func (h *hclogLogger) Debug(msg string, args ...interface{}) {
+	h.log(hclog.Debug, msg, args)
+}
+
+// Info implements the hclog.Logger interface.
+func (h *hclogLogger) Info(msg string, args ...interface{}) {
+	h.log(hclog.Info, msg, args)
+}
+
+// Warn implements the hclog.Logger interface.
+func (h *hclogLogger) Warn(msg string, args ...interface{}) {
+	h.log(hclog.Warn, msg, args)
+}
+
+// Error implements the hclog.Logger interface.
+func (h *hclogLogger) Error(msg string, args ...interface{}) {
+	h.log(hclog.Error, msg, args)
+}
+
+// log logs msg with the fields of args at the zap level of level.
+func (h *hclogLogger) log(level hclog.Level, msg string, args []interface{}) {
+	if level == hclog.Off {
+		return
+	}
+	if ce := h.logger.Check(zapLevelOf(level), msg); ce != nil {
+		ce.Write(hclogFields(args)...)
+	}
+}
+
+// IsTrace implements the hclog.Logger interface.
+func (h *hclogLogger) IsTrace() bool {
+	return h.logger.Core().Enabled(zapcore.DebugLevel)
+}
+
+// IsDebug implements the hclog.Logger interface.
+func (h *hclogLogger) IsDebug() bool {
+	return h.logger.Core().Enabled(zapcore.DebugLevel)
+}
+
+// IsInfo implements the hclog.Logger interface.
+func (h *hclogLogger) IsInfo() bool {
+	return h.logger.Core().Enabled(zapcore.InfoLevel)
+}
+
+// IsWarn implements the hclog.Logger interface.
+func (h *hclogLogger) IsWarn() bool {
+	return h.logger.Core().Enabled(zapcore.WarnLevel)
+}
+
+// IsError implements the hclog.Logger interface.
+func (h *hclogLogger) IsError() bool {
+	return h.logger.Core().Enabled(zapcore.ErrorLevel)
+}
+
+// ImpliedArgs implements the hclog.Logger interface.
+func (h *hclogLogger) ImpliedArgs() []interface{} {
+	implied := make([]interface{}, len(h.implied))
+	copy(implied, h.implied)
+	return implied
+}
+
+// With implements the hclog.Logger interface.
+func (h *hclogLogger) With(args ...interface{}) hclog.Logger {
+	if len(args) <= 0 {
+		return h
+	}
+	implied := make([]interface{}, 0, len(h.implied)+len(args))
+	implied = append(append(implied, h.implied...), args...)
+
+	base := h.base.With(hclogFields(args)...)
+	return &hclogLogger{base: base, logger: named(base, h.name), level: h.level, name: h.name, implied: implied}
+}
+
+// Name implements the hclog.Logger interface.
+func (h *hclogLogger) Name() string {
+	return h.name
+}
+
+// Named implements the hclog.Logger interface.
+func (h *hclogLogger) Named(name string) hclog.Logger {
+	if len(h.name) > 0 {
+		name = h.name + "." + name
+	}
+	return h.ResetNamed(name)
+}
+
+// ResetNamed implements the hclog.Logger interface.
+func (h *hclogLogger) ResetNamed(name string) hclog.Logger {
+	return &hclogLogger{base: h.base, logger: named(h.base, name), level: h.level, name: name, implied: h.implied}
+}
+
+// SetLevel implements the hclog.Logger interface.
+func (h *hclogLogger) SetLevel(level hclog.Level) {
+	if h.level == (zap.AtomicLevel{}) {
+		return
+	}
+	if level == hclog.Off {
+		h.level.SetLevel(zapcore.InvalidLevel)
+		return
+	}
+	h.level.SetLevel(zapLevelOf(level))
+}
+
+// GetLevel implements the hclog.Logger interface.
+func (h *hclogLogger) GetLevel() hclog.Level {
+	switch lvl := zapcore.LevelOf(h.logger.Core()); {
+	case lvl <= zapcore.DebugLevel:
+		return hclog.Debug
+	case lvl <= zapcore.InfoLevel:
+		return hclog.Info
+	case lvl <= zapcore.WarnLevel:
+		return hclog.Warn
+	case lvl <= zapcore.FatalLevel:
+		return hclog.Error
+	default:
+		return hclog.Off
+	}
+}
+
+// StandardLogger implements the hclog.Logger interface.
+func (h *hclogLogger) StandardLogger(opts *hclog.StandardLoggerOptions) *stdlog.Logger {
+	// skip log.Logger.output and its exported caller, e.g. log.Logger.Printf
+	return stdlog.New(newWriter(h.writerLogger(), writerOptionsOf(opts), 2), "", 0)
+}
+
+// StandardWriter implements the hclog.Logger interface.
+func (h *hclogLogger) StandardWriter(opts *hclog.StandardLoggerOptions) io.Writer {
+	return NewWriter(h.writerLogger(), writerOptionsOf(opts))
+}
+
+// writerLogger returns the logger without the caller skip of hclogLogger.log.
+func (h *hclogLogger) writerLogger() *zap.Logger {
+	return h.logger.WithOptions(zap.AddCallerSkip(-2))
+}
+
+// named returns l named name, which is relative to the name of l.
+func named(l *zap.Logger, name string) *zap.Logger {
+	if len(name) <= 0 {
+		return l
+	}
+	return l.Named(name)
+}
+
+// writerOptionsOf converts the options of a standard logger of hclog.
+func writerOptionsOf(opts *hclog.StandardLoggerOptions) WriterOptions {
+	if opts == nil {
+		return WriterOptions{}
+	}
+	if opts.ForceLevel != hclog.NoLevel {
+		return WriterOptions{Level: zapLevelOf(opts.ForceLevel)}
+	}
+	return WriterOptions{DetectLevel: opts.InferLevels}
+}
+
+// zapLevelOf converts a hclog level to the zap level it is logged at.
+func zapLevelOf(level hclog.Level) zapcore.Level {
+	switch level {
+	case hclog.Trace, hclog.Debug:
+		return zapcore.DebugLevel
+	case hclog.Warn:
+		return zapcore.WarnLevel
+	case hclog.Error:
+		return zapcore.ErrorLevel
+	default:
+		return zapcore.InfoLevel
+	}
+}
+
+// hclogFields converts hclog arguments, i.e. alternating keys and values, to
+// fields. A value without a key is logged with the key hclog.MissingKey.
+func hclogFields(args []interface{}) []zapcore.Field {
+	fields := make([]zapcore.Field, 0, (len(args)+1)/2)
+	for i := 0; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			fields = append(fields, hclogField(hclog.MissingKey, args[i]))
+			break
+		}
+		key, ok := args[i].(string)
+		if !ok {
+			key = fmt.Sprint(args[i])
+		}
+		fields = append(fields, hclogField(key, args[i+1]))
+	}
+	return fields
+}
+
+// hclogField converts an argument, formatting the special types of hclog like
+// hclog does.
+func hclogField(key string, val interface{}) zapcore.Field {
+	switch v := val.(type) {
+	case hclog.Format:
+		if len(v) <= 0 {
+			return zap.String(key, "")
+		}
+		return zap.String(key, fmt.Sprintf(fmt.Sprint(v[0]), v[1:]...))
+	case hclog.Hex:
+		return zap.String(key, "0x"+strconv.FormatInt(int64(v), 16))
+	case hclog.Octal:
+		return zap.String(key, "0"+strconv.FormatInt(int64(v), 8))
+	case hclog.Binary:
+		return zap.String(key, "0b"+strconv.FormatInt(int64(v), 2))
+	case hclog.Quote:
+		return zap.String(key, strconv.Quote(string(v)))
+	default:
+		return zap.Any(key, val)
+	}
+}
+
+// NewHclogWriter creates a Writer, which parses lines written by a hclog
+// logger with JSON format, e.g. the standard error of a HashiCorp plugin, and
+// logs them with their level, timestamp, module as logger name, caller and
+// arguments as fields. Other lines are logged like by NewWriter.
+func NewHclogWriter(l *zap.Logger, opts WriterOptions) *Writer {
+	w := newWriter(l, opts, 0)
+	w.hclog = true
+	return w
+}
+
+// logHclog logs line, if it has been written by a hclog logger with JSON
+// format, and reports whether it has.
+func (w *Writer) logHclog(line string) bool {
+	if !strings.HasPrefix(line, "{") {
+		return false
+	}
+	dec := json.NewDecoder(bytes.NewReader([]byte(line)))
+	dec.UseNumber()
+	var vals map[string]interface{}
+	if err := dec.Decode(&vals); err != nil {
+		return false
+	}
+	msg, ok := vals["@message"].(string)
+	if !ok {
+		return false
+	}
+
+	ent := zapcore.Entry{Message: msg, Time: time.Now()}
+	if s, ok := vals["@level"].(string); ok {
+		ent.Level = zapLevelOf(hclog.LevelFromString(s))
+	}
+	if s, ok := vals["@timestamp"].(string); ok {
+		if t, err := time.Parse(hclog.TimeFormatJSON, s); err == nil {
+			ent.Time = t
+		} else if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			ent.Time = t
+		}
+	}
+	if s, ok := vals["@module"].(string); ok {
+		ent.LoggerName = s
+	}
+	if s, ok := vals["@caller"].(string); ok {
+		if idx := strings.LastIndexByte(s, ':'); idx > 0 {
+			if line, err := strconv.Atoi(s[idx+1:]); err == nil {
+				ent.Caller = zapcore.EntryCaller{Defined: true, File: s[:idx], Line: line}
+			}
+		}
+	}
+
+	ce := w.logger.Core().Check(ent, nil)
+	if ce == nil {
+		return true
+	}
+
+	keys := make([]string, 0, len(vals))
+	for key := range vals {
+		switch key {
+		case "@message", "@level", "@timestamp", "@module", "@caller":
+		default:
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	fields := make([]zapcore.Field, 0, len(keys))
+	for _, key := range keys {
+		fields = append(fields, jsonField(key, vals[key]))
+	}
+	ce.Write(fields...)
+	return true
+}
+
+// jsonField converts a value decoded by a json.Decoder using numbers.
+func jsonField(key string, val interface{}) zapcore.Field {
+	switch v := val.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return zap.Int64(key, i)
+		}
+		if f, err := v.Float64(); err == nil {
+			return zap.Float64(key, f)
+		}
+		return zap.String(key, v.String())
+	case string:
+		return zap.String(key, v)
+	case bool:
+		return zap.Bool(key, v)
+	default:
+		return zap.Any(key, v)
+	}
+}
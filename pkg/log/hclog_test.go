@@ -0,0 +1,151 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package log_test
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/sobchak-security/klutz/pkg/log"
+)
+
+func TestHclogLogger(t *testing.T) {
+	lvl := zap.NewAtomicLevelAt(zapcore.DebugLevel)
+	core, logs := observer.New(lvl)
+	h := log.NewHclogLogger(zap.New(core, zap.AddCaller()), lvl)
+
+	h.Trace("trace")
+	h.Named("plugin").With("a", 1).Named("rpc").Info("info", "b", hclog.Hex(255), "c", hclog.Fmt("%d%%", 5), "odd")
+	h.With("x", "y").ResetNamed("other").Warn("warn", errors.New("failed"), true)
+	h.SetLevel(hclog.Error)
+	h.Warn("suppressed")
+	h.Log(hclog.Error, "error", "q", hclog.Quote("a\"b"))
+	h.SetLevel(hclog.Off)
+	h.Error("off")
+
+	type entry struct {
+		Level   zapcore.Level
+		Name    string
+		Message string
+		Fields  map[string]interface{}
+	}
+	want := []entry{
+		{zapcore.DebugLevel, "", "trace", map[string]interface{}{}},
+		{zapcore.InfoLevel, "plugin.rpc", "info", map[string]interface{}{
+			"a": int64(1), "b": "0xff", "c": "5%", hclog.MissingKey: "odd",
+		}},
+		{zapcore.WarnLevel, "other", "warn", map[string]interface{}{
+			"x": "y", "failed": true,
+		}},
+		{zapcore.ErrorLevel, "", "error", map[string]interface{}{"q": `"a\"b"`}},
+	}
+
+	var got []entry
+	for _, e := range logs.AllUntimed() {
+		got = append(got, entry{e.Level, e.LoggerName, e.Message, e.ContextMap()})
+		if file := filepath.Base(e.Caller.File); file != "hclog_test.go" {
+			t.Errorf("HclogLogger caller = %s, want hclog_test.go", e.Caller)
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("HclogLogger logged %v, want %v", got, want)
+	}
+
+	if got := h.GetLevel(); got != hclog.Off {
+		t.Errorf("GetLevel() = %v, want %v", got, hclog.Off)
+	}
+	if got := h.With("a", 1).Named("b").ImpliedArgs(); !reflect.DeepEqual(got, []interface{}{"a", 1}) {
+		t.Errorf("ImpliedArgs() = %v, want [a 1]", got)
+	}
+}
+
+func TestHclogLoggerStandardLogger(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	h := log.NewHclogLogger(zap.New(core, zap.AddCaller()), zap.AtomicLevel{})
+
+	h.StandardLogger(&hclog.StandardLoggerOptions{InferLevels: true}).Println("[WARN] inferred")
+	_, _ = h.StandardWriter(&hclog.StandardLoggerOptions{ForceLevel: hclog.Error}).Write([]byte("[WARN] forced\n"))
+	h.SetLevel(hclog.Error)
+
+	want := []struct {
+		lvl zapcore.Level
+		msg string
+	}{{zapcore.WarnLevel, "inferred"}, {zapcore.ErrorLevel, "[WARN] forced"}}
+	got := logs.AllUntimed()
+	if len(got) != len(want) {
+		t.Fatalf("standard logger logged %d entries, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Level != want[i].lvl || got[i].Message != want[i].msg {
+			t.Errorf("standard logger logged %v %q, want %v %q", got[i].Level, got[i].Message, want[i].lvl, want[i].msg)
+		}
+		if file := filepath.Base(got[i].Caller.File); file != "hclog_test.go" {
+			t.Errorf("standard logger caller = %s, want hclog_test.go", got[i].Caller)
+		}
+	}
+	if got := h.GetLevel(); got != hclog.Debug {
+		t.Errorf("GetLevel() = %v, want %v", got, hclog.Debug)
+	}
+}
+
+func TestHclogWriter(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	w := log.NewHclogWriter(zap.New(core), log.WriterOptions{DetectLevel: true})
+
+	plugin := hclog.New(&hclog.LoggerOptions{
+		Name:            "plugin",
+		Level:           hclog.Trace,
+		Output:          w,
+		JSONFormat:      true,
+		IncludeLocation: true,
+	})
+	plugin.Trace("trace")
+	plugin.Named("rpc").Warn("warn", "n", 42, "f", 1.5, "b", true, "s", "v", "m", map[string]int{"k": 1})
+	_, _ = w.Write([]byte("[ERROR] plain text\n{\"no\":\"message\"}\n"))
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	got := logs.All()
+	if len(got) != 4 {
+		t.Fatalf("HclogWriter logged %d entries, want 4", len(got))
+	}
+
+	if e := got[0]; e.Level != zapcore.DebugLevel || e.LoggerName != "plugin" || e.Message != "trace" {
+		t.Errorf("HclogWriter logged %v %q by %q, want debug %q by %q", e.Level, e.Message, e.LoggerName, "trace", "plugin")
+	}
+	if e := got[0]; !e.Caller.Defined || filepath.Base(e.Caller.File) != "hclog_test.go" {
+		t.Errorf("HclogWriter caller = %v, want hclog_test.go", e.Caller)
+	}
+	if d := time.Since(got[0].Time); d < 0 || d > time.Minute || got[0].Time.Nanosecond()%int(time.Microsecond) != 0 {
+		t.Errorf("HclogWriter time = %v, want timestamp of hclog", got[0].Time)
+	}
+
+	e := got[1]
+	if e.Level != zapcore.WarnLevel || e.LoggerName != "plugin.rpc" || e.Message != "warn" {
+		t.Errorf("HclogWriter logged %v %q by %q, want warn %q by %q", e.Level, e.Message, e.LoggerName, "warn", "plugin.rpc")
+	}
+	wantFields := map[string]interface{}{
+		"n": int64(42), "f": 1.5, "b": true, "s": "v", "m": map[string]interface{}{"k": json.Number("1")},
+	}
+	if got := e.ContextMap(); !reflect.DeepEqual(got, wantFields) {
+		t.Errorf("HclogWriter fields = %v, want %v", got, wantFields)
+	}
+
+	if e := got[2]; e.Level != zapcore.ErrorLevel || e.Message != "plain text" {
+		t.Errorf("HclogWriter logged %v %q, want error %q", e.Level, e.Message, "plain text")
+	}
+	if e := got[3]; e.Level != zapcore.InfoLevel || e.Message != `{"no":"message"}` {
+		t.Errorf("HclogWriter logged %v %q, want info %q", e.Level, e.Message, `{"no":"message"}`)
+	}
+}
@@ -0,0 +1,221 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// LevelManager maintains the levels of named loggers, cf. zap.Logger.Named. A
+// logger without a level of its own inherits the level of its closest named
+// ancestor, e.g. "db.pool" inherits from "db", or the default level.
+//
+// The manager controls a logger by wrapping its core, e.g. with
+// zap.WrapCore(m.Wrap), and by keeping the atomic level of the core, which
+// has been passed to NewLevelManager, at the most verbose level of all
+// loggers. Hence, that atomic level must not be changed directly anymore.
+type LevelManager struct {
+	mu        sync.RWMutex
+	floor     zap.AtomicLevel
+	level     zapcore.Level
+	overrides map[string]zapcore.Level
+}
+
+// NewLevelManager creates a LevelManager, whose default level is the current
+// level of floor, i.e. the atomic level of the core to be wrapped.
+func NewLevelManager(floor zap.AtomicLevel) *LevelManager {
+	return &LevelManager{
+		floor:     floor,
+		level:     floor.Level(),
+		overrides: map[string]zapcore.Level{},
+	}
+}
+
+// DefaultLevel returns the level of loggers without a level of their own.
+func (m *LevelManager) DefaultLevel() zapcore.Level {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.level
+}
+
+// SetDefaultLevel changes the level of loggers without a level of their own.
+func (m *LevelManager) SetDefaultLevel(lvl zapcore.Level) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.level = lvl
+	m.updateFloor()
+}
+
+// SetLevel overrides the level of the logger name and its descendants.
+func (m *LevelManager) SetLevel(name string, lvl zapcore.Level) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.overrides[name] = lvl
+	m.updateFloor()
+}
+
+// UnsetLevel removes the level override of the logger name.
+func (m *LevelManager) UnsetLevel(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.overrides, name)
+	m.updateFloor()
+}
+
+// Levels returns a copy of all level overrides.
+func (m *LevelManager) Levels() map[string]zapcore.Level {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	levels := make(map[string]zapcore.Level, len(m.overrides))
+	for name, lvl := range m.overrides {
+		levels[name] = lvl
+	}
+	return levels
+}
+
+// LevelOf returns the effective level of the logger name.
+func (m *LevelManager) LevelOf(name string) zapcore.Level {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for len(name) > 0 {
+		if lvl, ok := m.overrides[name]; ok {
+			return lvl
+		}
+		idx := strings.LastIndexByte(name, '.')
+		if idx < 0 {
+			break
+		}
+		name = name[:idx]
+	}
+	return m.level
+}
+
+// Wrap wraps core, so that entries are filtered by the level of their logger.
+func (m *LevelManager) Wrap(core zapcore.Core) zapcore.Core {
+	return &levelCore{Core: core, m: m}
+}
+
+// updateFloor sets the floor to the most verbose level of all loggers; callers
+// must hold the write lock.
+func (m *LevelManager) updateFloor() {
+	min := m.level
+	for _, lvl := range m.overrides {
+		if lvl < min {
+			min = lvl
+		}
+	}
+	m.floor.SetLevel(min)
+}
+
+// levelPayload is the JSON representation of the levels served by
+// LevelManager.ServeHTTP.
+type levelPayload struct {
+	Logger  string            `json:"logger,omitempty"`
+	Level   string            `json:"level,omitempty"`
+	Loggers map[string]string `json:"loggers,omitempty"`
+	Error   string            `json:"error,omitempty"`
+}
+
+// ServeHTTP implements the http.Handler interface. GET requests return the
+// default level and all overrides, e.g.
+//
+//	{"level":"info","loggers":{"db":"debug"}}
+//
+// PUT requests change the default level, e.g. {"level":"warn"}, or the level
+// of a logger, e.g. {"logger":"db","level":"debug"}. DELETE requests remove
+// the level override of the logger given by the query parameter logger. Valid
+// levels are listed by Levels.
+func (m *LevelManager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var req levelPayload
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeLevelJSON(w, http.StatusBadRequest, levelPayload{
+				Error: fmt.Sprintf("decoding request failed - %v", err),
+			})
+			return
+		}
+		lvl, err := parseManagedLevel(req.Level)
+		if err != nil {
+			writeLevelJSON(w, http.StatusBadRequest, levelPayload{Error: err.Error()})
+			return
+		}
+		if len(req.Logger) > 0 {
+			m.SetLevel(req.Logger, lvl)
+		} else {
+			m.SetDefaultLevel(lvl)
+		}
+	case http.MethodDelete:
+		name := r.URL.Query().Get("logger")
+		if len(name) <= 0 {
+			writeLevelJSON(w, http.StatusBadRequest, levelPayload{Error: "missing query parameter logger"})
+			return
+		}
+		m.UnsetLevel(name)
+	default:
+		w.Header().Set("Allow", strings.Join([]string{http.MethodGet, http.MethodPut, http.MethodDelete}, ", "))
+		writeLevelJSON(w, http.StatusMethodNotAllowed, levelPayload{
+			Error: fmt.Sprintf("method %s not allowed", r.Method),
+		})
+		return
+	}
+
+	resp := levelPayload{
+		Level:   m.DefaultLevel().String(),
+		Loggers: map[string]string{},
+	}
+	for name, lvl := range m.Levels() {
+		resp.Loggers[name] = lvl.String()
+	}
+	writeLevelJSON(w, http.StatusOK, resp)
+}
+
+func writeLevelJSON(w http.ResponseWriter, status int, payload levelPayload) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(payload)
+}
+
+// parseManagedLevel parses s, which has to be one of Levels.
+func parseManagedLevel(s string) (zapcore.Level, error) {
+	for _, name := range Levels {
+		if strings.EqualFold(s, name) {
+			return zapcore.ParseLevel(name)
+		}
+	}
+	return zapcore.InvalidLevel, fmt.Errorf("invalid level %q, valid levels are %q", s, Levels)
+}
+
+// levelCore filters entries by the level of their logger.
+type levelCore struct {
+	zapcore.Core
+	m *LevelManager
+}
+
+// With implements the zapcore.Core interface.
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), m: c.m}
+}
+
+// Check implements the zapcore.Core interface.
+func (c *levelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if ent.Level < c.m.LevelOf(ent.LoggerName) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
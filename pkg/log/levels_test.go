@@ -0,0 +1,151 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package log_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/sobchak-security/klutz/pkg/log"
+)
+
+func TestLevelManager(t *testing.T) {
+	floor := zap.NewAtomicLevelAt(zap.InfoLevel)
+	m := log.NewLevelManager(floor)
+
+	core, logs := observer.New(floor)
+	root := zap.New(core, zap.WrapCore(m.Wrap))
+	db := root.Named("db")
+	pool := db.Named("pool")
+	web := root.Named("http")
+
+	m.SetLevel("db", zap.DebugLevel)
+	m.SetLevel("http", zap.ErrorLevel)
+
+	if got := floor.Level(); got != zap.DebugLevel {
+		t.Errorf("SetLevel() [floor]: %v, want %v", got, zap.DebugLevel)
+	}
+
+	root.Debug("root debug")
+	root.Info("root info")
+	db.Debug("db debug")
+	pool.With(zap.Int("size", 3)).Debug("pool debug")
+	web.Warn("http warn")
+	web.Error("http error")
+
+	m.UnsetLevel("db")
+	if got := floor.Level(); got != zap.InfoLevel {
+		t.Errorf("UnsetLevel() [floor]: %v, want %v", got, zap.InfoLevel)
+	}
+	pool.Debug("pool debug after unset")
+	pool.Info("pool info after unset")
+
+	var got []string
+	for _, e := range logs.AllUntimed() {
+		got = append(got, e.Message)
+	}
+	want := []string{"root info", "db debug", "pool debug", "http error", "pool info after unset"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("LevelManager: logged %q, want %q", got, want)
+	}
+}
+
+func TestLevelManagerServeHTTP(t *testing.T) {
+	m := log.NewLevelManager(zap.NewAtomicLevelAt(zap.InfoLevel))
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "success: get",
+			method:     http.MethodGet,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"level":"info"}`,
+		},
+		{
+			name:       "success: put logger level",
+			method:     http.MethodPut,
+			body:       `{"logger":"db","level":"debug"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"level":"info","loggers":{"db":"debug"}}`,
+		},
+		{
+			name:       "success: put default level",
+			method:     http.MethodPut,
+			body:       `{"level":"WARN"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"level":"warn","loggers":{"db":"debug"}}`,
+		},
+		{
+			name:       "success: delete logger level",
+			method:     http.MethodDelete,
+			target:     "/?logger=db",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"level":"warn"}`,
+		},
+		{
+			name:       "failure: level not listed",
+			method:     http.MethodPut,
+			body:       `{"level":"panic"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "failure: invalid JSON",
+			method:     http.MethodPut,
+			body:       `{"level":`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "failure: delete without logger",
+			method:     http.MethodDelete,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "failure: method not allowed",
+			method:     http.MethodPost,
+			body:       `{"level":"info"}`,
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := tt.target
+			if len(target) <= 0 {
+				target = "/"
+			}
+			rec := httptest.NewRecorder()
+			m.ServeHTTP(rec, httptest.NewRequest(tt.method, target, strings.NewReader(tt.body)))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("ServeHTTP() status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if len(tt.wantBody) <= 0 {
+				var payload map[string]interface{}
+				if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil || payload["error"] == nil {
+					t.Errorf("ServeHTTP() body = %q, want error", rec.Body.String())
+				}
+				return
+			}
+			if got := strings.TrimSpace(rec.Body.String()); got != tt.wantBody {
+				t.Errorf("ServeHTTP() body = %s, want %s", got, tt.wantBody)
+			}
+		})
+	}
+
+	if got := m.LevelOf("db.pool"); got != zapcore.WarnLevel {
+		t.Errorf("LevelOf(): %v, want %v", got, zapcore.WarnLevel)
+	}
+}
@@ -17,6 +17,30 @@ const (
 )
 
 var (
+	// timeLayouts maps the names of layout presets to the layouts of package
+	// time.
+	timeLayouts = map[string]string{
+		"Layout":      time.Layout,
+		"ANSIC":       time.ANSIC,
+		"UnixDate":    time.UnixDate,
+		"RubyDate":    time.RubyDate,
+		"RFC822":      time.RFC822,
+		"RFC822Z":     time.RFC822Z,
+		"RFC850":      time.RFC850,
+		"RFC1123":     time.RFC1123,
+		"RFC1123Z":    time.RFC1123Z,
+		"RFC3339":     time.RFC3339,
+		"RFC3339Nano": time.RFC3339Nano,
+		"Kitchen":     time.Kitchen,
+		"Stamp":       time.Stamp,
+		"StampMilli":  time.StampMilli,
+		"StampMicro":  time.StampMicro,
+		"StampNano":   time.StampNano,
+		"DateTime":    time.DateTime,
+		"DateOnly":    time.DateOnly,
+		"TimeOnly":    time.TimeOnly,
+	}
+
 	// Levels provide a convenient way to list all supported log level strings.
 	Levels = []string{
 		// omitting DPanic and Panic
@@ -39,9 +63,42 @@ func EpochShortTimeEncoder(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
 	enc.AppendString(t.Format("06-01-02 15:04:05"))
 }
 
-// DevConfig returns a logger and atomic log level aimed at development
-// environments with a highly opinionated configuration.
-// NOTE this factory function panics if an error occurs.
+// TimeLayout returns the layout of the preset name, e.g. "RFC3339Nano" or
+// "Kitchen", cf. the layout constants of package time. Any other name is
+// considered to be a layout itself and returned unchanged.
+func TimeLayout(name string) string {
+	if layout, ok := timeLayouts[name]; ok {
+		return layout
+	}
+	return name
+}
+
+// LayoutTimeEncoder returns a time encoder, which serializes a time.Time with
+// the given layout (or preset, cf. TimeLayout) in the time zone loc. If loc is
+// nil, the time zone of the time.Time is retained.
+func LayoutTimeEncoder(layout string, loc *time.Location) zapcore.TimeEncoder {
+	layout = TimeLayout(layout)
+	return func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+		if loc != nil {
+			t = t.In(loc)
+		}
+		enc.AppendString(t.Format(layout))
+	}
+}
+
+// TimeEncoderIn returns a time encoder, which converts a time.Time to the time
+// zone loc before serializing it with enc.
+func TimeEncoderIn(enc zapcore.TimeEncoder, loc *time.Location) zapcore.TimeEncoder {
+	if loc == nil {
+		return enc
+	}
+	return func(t time.Time, pae zapcore.PrimitiveArrayEncoder) {
+		enc(t.In(loc), pae)
+	}
+}
+
+// DevConfig returns a configuration aimed at development environments with a
+// highly opinionated configuration, cf. NewDev.
 func DevConfig() zap.Config {
 	cfg := zap.NewDevelopmentConfig()
 	cfg.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
@@ -49,9 +106,8 @@ func DevConfig() zap.Config {
 	return cfg
 }
 
-// StdConfig returns a logger and atomic log level aimed at development
-// environments with a highly opinionated configuration.
-// NOTE this factory function panics if an error occurs.
+// StdConfig returns a configuration aimed at console output in standard
+// environments with a highly opinionated configuration, cf. NewStd.
 func StdConfig() zap.Config {
 	cfg := zap.NewProductionConfig()
 	cfg.Encoding = "console"
@@ -63,7 +119,20 @@ func StdConfig() zap.Config {
 	return cfg
 }
 
+// NewDev builds a logger configured by DevConfig, cf. New.
+func NewDev(opts ...zap.Option) (*zap.Logger, zap.AtomicLevel, func() error, error) {
+	return New(Config{Config: DevConfig()}, opts...)
+}
+
+// NewStd builds a logger configured by StdConfig, cf. New.
+func NewStd(opts ...zap.Option) (*zap.Logger, zap.AtomicLevel, func() error, error) {
+	return New(Config{Config: StdConfig()}, opts...)
+}
+
 // LogWriterWrapper is a simple io.Writer wrapper of zap's Logger.
+//
+// Deprecated: LogWriterWrapper logs each call of Write as a message of its own
+// at the minimum enabled level of the logger; use NewWriter instead.
 type LogWriterWrapper zap.Logger
 
 // Write implements the io.Writer interface.
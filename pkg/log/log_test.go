@@ -8,9 +8,11 @@ import (
 	"os"
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/sobchak-security/klutz/pkg/log"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 func TestAdHoc(t *testing.T) {
@@ -154,3 +156,52 @@ func TestLogWriterWrapperConfig(t *testing.T) {
 
 	// t.Error("intentional")
 }
+
+func TestLayoutTimeEncoder(t *testing.T) {
+	ts := time.Date(2023, 4, 21, 9, 41, 58, 212000000, time.FixedZone("CEST", 2*60*60))
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		enc  zapcore.TimeEncoder
+		want string
+	}{
+		{
+			name: "success: preset retaining time zone",
+			enc:  log.LayoutTimeEncoder("Kitchen", nil),
+			want: "9:41AM",
+		},
+		{
+			name: "success: preset in UTC",
+			enc:  log.LayoutTimeEncoder("StampMilli", time.UTC),
+			want: "Apr 21 07:41:58.212",
+		},
+		{
+			name: "success: custom layout in named time zone",
+			enc:  log.LayoutTimeEncoder("2006-01-02 15:04 MST", ny),
+			want: "2023-04-21 03:41 EDT",
+		},
+		{
+			name: "success: named encoder in UTC",
+			enc:  log.TimeEncoderIn(zapcore.ISO8601TimeEncoder, time.UTC),
+			want: "2023-04-21T07:41:58.212Z",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc := zapcore.NewMapObjectEncoder()
+			if err := enc.AddArray("t", zapcore.ArrayMarshalerFunc(func(ae zapcore.ArrayEncoder) error {
+				tt.enc(ts, ae)
+				return nil
+			})); err != nil {
+				t.Fatal(err)
+			}
+			if got := enc.Fields["t"].([]interface{})[0]; got != tt.want {
+				t.Errorf("LayoutTimeEncoder(): %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
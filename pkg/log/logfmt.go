@@ -0,0 +1,184 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package log
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// LogfmtEncoding is the name of the logfmt encoding, cf. NewLogfmtEncoder.
+const LogfmtEncoding = "logfmt"
+
+func init() {
+	if err := RegisterEncoding(LogfmtEncoding, func(ec zapcore.EncoderConfig) (zapcore.Encoder, error) {
+		return NewLogfmtEncoder(ec), nil
+	}); err != nil {
+		panic(fmt.Sprintf("registering encoding %q failed - %v", LogfmtEncoding, err))
+	}
+}
+
+// logfmtEncoder is a zapcore.Encoder producing logfmt lines.
+type logfmtEncoder struct {
+	*flatEncoder
+	ec zapcore.EncoderConfig
+}
+
+// NewLogfmtEncoder creates a logfmt encoder, which writes entries as key=value
+// pairs, e.g.
+//
+//	ts=2023-01-02T03:04:05Z level=info msg="request served" http.status=200
+//
+// The keys and encoders of ec are respected like by zap's JSON encoder; keys
+// of nested objects are joined by dots. Values are quoted, if they are empty
+// or contain spaces, equal signs, quotes or non-printable characters.
+func NewLogfmtEncoder(ec zapcore.EncoderConfig) zapcore.Encoder {
+	e := &logfmtEncoder{ec: ec}
+	e.flatEncoder = newFlatEncoder(&e.ec, ".")
+	return e
+}
+
+// Clone implements the zapcore.Encoder interface.
+func (e *logfmtEncoder) Clone() zapcore.Encoder {
+	return e.clone()
+}
+
+func (e *logfmtEncoder) clone() *logfmtEncoder {
+	c := &logfmtEncoder{ec: e.ec}
+	c.flatEncoder = e.flatEncoder.clone()
+	c.flatEncoder.ec = &c.ec
+	return c
+}
+
+// EncodeEntry implements the zapcore.Encoder interface.
+func (e *logfmtEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	buf := bufferPool.Get()
+
+	if len(e.ec.TimeKey) > 0 && !ent.Time.IsZero() {
+		value := ent.Time.Format("2006-01-02T15:04:05.000Z0700")
+		if e.ec.EncodeTime != nil {
+			if s, ok := encodeText(func(enc zapcore.PrimitiveArrayEncoder) { e.ec.EncodeTime(ent.Time, enc) }); ok {
+				value = s
+			}
+		}
+		appendLogfmtPair(buf, e.ec.TimeKey, value)
+	}
+	if len(e.ec.LevelKey) > 0 {
+		value := ent.Level.String()
+		if e.ec.EncodeLevel != nil {
+			if s, ok := encodeText(func(enc zapcore.PrimitiveArrayEncoder) { e.ec.EncodeLevel(ent.Level, enc) }); ok {
+				value = s
+			}
+		}
+		appendLogfmtPair(buf, e.ec.LevelKey, value)
+	}
+	if len(e.ec.NameKey) > 0 && len(ent.LoggerName) > 0 {
+		value := ent.LoggerName
+		if e.ec.EncodeName != nil {
+			if s, ok := encodeText(func(enc zapcore.PrimitiveArrayEncoder) { e.ec.EncodeName(ent.LoggerName, enc) }); ok {
+				value = s
+			}
+		}
+		appendLogfmtPair(buf, e.ec.NameKey, value)
+	}
+	if ent.Caller.Defined {
+		if len(e.ec.CallerKey) > 0 {
+			value := ent.Caller.String()
+			if e.ec.EncodeCaller != nil {
+				if s, ok := encodeText(func(enc zapcore.PrimitiveArrayEncoder) { e.ec.EncodeCaller(ent.Caller, enc) }); ok {
+					value = s
+				}
+			}
+			appendLogfmtPair(buf, e.ec.CallerKey, value)
+		}
+		if len(e.ec.FunctionKey) > 0 {
+			appendLogfmtPair(buf, e.ec.FunctionKey, ent.Caller.Function)
+		}
+	}
+	if len(e.ec.MessageKey) > 0 {
+		appendLogfmtPair(buf, e.ec.MessageKey, ent.Message)
+	}
+
+	final := e.clone()
+	for _, f := range fields {
+		f.AddTo(final)
+	}
+	for _, f := range final.fields {
+		appendLogfmtPair(buf, f.key, f.value)
+	}
+
+	if len(ent.Stack) > 0 && len(e.ec.StacktraceKey) > 0 {
+		appendLogfmtPair(buf, e.ec.StacktraceKey, ent.Stack)
+	}
+
+	if !e.ec.SkipLineEnding {
+		if len(e.ec.LineEnding) > 0 {
+			buf.AppendString(e.ec.LineEnding)
+		} else {
+			buf.AppendString(zapcore.DefaultLineEnding)
+		}
+	}
+	return buf, nil
+}
+
+// appendLogfmtPair appends key and value separated by an equal sign; spaces,
+// equal signs, quotes and non-printable characters in keys are replaced by
+// underscores.
+func appendLogfmtPair(buf *buffer.Buffer, key, value string) {
+	if buf.Len() > 0 {
+		buf.AppendByte(' ')
+	}
+	if len(key) <= 0 {
+		key = "_"
+	}
+	for _, r := range key {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError || !unicode.IsPrint(r) {
+			r = '_'
+		}
+		buf.AppendString(string(r))
+	}
+	buf.AppendByte('=')
+	appendLogfmtValue(buf, value)
+}
+
+// appendLogfmtValue appends value, quoted and escaped, if necessary.
+func appendLogfmtValue(buf *buffer.Buffer, value string) {
+	needsQuotes := len(value) <= 0
+	for _, r := range value {
+		if r <= ' ' || r == '=' || r == '"' || r == '\\' || r == utf8.RuneError || !unicode.IsPrint(r) {
+			needsQuotes = true
+			break
+		}
+	}
+	if !needsQuotes {
+		buf.AppendString(value)
+		return
+	}
+
+	buf.AppendByte('"')
+	for _, r := range value {
+		switch r {
+		case '"', '\\':
+			buf.AppendByte('\\')
+			buf.AppendString(string(r))
+		case '\n':
+			buf.AppendString(`\n`)
+		case '\r':
+			buf.AppendString(`\r`)
+		case '\t':
+			buf.AppendString(`\t`)
+		default:
+			if r != ' ' && (r == utf8.RuneError || !unicode.IsPrint(r)) {
+				buf.AppendString(fmt.Sprintf(`\u%04x`, r))
+			} else {
+				buf.AppendString(string(r))
+			}
+		}
+	}
+	buf.AppendByte('"')
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package log_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/sobchak-security/klutz/pkg/log"
+)
+
+func TestLogfmtEncoder(t *testing.T) {
+	ent := zapcore.Entry{
+		Level:      zapcore.InfoLevel,
+		Time:       time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC),
+		LoggerName: "http",
+		Message:    "request served",
+		Caller:     zapcore.NewEntryCaller(0, "/src/pkg/http/server.go", 7, true),
+	}
+	ec := zapcore.EncoderConfig{
+		TimeKey:       "ts",
+		LevelKey:      "lvl",
+		NameKey:       "logger",
+		CallerKey:     "caller",
+		MessageKey:    "msg",
+		StacktraceKey: "stack",
+		EncodeTime:    zapcore.ISO8601TimeEncoder,
+		EncodeLevel:   zapcore.CapitalLevelEncoder,
+		EncodeCaller:  zapcore.ShortCallerEncoder,
+	}
+
+	tests := []struct {
+		name   string
+		ec     zapcore.EncoderConfig
+		fields []zap.Field
+		want   string
+	}{
+		{
+			name: "success: entry keys",
+			ec:   ec,
+			want: `ts=2023-01-02T03:04:05.000Z lvl=INFO logger=http caller=http/server.go:7 msg="request served"` + "\n",
+		},
+		{
+			name: "success: quoting and nested objects",
+			ec:   zapcore.EncoderConfig{MessageKey: "msg", SkipLineEnding: true},
+			fields: []zap.Field{
+				zap.Int("status", 200),
+				zap.String("empty", ""),
+				zap.String("query", `a=b "c"`),
+				zap.String("multi", "line\none"),
+				zap.String("path\\", `C:\tmp`),
+				zap.Bool("ok", true),
+				zap.Object("a", testObject{}),
+				zap.Error(errors.New("failed")),
+			},
+			want: `msg="request served" status=200 empty="" query="a=b \"c\"" multi="line\none" ` +
+				`path\=` + `"C:\\tmp" ok=true a.b=c a.d=1 error=failed`,
+		},
+		{
+			name:   "success: custom time encoder",
+			ec:     zapcore.EncoderConfig{TimeKey: "T", EncodeTime: log.LayoutTimeEncoder(time.Kitchen, nil), SkipLineEnding: true},
+			fields: []zap.Field{zap.Time("at", time.Date(2023, 1, 2, 15, 4, 0, 0, time.UTC))},
+			want:   `T=3:04AM at=3:04PM`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf, err := log.NewLogfmtEncoder(tt.ec).EncodeEntry(ent, tt.fields)
+			if err != nil {
+				t.Fatalf("EncodeEntry() error = %v", err)
+			}
+			defer buf.Free()
+
+			if got := buf.String(); got != tt.want {
+				t.Errorf("EncodeEntry(): %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogfmtEncodingHCL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+
+	hf, diags := hclparse.NewParser().ParseHCL([]byte(`
+level        = "info"
+encoding     = "logfmt"
+output_paths = ["`+path+`"]
+encoder_config {
+  message_key  = "message"
+  level_key    = "severity"
+  time_key     = "time"
+  time_layout  = "DateTime"
+  time_zone    = "UTC"
+  name_key     = "logger"
+}`), "logfmt.hcl")
+	if diags.HasErrors() {
+		t.Fatalf("ParseHCL() error = %v", diags)
+	}
+	var cfg zap.Config
+	if err := (*log.ConfigWrapper)(&cfg).UnmarshalHCL(nil, hf.Body); err != nil {
+		t.Fatalf("UnmarshalHCL() error = %v", err)
+	}
+	l, err := cfg.Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	l.Named("db").With(zap.String("user", "alice bob")).Warn("slow", zap.Duration("took", time.Second))
+	_ = l.Sync()
+
+	testMatchLog(t, testReadLog(t, path),
+		`^time="\d{4}-\d\d-\d\d \d\d:\d\d:\d\d" severity=warn logger=db message=slow user="alice bob" took=1\n$`)
+}
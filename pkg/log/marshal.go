@@ -0,0 +1,470 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/gocty"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// MarshalHCL renders the configuration as canonical HCL, as accepted by
+// UnmarshalHCL: attributes with zero values are omitted, rotating file sinks
+// are rendered as sink blocks, and encoders by their registered names, e.g.
+// "int64seconds", and the time layout and zone as time_layout and time_zone
+// attributes. Configurations with encoders or sampling hooks, which have not
+// been registered, cannot be marshaled; neither can those with closures, e.g.
+// the method value SamplingCounter.Hook or time encoders created by
+// LayoutTimeEncoder, even if registered, as they cannot be told apart.
+//
+// The hash key of the redaction is never rendered, as it must not be stored
+// together with the configuration, cf. RedactConfig.HashKey; configurations
+// hashing redacted values hence have to be completed, e.g. by OverlayHCL.
+func (c *Config) MarshalHCL() ([]byte, error) {
+	ch, err := newConfigHCL(*c)
+	if err != nil {
+		return nil, fmt.Errorf("MarshalHCL(): converting configuration failed - %w", err)
+	}
+
+	f := hclwrite.NewEmptyFile()
+	if err := writeHCLBody(f.Body(), reflect.ValueOf(ch)); err != nil {
+		return nil, fmt.Errorf("MarshalHCL(): writing configuration failed - %w", err)
+	}
+	return hclwrite.Format(f.Bytes()), nil
+}
+
+// MarshalMap renders the configuration as map, as accepted by UnmarshalMap,
+// i.e. with the keys of zap's JSON configuration and the enhancements of this
+// package, e.g. encoders by their registered names, cf. MarshalHCL. The
+// sampling tick and hook cannot be represented, and the hash key of the
+// redaction is never rendered.
+func (c *Config) MarshalMap() (map[string]interface{}, error) {
+	names, err := encoderNames(c.EncoderConfig, c.TimeLayout, c.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("MarshalMap(): %w", err)
+	}
+
+	ec := map[string]interface{}{
+		"messageKey":       c.EncoderConfig.MessageKey,
+		"levelKey":         c.EncoderConfig.LevelKey,
+		"timeKey":          c.EncoderConfig.TimeKey,
+		"nameKey":          c.EncoderConfig.NameKey,
+		"callerKey":        c.EncoderConfig.CallerKey,
+		"functionKey":      c.EncoderConfig.FunctionKey,
+		"stacktraceKey":    c.EncoderConfig.StacktraceKey,
+		"skipLineEnding":   c.EncoderConfig.SkipLineEnding,
+		"lineEnding":       c.EncoderConfig.LineEnding,
+		"consoleSeparator": c.EncoderConfig.ConsoleSeparator,
+	}
+	if len(names.timeLayout) > 0 || len(names.timeZone) > 0 {
+		te := map[string]interface{}{}
+		for key, v := range map[string]string{
+			"name":     names.time,
+			"layout":   names.timeLayout,
+			"timeZone": names.timeZone,
+		} {
+			if len(v) > 0 {
+				te[key] = v
+			}
+		}
+		ec["timeEncoder"] = te
+	} else if len(names.time) > 0 {
+		ec["timeEncoder"] = names.time
+	}
+	for key, name := range map[string]string{
+		"levelEncoder":    names.level,
+		"durationEncoder": names.duration,
+		"callerEncoder":   names.caller,
+		"nameEncoder":     names.name,
+		"syslogFacility":  c.Syslog.Facility,
+		"syslogAppName":   c.Syslog.AppName,
+		"syslogHostname":  c.Syslog.Hostname,
+		"syslogFormat":    c.Syslog.Format,
+	} {
+		if len(name) > 0 {
+			ec[key] = name
+		}
+	}
+
+	m := map[string]interface{}{
+		"development":       c.Development,
+		"disableCaller":     c.DisableCaller,
+		"disableStacktrace": c.DisableStacktrace,
+		"encoding":          c.Encoding,
+		"encoderConfig":     ec,
+	}
+	if c.Level != (zap.AtomicLevel{}) {
+		m["level"] = c.Level.String()
+	}
+	if c.Sampling != nil {
+		m["sampling"] = map[string]interface{}{
+			"initial":    c.Sampling.Initial,
+			"thereafter": c.Sampling.Thereafter,
+		}
+	}
+	if c.OutputPaths != nil {
+		m["outputPaths"] = append([]string{}, c.OutputPaths...)
+	}
+	if c.ErrorOutputPaths != nil {
+		m["errorOutputPaths"] = append([]string{}, c.ErrorOutputPaths...)
+	}
+	if c.InitialFields != nil {
+		fields := make(map[string]interface{}, len(c.InitialFields))
+		for k, v := range c.InitialFields {
+			fields[k] = v
+		}
+		m["initialFields"] = fields
+	}
+	if redact := c.Redact; redact != nil {
+		rc := map[string]interface{}{}
+		for key, v := range map[string][]string{
+			"keys":    redact.Keys,
+			"values":  redact.Values,
+			"presets": redact.Presets,
+		} {
+			if len(v) > 0 {
+				rc[key] = append([]string{}, v...)
+			}
+		}
+		for key, v := range map[string]string{
+			"action":      redact.Action,
+			"replacement": redact.Replacement,
+		} {
+			if len(v) > 0 {
+				rc[key] = v
+			}
+		}
+		m["redact"] = rc
+	}
+	return m, nil
+}
+
+// MarshalJSON implements the json.Marshaler interface, cf. MarshalMap.
+func (c *Config) MarshalJSON() ([]byte, error) {
+	m, err := c.MarshalMap()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(m)
+}
+
+// MarshalHCL renders the configuration as canonical HCL, cf.
+// Config.MarshalHCL; time layouts and zones applied by ConfigWrapper are
+// closures and cannot be marshaled.
+func (cw *ConfigWrapper) MarshalHCL() ([]byte, error) {
+	cfg := cw.config()
+	return cfg.MarshalHCL()
+}
+
+// MarshalMap renders the configuration as map, cf. Config.MarshalMap and
+// MarshalHCL.
+func (cw *ConfigWrapper) MarshalMap() (map[string]interface{}, error) {
+	cfg := cw.config()
+	return cfg.MarshalMap()
+}
+
+// MarshalJSON implements the json.Marshaler interface, cf. MarshalMap.
+func (cw *ConfigWrapper) MarshalJSON() ([]byte, error) {
+	cfg := cw.config()
+	return cfg.MarshalJSON()
+}
+
+// newConfigHCL converts c to its HCL-compatible representation, the inverse
+// of configHCL.initConfig.
+func newConfigHCL(c Config) (configHCL, error) {
+	zc := c.Config
+	names, err := encoderNames(zc.EncoderConfig, c.TimeLayout, c.TimeZone)
+	if err != nil {
+		return configHCL{}, err
+	}
+
+	ch := configHCL{
+		Encoding:          zc.Encoding,
+		ErrorOutputPaths:  zc.ErrorOutputPaths,
+		Development:       zc.Development,
+		DisableCaller:     zc.DisableCaller,
+		DisableStacktrace: zc.DisableStacktrace,
+		EncoderConfig: &encoderConfigHCL{
+			MessageKey:       zc.EncoderConfig.MessageKey,
+			LevelKey:         zc.EncoderConfig.LevelKey,
+			TimeKey:          zc.EncoderConfig.TimeKey,
+			NameKey:          zc.EncoderConfig.NameKey,
+			CallerKey:        zc.EncoderConfig.CallerKey,
+			FunctionKey:      zc.EncoderConfig.FunctionKey,
+			StacktraceKey:    zc.EncoderConfig.StacktraceKey,
+			LineEnding:       zc.EncoderConfig.LineEnding,
+			EncodeLevel:      names.level,
+			EncodeTime:       names.time,
+			TimeLayout:       names.timeLayout,
+			TimeZone:         names.timeZone,
+			EncodeDuration:   names.duration,
+			EncodeCaller:     names.caller,
+			EncodeName:       names.name,
+			ConsoleSeparator: zc.EncoderConfig.ConsoleSeparator,
+			SkipLineEnding:   zc.EncoderConfig.SkipLineEnding,
+			SyslogFacility:   c.Syslog.Facility,
+			SyslogAppName:    c.Syslog.AppName,
+			SyslogHostname:   c.Syslog.Hostname,
+			SyslogFormat:     c.Syslog.Format,
+		},
+	}
+	if zc.Level != (zap.AtomicLevel{}) {
+		ch.Level = zc.Level.String()
+	}
+
+	for _, path := range zc.OutputPaths {
+		u, err := url.Parse(path)
+		if err != nil || u.Scheme != RotateScheme {
+			ch.OutputPaths = append(ch.OutputPaths, path)
+			continue
+		}
+		path, opts, err := parseRotateURL(u)
+		if err != nil {
+			return configHCL{}, err
+		}
+		ch.Sinks = append(ch.Sinks, sinkConfigHCL{
+			Path:           path,
+			MaxSize:        opts.MaxSize,
+			MaxAge:         opts.MaxAge,
+			MaxBackups:     opts.MaxBackups,
+			Compress:       opts.Compress,
+			LocalTime:      opts.LocalTime,
+			RotateOnSIGHUP: opts.RotateOnSIGHUP,
+		})
+	}
+
+	if len(zc.InitialFields) > 0 {
+		ch.InitialFields = goToCty(zc.InitialFields)
+	}
+
+	if zc.Sampling != nil {
+		ch.Sampling = &samplingConfigHCL{
+			Initial:    zc.Sampling.Initial,
+			Thereafter: zc.Sampling.Thereafter,
+		}
+		if tick := c.SamplingTick; tick != 0 && tick != time.Second {
+			ch.Sampling.Tick = tick.String()
+		}
+		if zc.Sampling.Hook != nil {
+			name, ok := samplingHooks.nameOf(zc.Sampling.Hook)
+			if !ok {
+				return configHCL{}, fmt.Errorf("unregistered %s", samplingHooks.kind)
+			}
+			ch.Sampling.Hook = name
+		}
+	}
+
+	if redact := c.Redact; redact != nil {
+		ch.Redact = &redactConfigHCL{
+			Keys:        redact.Keys,
+			Values:      redact.Values,
+			Presets:     redact.Presets,
+			Action:      redact.Action,
+			Replacement: redact.Replacement,
+		}
+	}
+	return ch, nil
+}
+
+// goToCty converts v to a cty value, the inverse of ctyToGo; values of other
+// types than those produced by ctyToGo, JSON decoders and the common integer
+// and float types are converted to strings by fmt.Sprint.
+func goToCty(v interface{}) cty.Value {
+	switch v := v.(type) {
+	case nil:
+		return cty.NullVal(cty.DynamicPseudoType)
+	case string:
+		return cty.StringVal(v)
+	case bool:
+		return cty.BoolVal(v)
+	case int:
+		return cty.NumberIntVal(int64(v))
+	case int8:
+		return cty.NumberIntVal(int64(v))
+	case int16:
+		return cty.NumberIntVal(int64(v))
+	case int32:
+		return cty.NumberIntVal(int64(v))
+	case int64:
+		return cty.NumberIntVal(v)
+	case uint:
+		return cty.NumberUIntVal(uint64(v))
+	case uint8:
+		return cty.NumberUIntVal(uint64(v))
+	case uint16:
+		return cty.NumberUIntVal(uint64(v))
+	case uint32:
+		return cty.NumberUIntVal(uint64(v))
+	case uint64:
+		return cty.NumberUIntVal(v)
+	case float32:
+		return goToCty(float64(v))
+	case float64:
+		if math.IsNaN(v) {
+			// cty numbers cannot be NaN
+			return cty.StringVal(fmt.Sprint(v))
+		}
+		return cty.NumberFloatVal(v)
+	case json.Number:
+		if n, err := cty.ParseNumberVal(v.String()); err == nil {
+			return n
+		}
+		return cty.StringVal(v.String())
+	case []interface{}:
+		if len(v) <= 0 {
+			return cty.EmptyTupleVal
+		}
+		vals := make([]cty.Value, 0, len(v))
+		for _, e := range v {
+			vals = append(vals, goToCty(e))
+		}
+		return cty.TupleVal(vals)
+	case map[string]interface{}:
+		if len(v) <= 0 {
+			return cty.EmptyObjectVal
+		}
+		vals := make(map[string]cty.Value, len(v))
+		for k, e := range v {
+			vals[k] = goToCty(e)
+		}
+		return cty.ObjectVal(vals)
+	default:
+		return cty.StringVal(fmt.Sprint(v))
+	}
+}
+
+// encoderNamesOf are the registered names of the encoders of an encoder
+// configuration; names of nil encoders are empty. The time encoder is
+// represented by the time layout instead, if set, cf. Config.TimeLayout.
+type encoderNamesOf struct {
+	level, time, duration, caller, name string
+	timeLayout, timeZone                string
+}
+
+// encoderNames looks up the registered names of the encoders of ec; its time
+// encoder is ignored, if the time layout is set.
+func encoderNames(ec zapcore.EncoderConfig, timeLayout, timeZone string) (encoderNamesOf, error) {
+	names := encoderNamesOf{timeLayout: timeLayout, timeZone: timeZone}
+	var err error
+	if names.level, err = encoderName(levelEncoders, ec.EncodeLevel, ec.EncodeLevel == nil); err != nil {
+		return encoderNamesOf{}, err
+	}
+	if len(timeLayout) <= 0 {
+		if names.time, err = encoderName(timeEncoders, ec.EncodeTime, ec.EncodeTime == nil); err != nil {
+			return encoderNamesOf{}, err
+		}
+	}
+	if names.duration, err = encoderName(durationEncoders, ec.EncodeDuration, ec.EncodeDuration == nil); err != nil {
+		return encoderNamesOf{}, err
+	}
+	if names.caller, err = encoderName(callerEncoders, ec.EncodeCaller, ec.EncodeCaller == nil); err != nil {
+		return encoderNamesOf{}, err
+	}
+	if names.name, err = encoderName(nameEncoders, ec.EncodeName, ec.EncodeName == nil); err != nil {
+		return encoderNamesOf{}, err
+	}
+	return names, nil
+}
+
+// encoderName returns the registered name of enc, or an empty name, if enc is
+// nil.
+func encoderName[E any](encoders *registry[E], enc E, isNil bool) (string, error) {
+	if isNil {
+		return "", nil
+	}
+	name, ok := encoders.nameOf(enc)
+	if !ok {
+		return "", fmt.Errorf("unregistered %s", encoders.kind)
+	}
+	return name, nil
+}
+
+// writeHCLBody writes the attributes and blocks of v, a struct decoded by
+// gohcl, to body; attributes with zero values are omitted, unless their field
+// is tagged `hclwrite:"zero"`, e.g. as their default is not zero.
+func writeHCLBody(body *hclwrite.Body, v reflect.Value) error {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		name, kind, _ := strings.Cut(t.Field(i).Tag.Get("hcl"), ",")
+		fv := v.Field(i)
+		switch kind {
+		case "", "attr", "optional":
+		default:
+			continue
+		}
+		if t.Field(i).Tag.Get("hclwrite") != "zero" &&
+			(fv.IsZero() || ((fv.Kind() == reflect.Slice || fv.Kind() == reflect.Map) && fv.Len() <= 0)) {
+			continue
+		}
+
+		if val, ok := fv.Interface().(cty.Value); ok {
+			body.SetAttributeValue(name, val)
+			continue
+		}
+
+		ty, err := gocty.ImpliedType(fv.Interface())
+		if err != nil {
+			return fmt.Errorf("attribute %s - %w", name, err)
+		}
+		val, err := gocty.ToCtyValue(fv.Interface(), ty)
+		if err != nil {
+			return fmt.Errorf("attribute %s - %w", name, err)
+		}
+		body.SetAttributeValue(name, val)
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		name, kind, _ := strings.Cut(t.Field(i).Tag.Get("hcl"), ",")
+		if kind != "block" {
+			continue
+		}
+		fv := v.Field(i)
+		switch fv.Kind() {
+		case reflect.Ptr:
+			if !fv.IsNil() {
+				if err := writeHCLBlock(body, name, fv.Elem()); err != nil {
+					return err
+				}
+			}
+		case reflect.Slice:
+			for j := 0; j < fv.Len(); j++ {
+				if err := writeHCLBlock(body, name, fv.Index(j)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// writeHCLBlock appends a block of type name with the labels and body of v to
+// body, cf. writeHCLBody.
+func writeHCLBlock(body *hclwrite.Body, name string, v reflect.Value) error {
+	var labels []string
+	for i := 0; i < v.NumField(); i++ {
+		if _, kind, _ := strings.Cut(v.Type().Field(i).Tag.Get("hcl"), ","); kind == "label" {
+			labels = append(labels, v.Field(i).String())
+		}
+	}
+
+	if len(body.Attributes()) > 0 || len(body.Blocks()) > 0 {
+		body.AppendNewline()
+	}
+	block := body.AppendNewBlock(name, labels)
+	if err := writeHCLBody(block.Body(), v); err != nil {
+		return fmt.Errorf("block %s - %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,312 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package log_test
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/sobchak-security/klutz/pkg/log"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// testGolden compares got with the golden file path, which is updated instead,
+// if the flag -update is set.
+func testGolden(t *testing.T, path string, got []byte) {
+	t.Helper()
+
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("output differs from %s:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+func testUnmarshalHCL(t *testing.T, src []byte, name string) log.Config {
+	t.Helper()
+
+	hf, diags := hclparse.NewParser().ParseHCL(src, name)
+	if diags.HasErrors() {
+		t.Fatalf("ParseHCL() error = %v", diags)
+	}
+	var cfg log.Config
+	if err := cfg.UnmarshalHCL(nil, hf.Body); err != nil {
+		t.Fatalf("UnmarshalHCL() error = %v", err)
+	}
+	return cfg
+}
+
+func TestMarshalGolden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "marshal", "*.hcl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, input := range inputs {
+		if strings.HasSuffix(input, ".golden.hcl") {
+			continue
+		}
+		base := strings.TrimSuffix(input, ".hcl")
+
+		t.Run("success: "+filepath.Base(base), func(t *testing.T) {
+			src, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg := testUnmarshalHCL(t, src, input)
+
+			gotHCL, err := cfg.MarshalHCL()
+			if err != nil {
+				t.Fatalf("MarshalHCL() error = %v", err)
+			}
+			testGolden(t, base+".golden.hcl", gotHCL)
+
+			// the canonical HCL is stable
+			again := testUnmarshalHCL(t, gotHCL, base+".golden.hcl")
+			if b, err := again.MarshalHCL(); err != nil || string(b) != string(gotHCL) {
+				t.Errorf("MarshalHCL() after round trip = %s, %v, want %s", b, err, gotHCL)
+			}
+
+			gotJSON, err := json.MarshalIndent(&cfg, "", "  ")
+			if err != nil {
+				t.Fatalf("MarshalJSON() error = %v", err)
+			}
+			testGolden(t, base+".golden.json", append(gotJSON, '\n'))
+
+			// the JSON is stable
+			var m map[string]interface{}
+			if err := json.Unmarshal(gotJSON, &m); err != nil {
+				t.Fatal(err)
+			}
+			var fromJSON log.Config
+			if err := fromJSON.UnmarshalMap(m); err != nil {
+				t.Fatalf("UnmarshalMap() error = %v", err)
+			}
+			if b, err := json.MarshalIndent(&fromJSON, "", "  "); err != nil || string(b) != string(gotJSON) {
+				t.Errorf("MarshalJSON() after round trip = %s, %v, want %s", b, err, gotJSON)
+			}
+		})
+	}
+}
+
+func TestMarshalPresets(t *testing.T) {
+	for _, zc := range []zap.Config{log.DevConfig(), log.StdConfig()} {
+		cfg := log.Config{Config: zc}
+		b, err := cfg.MarshalHCL()
+		if err != nil {
+			t.Fatalf("MarshalHCL() error = %v", err)
+		}
+		again := testUnmarshalHCL(t, b, "preset.hcl")
+
+		want, _ := json.Marshal(&cfg)
+		if got, _ := json.Marshal(&again); string(got) != string(want) {
+			t.Errorf("MarshalHCL() round trip: %s, want %s", got, want)
+		}
+	}
+}
+
+func TestConfigWrapperMarshal(t *testing.T) {
+	hf, diags := hclparse.NewParser().ParseHCL([]byte(`
+encoding = "console"
+encoder_config {
+  level_encoder = "capital"
+  time_encoder  = "rfc3339"
+}`), "wrapper.hcl")
+	if diags.HasErrors() {
+		t.Fatalf("ParseHCL() error = %v", diags)
+	}
+	var zc zap.Config
+	cw := (*log.ConfigWrapper)(&zc)
+	if err := cw.UnmarshalHCL(nil, hf.Body); err != nil {
+		t.Fatalf("UnmarshalHCL() error = %v", err)
+	}
+
+	b, err := cw.MarshalHCL()
+	if err != nil {
+		t.Fatalf("MarshalHCL() error = %v", err)
+	}
+	for _, want := range []string{`encoding\s+= "console"\n`, `level_encoder\s+= "capital"`, `time_encoder\s+= "RFC3339"`} {
+		if !regexp.MustCompile(want).Match(b) {
+			t.Errorf("MarshalHCL() = %s, want match of %q", b, want)
+		}
+	}
+	m, err := cw.MarshalMap()
+	if err != nil {
+		t.Fatalf("MarshalMap() error = %v", err)
+	}
+	if m["encoding"] != "console" {
+		t.Errorf("MarshalMap() [encoding]: %v, want %q", m["encoding"], "console")
+	}
+
+	// round trip
+	hf, diags = hclparse.NewParser().ParseHCL(b, "again.hcl")
+	if diags.HasErrors() {
+		t.Fatalf("ParseHCL() error = %v", diags)
+	}
+	var again zap.Config
+	if err := (*log.ConfigWrapper)(&again).UnmarshalHCL(nil, hf.Body); err != nil {
+		t.Fatalf("UnmarshalHCL() error = %v", err)
+	}
+	want, err := json.Marshal(cw)
+	if err != nil {
+		t.Fatalf("MarshalJSON() error = %v", err)
+	}
+	if got, _ := json.Marshal((*log.ConfigWrapper)(&again)); string(got) != string(want) {
+		t.Errorf("MarshalJSON() after round trip = %s, want %s", got, want)
+	}
+
+	// a time layout is applied to the time encoder, which cannot be marshaled
+	if err := cw.OverlayMap(map[string]interface{}{"encoderConfig": map[string]interface{}{"timeEncoder": map[string]interface{}{"layout": "Kitchen"}}}); err != nil {
+		t.Fatalf("OverlayMap() error = %v", err)
+	}
+	if _, err := cw.MarshalHCL(); err == nil {
+		t.Errorf("MarshalHCL() error = nil, want error")
+	}
+}
+
+func TestMarshalTimeEncoders(t *testing.T) {
+	tests := []struct {
+		name   string
+		enc    zapcore.TimeEncoder
+		layout string
+		zone   string
+		want   string
+	}{
+		{
+			name:   "success: layout",
+			layout: "Stamp",
+			want:   `time_layout\s+= "Stamp"`,
+		},
+		{
+			name:   "success: layout in time zone",
+			layout: "2006-01-02",
+			zone:   "UTC",
+			want:   `time_layout\s+= "2006-01-02"\s+time_zone\s+= "UTC"`,
+		},
+		{
+			name: "success: registered encoder in time zone",
+			enc:  zapcore.RFC3339TimeEncoder,
+			zone: "Europe/Zurich",
+			want: `time_encoder\s+= "RFC3339"\s+time_zone\s+= "Europe/Zurich"`,
+		},
+		{
+			// the layout replaces the time encoder
+			name:   "success: layout replacing an encoder",
+			enc:    log.LayoutTimeEncoder("Kitchen", nil),
+			layout: "Kitchen",
+			want:   `level_encoder\s+= "capitalColor"\s+time_layout\s+= "Kitchen"\s+duration_encoder`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := log.Config{Config: log.StdConfig(), TimeLayout: tt.layout, TimeZone: tt.zone}
+			if tt.enc != nil {
+				cfg.EncoderConfig.EncodeTime = tt.enc
+			}
+			b, err := cfg.MarshalHCL()
+			if err != nil {
+				t.Fatalf("MarshalHCL() error = %v", err)
+			}
+			if !regexp.MustCompile(tt.want).Match(b) {
+				t.Errorf("MarshalHCL() = %s, want match of %q", b, tt.want)
+			}
+
+			again := testUnmarshalHCL(t, b, "time.hcl")
+			if again.TimeLayout != cfg.TimeLayout || again.TimeZone != cfg.TimeZone {
+				t.Errorf("UnmarshalHCL() [TimeLayout, TimeZone]: %q, %q, want %q, %q",
+					again.TimeLayout, again.TimeZone, cfg.TimeLayout, cfg.TimeZone)
+			}
+		})
+	}
+}
+
+func TestMarshalRedactHashKey(t *testing.T) {
+	cfg := log.Config{
+		Config: log.StdConfig(),
+		Redact: &log.RedactConfig{Keys: []string{"password"}, Action: log.RedactActionHash, HashKey: "k3y"},
+	}
+
+	b, err := cfg.MarshalHCL()
+	if err != nil {
+		t.Fatalf("MarshalHCL() error = %v", err)
+	}
+	if strings.Contains(string(b), "k3y") || !strings.Contains(string(b), `action = "hash"`) {
+		t.Errorf("MarshalHCL() = %s, want redaction without hash key", b)
+	}
+
+	b, err = json.Marshal(&cfg)
+	if err != nil {
+		t.Fatalf("MarshalJSON() error = %v", err)
+	}
+	if strings.Contains(string(b), "k3y") || !strings.Contains(string(b), `"action":"hash"`) {
+		t.Errorf("MarshalJSON() = %s, want redaction without hash key", b)
+	}
+}
+
+func TestMarshalErrors(t *testing.T) {
+	log.RestoreRegistries(t)
+	closure := func(zapcore.Level, zapcore.PrimitiveArrayEncoder) {}
+	if err := log.RegisterLevelEncoder("test_closure", closure); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		cfg  func() log.Config
+	}{
+		{
+			name: "failure: time encoder closure",
+			cfg: func() log.Config {
+				cfg := log.Config{Config: log.StdConfig()}
+				cfg.EncoderConfig.EncodeTime = log.LayoutTimeEncoder("Kitchen", time.UTC)
+				return cfg
+			},
+		},
+		{
+			name: "failure: registered closure",
+			cfg: func() log.Config {
+				cfg := log.Config{Config: log.StdConfig()}
+				cfg.EncoderConfig.EncodeLevel = closure
+				return cfg
+			},
+		},
+		{
+			name: "failure: unregistered level encoder",
+			cfg: func() log.Config {
+				cfg := log.Config{Config: log.StdConfig()}
+				cfg.EncoderConfig.EncodeLevel = func(zapcore.Level, zapcore.PrimitiveArrayEncoder) {}
+				return cfg
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg()
+			if _, err := cfg.MarshalHCL(); err == nil {
+				t.Errorf("MarshalHCL() error = nil, want error")
+			}
+			if _, err := cfg.MarshalJSON(); err == nil {
+				t.Errorf("MarshalJSON() error = nil, want error")
+			}
+		})
+	}
+}
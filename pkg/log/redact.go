@@ -0,0 +1,580 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package log
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// Redaction actions, cf. RedactConfig.
+const (
+	RedactActionRedact = "redact"
+	RedactActionHash   = "hash"
+)
+
+// defaultRedactReplacement replaces redacted values, unless configured
+// otherwise.
+const defaultRedactReplacement = "[REDACTED]"
+
+// redactPreset is a predefined value pattern; matches are redacted, if valid
+// is nil or returns true.
+type redactPreset struct {
+	re    *regexp.Regexp
+	valid func(string) bool
+}
+
+// redactPresets are the predefined value patterns, cf. RedactConfig.
+var redactPresets = map[string]redactPreset{
+	"jwt": {
+		re: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+	},
+	"aws_access_key": {
+		re: regexp.MustCompile(`\b(?:AKIA|ASIA|AROA|AIDA)[0-9A-Z]{16}\b`),
+	},
+	"credit_card": {
+		re:    regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+		valid: luhnValid,
+	},
+}
+
+// RedactConfig configures the redaction of field values.
+type RedactConfig struct {
+	// Keys are the names of fields, whose values are redacted, either exact
+	// names or glob patterns, e.g. "*token*", cf. path.Match. Keys are
+	// compared case-insensitively; the keys of nested objects are matched by
+	// name and by their dotted path, e.g. "user.password".
+	Keys []string `json:"keys,omitempty"`
+	// Values are regular expressions; matching parts of string values are
+	// redacted.
+	Values []string `json:"values,omitempty"`
+	// Presets are names of predefined value patterns, i.e. "jwt",
+	// "aws_access_key" and "credit_card".
+	Presets []string `json:"presets,omitempty"`
+	// Action is either RedactActionRedact, the default, replacing values by
+	// Replacement, or RedactActionHash, replacing values by the prefix of
+	// their HMAC-SHA256 keyed by HashKey, e.g. "hmac:9f86d081884c7d65", so
+	// equal values can still be correlated.
+	Action string `json:"action,omitempty"`
+	// Replacement defaults to "[REDACTED]".
+	Replacement string `json:"replacement,omitempty"`
+	// HashKey is the key of the HMAC, required by RedactActionHash. Anyone
+	// knowing the key can confirm guesses of redacted values, hence it must
+	// not be stored together with the logs, e.g. in the same configuration
+	// file, but rather be read from a secret, cf. lib.FileFunctions.
+	HashKey string `json:"hashKey,omitempty"`
+}
+
+// RedactPresets returns the names of the predefined value patterns.
+func RedactPresets() []string {
+	names := make([]string, 0, len(redactPresets))
+	for name := range redactPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Redactor redacts field values as configured by a RedactConfig.
+type Redactor struct {
+	keys        []string
+	values      []redactPreset
+	hashKey     []byte
+	replacement string
+}
+
+// NewRedactor creates a Redactor; errors refer to invalid keys, values, presets
+// or actions.
+func NewRedactor(cfg RedactConfig) (*Redactor, error) {
+	r, err := newRedactor(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("NewRedactor(): %w", err)
+	}
+	return r, nil
+}
+
+func newRedactor(cfg RedactConfig) (*Redactor, error) {
+	r := &Redactor{replacement: cfg.Replacement}
+	if len(r.replacement) <= 0 {
+		r.replacement = defaultRedactReplacement
+	}
+
+	switch cfg.Action {
+	case "", RedactActionRedact:
+	case RedactActionHash:
+		if len(cfg.HashKey) <= 0 {
+			return nil, fmt.Errorf("action %q requires a hash key", cfg.Action)
+		}
+		r.hashKey = []byte(cfg.HashKey)
+	default:
+		return nil, fmt.Errorf("unknown action %q, valid actions are %q",
+			cfg.Action, []string{RedactActionRedact, RedactActionHash})
+	}
+
+	for _, key := range cfg.Keys {
+		key = strings.ToLower(key)
+		if _, err := path.Match(key, ""); err != nil {
+			return nil, fmt.Errorf("invalid key pattern %q - %w", key, err)
+		}
+		r.keys = append(r.keys, key)
+	}
+	for _, value := range cfg.Values {
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value pattern %q - %w", value, err)
+		}
+		r.values = append(r.values, redactPreset{re: re})
+	}
+	for _, name := range cfg.Presets {
+		preset, ok := redactPresets[name]
+		if !ok {
+			if suggestion := nameSuggestion(name, RedactPresets()); len(suggestion) > 0 {
+				return nil, fmt.Errorf("unknown preset %q, did you mean %q?", name, suggestion)
+			}
+			return nil, fmt.Errorf("unknown preset %q, valid presets are %q", name, RedactPresets())
+		}
+		r.values = append(r.values, preset)
+	}
+	return r, nil
+}
+
+// WrapEncoder wraps enc, so that the values of fields are redacted, before they
+// are encoded. As fields added by zap.Logger.With, including the initial
+// fields of a zap.Config, are added to a clone of the encoder, those fields
+// are redacted, too. Entry messages are not redacted.
+func (r *Redactor) WrapEncoder(enc zapcore.Encoder) zapcore.Encoder {
+	return &redactEncoder{
+		redactObjectEncoder: redactObjectEncoder{enc: enc, r: r},
+		inner:               enc,
+	}
+}
+
+// matchKey reports, whether the field key, nested in an object at prefix,
+// has to be redacted.
+func (r *Redactor) matchKey(prefix, key string) bool {
+	key = strings.ToLower(key)
+	dotted := strings.ToLower(prefix) + key
+	for _, pattern := range r.keys {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, dotted); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// replace returns the replacement of the value v.
+func (r *Redactor) replace(v string) string {
+	if r.hashKey == nil {
+		return r.replacement
+	}
+	mac := hmac.New(sha256.New, r.hashKey)
+	mac.Write([]byte(v))
+	return "hmac:" + hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// redactString replaces all parts of s matching the value patterns.
+func (r *Redactor) redactString(s string) string {
+	for _, p := range r.values {
+		s = p.re.ReplaceAllStringFunc(s, func(m string) string {
+			if p.valid != nil && !p.valid(m) {
+				return m
+			}
+			return r.replace(m)
+		})
+	}
+	return s
+}
+
+// redactValue redacts a generic JSON value, reporting whether it has been
+// changed.
+func (r *Redactor) redactValue(prefix string, v interface{}) (interface{}, bool) {
+	switch v := v.(type) {
+	case string:
+		s := r.redactString(v)
+		return s, s != v
+	case []interface{}:
+		changed := false
+		for i, elem := range v {
+			var ok bool
+			if v[i], ok = r.redactValue(prefix, elem); ok {
+				changed = true
+			}
+		}
+		return v, changed
+	case map[string]interface{}:
+		changed := false
+		for key, elem := range v {
+			if r.matchKey(prefix, key) {
+				s, ok := elem.(string)
+				if !ok {
+					b, _ := json.Marshal(elem)
+					s = string(b)
+				}
+				v[key] = r.replace(s)
+				changed = true
+				continue
+			}
+			var ok bool
+			if v[key], ok = r.redactValue(prefix+key+".", elem); ok {
+				changed = true
+			}
+		}
+		return v, changed
+	}
+	return v, false
+}
+
+// redactEncoder is a zapcore.Encoder redacting fields before passing them to
+// the wrapped encoder.
+type redactEncoder struct {
+	redactObjectEncoder
+	inner zapcore.Encoder
+}
+
+// Clone implements the zapcore.Encoder interface.
+func (e *redactEncoder) Clone() zapcore.Encoder {
+	return e.clone()
+}
+
+func (e *redactEncoder) clone() *redactEncoder {
+	inner := e.inner.Clone()
+	return &redactEncoder{
+		redactObjectEncoder: redactObjectEncoder{enc: inner, r: e.r, prefix: e.prefix},
+		inner:               inner,
+	}
+}
+
+// EncodeEntry implements the zapcore.Encoder interface.
+func (e *redactEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	if len(fields) <= 0 {
+		return e.inner.EncodeEntry(ent, nil)
+	}
+	final := e.clone()
+	for _, f := range fields {
+		f.AddTo(final)
+	}
+	return final.inner.EncodeEntry(ent, nil)
+}
+
+// redactObjectEncoder is a zapcore.ObjectEncoder redacting values before
+// adding them to enc.
+type redactObjectEncoder struct {
+	enc    zapcore.ObjectEncoder
+	r      *Redactor
+	prefix string
+}
+
+// redactKey adds the redacted value v, if key has to be redacted. Values other
+// than strings are hashed by their JSON representation.
+func (e *redactObjectEncoder) redactKey(key string, v interface{}) bool {
+	if !e.r.matchKey(e.prefix, key) {
+		return false
+	}
+	s, ok := v.(string)
+	if !ok {
+		b, _ := json.Marshal(v)
+		s = string(b)
+	}
+	e.enc.AddString(key, e.r.replace(s))
+	return true
+}
+
+// AddArray implements the zapcore.ObjectEncoder interface.
+func (e *redactObjectEncoder) AddArray(key string, arr zapcore.ArrayMarshaler) error {
+	if e.r.matchKey(e.prefix, key) {
+		m := zapcore.NewMapObjectEncoder()
+		_ = m.AddArray(key, arr)
+		e.redactKey(key, m.Fields[key])
+		return nil
+	}
+	return e.enc.AddArray(key, redactArray{arr: arr, r: e.r, prefix: e.prefix + key + "."})
+}
+
+// AddObject implements the zapcore.ObjectEncoder interface.
+func (e *redactObjectEncoder) AddObject(key string, obj zapcore.ObjectMarshaler) error {
+	if e.r.matchKey(e.prefix, key) {
+		m := zapcore.NewMapObjectEncoder()
+		_ = obj.MarshalLogObject(m)
+		e.redactKey(key, m.Fields)
+		return nil
+	}
+	return e.enc.AddObject(key, redactObject{obj: obj, r: e.r, prefix: e.prefix + key + "."})
+}
+
+// AddReflected implements the zapcore.ObjectEncoder interface. The value is
+// converted to its generic JSON representation, if it has to be redacted.
+func (e *redactObjectEncoder) AddReflected(key string, obj interface{}) error {
+	if e.redactKey(key, obj) {
+		return nil
+	}
+	if v, ok := e.r.redactReflected(e.prefix+key+".", obj); ok {
+		return e.enc.AddReflected(key, v)
+	}
+	return e.enc.AddReflected(key, obj)
+}
+
+// redactReflected returns the redacted generic JSON representation of obj, if
+// anything has to be redacted.
+func (r *Redactor) redactReflected(prefix string, obj interface{}) (interface{}, bool) {
+	if len(r.keys) <= 0 && len(r.values) <= 0 {
+		return nil, false
+	}
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return nil, false
+	}
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, false
+	}
+	return r.redactValue(prefix, v)
+}
+
+// OpenNamespace implements the zapcore.ObjectEncoder interface.
+func (e *redactObjectEncoder) OpenNamespace(key string) {
+	e.prefix += key + "."
+	e.enc.OpenNamespace(key)
+}
+
+// AddBinary implements the zapcore.ObjectEncoder interface.
+func (e *redactObjectEncoder) AddBinary(key string, v []byte) {
+	if !e.redactKey(key, string(v)) {
+		e.enc.AddBinary(key, v)
+	}
+}
+
+// AddByteString implements the zapcore.ObjectEncoder interface.
+func (e *redactObjectEncoder) AddByteString(key string, v []byte) {
+	if !e.redactKey(key, string(v)) {
+		e.enc.AddByteString(key, []byte(e.r.redactString(string(v))))
+	}
+}
+
+// AddBool implements the zapcore.ObjectEncoder interface.
+func (e *redactObjectEncoder) AddBool(key string, v bool) {
+	if !e.redactKey(key, v) {
+		e.enc.AddBool(key, v)
+	}
+}
+
+// AddComplex128 implements the zapcore.ObjectEncoder interface.
+func (e *redactObjectEncoder) AddComplex128(key string, v complex128) {
+	if !e.redactKey(key, v) {
+		e.enc.AddComplex128(key, v)
+	}
+}
+
+// AddComplex64 implements the zapcore.ObjectEncoder interface.
+func (e *redactObjectEncoder) AddComplex64(key string, v complex64) {
+	if !e.redactKey(key, v) {
+		e.enc.AddComplex64(key, v)
+	}
+}
+
+// AddDuration implements the zapcore.ObjectEncoder interface.
+func (e *redactObjectEncoder) AddDuration(key string, v time.Duration) {
+	if !e.redactKey(key, v) {
+		e.enc.AddDuration(key, v)
+	}
+}
+
+// AddFloat64 implements the zapcore.ObjectEncoder interface.
+func (e *redactObjectEncoder) AddFloat64(key string, v float64) {
+	if !e.redactKey(key, v) {
+		e.enc.AddFloat64(key, v)
+	}
+}
+
+// AddFloat32 implements the zapcore.ObjectEncoder interface.
+func (e *redactObjectEncoder) AddFloat32(key string, v float32) {
+	if !e.redactKey(key, v) {
+		e.enc.AddFloat32(key, v)
+	}
+}
+
+// AddInt implements the zapcore.ObjectEncoder interface.
+func (e *redactObjectEncoder) AddInt(key string, v int) {
+	if !e.redactKey(key, v) {
+		e.enc.AddInt(key, v)
+	}
+}
+
+// AddInt64 implements the zapcore.ObjectEncoder interface.
+func (e *redactObjectEncoder) AddInt64(key string, v int64) {
+	if !e.redactKey(key, v) {
+		e.enc.AddInt64(key, v)
+	}
+}
+
+// AddInt32 implements the zapcore.ObjectEncoder interface.
+func (e *redactObjectEncoder) AddInt32(key string, v int32) {
+	if !e.redactKey(key, v) {
+		e.enc.AddInt32(key, v)
+	}
+}
+
+// AddInt16 implements the zapcore.ObjectEncoder interface.
+func (e *redactObjectEncoder) AddInt16(key string, v int16) {
+	if !e.redactKey(key, v) {
+		e.enc.AddInt16(key, v)
+	}
+}
+
+// AddInt8 implements the zapcore.ObjectEncoder interface.
+func (e *redactObjectEncoder) AddInt8(key string, v int8) {
+	if !e.redactKey(key, v) {
+		e.enc.AddInt8(key, v)
+	}
+}
+
+// AddString implements the zapcore.ObjectEncoder interface.
+func (e *redactObjectEncoder) AddString(key, v string) {
+	if !e.redactKey(key, v) {
+		e.enc.AddString(key, e.r.redactString(v))
+	}
+}
+
+// AddTime implements the zapcore.ObjectEncoder interface.
+func (e *redactObjectEncoder) AddTime(key string, v time.Time) {
+	if !e.redactKey(key, v) {
+		e.enc.AddTime(key, v)
+	}
+}
+
+// AddUint implements the zapcore.ObjectEncoder interface.
+func (e *redactObjectEncoder) AddUint(key string, v uint) {
+	if !e.redactKey(key, v) {
+		e.enc.AddUint(key, v)
+	}
+}
+
+// AddUint64 implements the zapcore.ObjectEncoder interface.
+func (e *redactObjectEncoder) AddUint64(key string, v uint64) {
+	if !e.redactKey(key, v) {
+		e.enc.AddUint64(key, v)
+	}
+}
+
+// AddUint32 implements the zapcore.ObjectEncoder interface.
+func (e *redactObjectEncoder) AddUint32(key string, v uint32) {
+	if !e.redactKey(key, v) {
+		e.enc.AddUint32(key, v)
+	}
+}
+
+// AddUint16 implements the zapcore.ObjectEncoder interface.
+func (e *redactObjectEncoder) AddUint16(key string, v uint16) {
+	if !e.redactKey(key, v) {
+		e.enc.AddUint16(key, v)
+	}
+}
+
+// AddUint8 implements the zapcore.ObjectEncoder interface.
+func (e *redactObjectEncoder) AddUint8(key string, v uint8) {
+	if !e.redactKey(key, v) {
+		e.enc.AddUint8(key, v)
+	}
+}
+
+// AddUintptr implements the zapcore.ObjectEncoder interface.
+func (e *redactObjectEncoder) AddUintptr(key string, v uintptr) {
+	if !e.redactKey(key, v) {
+		e.enc.AddUintptr(key, v)
+	}
+}
+
+// redactObject is a zapcore.ObjectMarshaler redacting the fields of obj.
+type redactObject struct {
+	obj    zapcore.ObjectMarshaler
+	r      *Redactor
+	prefix string
+}
+
+// MarshalLogObject implements the zapcore.ObjectMarshaler interface.
+func (o redactObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return o.obj.MarshalLogObject(&redactObjectEncoder{enc: enc, r: o.r, prefix: o.prefix})
+}
+
+// redactArray is a zapcore.ArrayMarshaler redacting the elements of arr.
+type redactArray struct {
+	arr    zapcore.ArrayMarshaler
+	r      *Redactor
+	prefix string
+}
+
+// MarshalLogArray implements the zapcore.ArrayMarshaler interface.
+func (a redactArray) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	return a.arr.MarshalLogArray(&redactArrayEncoder{ArrayEncoder: enc, r: a.r, prefix: a.prefix})
+}
+
+// redactArrayEncoder is a zapcore.ArrayEncoder redacting string, object, array
+// and reflected elements.
+type redactArrayEncoder struct {
+	zapcore.ArrayEncoder
+	r      *Redactor
+	prefix string
+}
+
+// AppendString implements the zapcore.ArrayEncoder interface.
+func (e *redactArrayEncoder) AppendString(v string) {
+	e.ArrayEncoder.AppendString(e.r.redactString(v))
+}
+
+// AppendByteString implements the zapcore.ArrayEncoder interface.
+func (e *redactArrayEncoder) AppendByteString(v []byte) {
+	e.ArrayEncoder.AppendByteString([]byte(e.r.redactString(string(v))))
+}
+
+// AppendArray implements the zapcore.ArrayEncoder interface.
+func (e *redactArrayEncoder) AppendArray(arr zapcore.ArrayMarshaler) error {
+	return e.ArrayEncoder.AppendArray(redactArray{arr: arr, r: e.r, prefix: e.prefix})
+}
+
+// AppendObject implements the zapcore.ArrayEncoder interface.
+func (e *redactArrayEncoder) AppendObject(obj zapcore.ObjectMarshaler) error {
+	return e.ArrayEncoder.AppendObject(redactObject{obj: obj, r: e.r, prefix: e.prefix})
+}
+
+// AppendReflected implements the zapcore.ArrayEncoder interface.
+func (e *redactArrayEncoder) AppendReflected(obj interface{}) error {
+	if v, ok := e.r.redactReflected(e.prefix, obj); ok {
+		return e.ArrayEncoder.AppendReflected(v)
+	}
+	return e.ArrayEncoder.AppendReflected(obj)
+}
+
+// luhnValid reports, whether the digits of s pass the Luhn check, cf.
+// ISO/IEC 7812-1.
+func luhnValid(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n > 0 && sum%10 == 0
+}
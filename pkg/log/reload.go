@@ -0,0 +1,309 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package log
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Reloader maintains a logger configured by a file, cf. Config.LoadFile,
+// which is watched for changes. On change, level changes are applied to the
+// logger's atomic level in place, whereas changes of encoding, encoder
+// configuration, outputs, sampling or initial fields lead to a new core, which
+// atomically replaces the previous one. Caller, stacktrace, development and
+// error output settings are applied only once, when the Reloader is created.
+type Reloader struct {
+	path    string
+	level   zap.AtomicLevel
+	core    *swappableCore
+	logger  *zap.Logger
+	onError func(error)
+
+	mu          sync.Mutex
+	cfg         Config
+	fingerprint string
+	modTime     time.Time
+	size        int64
+	closeSinks  func()
+	closePrev   func()
+	closeErrOut func()
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewReloader creates a Reloader for the configuration file path, which is
+// polled for changes in the given interval; if interval is not positive,
+// changes are only applied by calling Reload. Errors occuring during
+// reloads are reported to onError, if set, or logged by the running logger
+// otherwise. The options are applied to the logger.
+func NewReloader(path string, interval time.Duration, onError func(error), opts ...zap.Option) (*Reloader, error) {
+	r := &Reloader{
+		path:    path,
+		onError: onError,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	cfg, fingerprint, info, err := r.load()
+	if err != nil {
+		return nil, fmt.Errorf("NewReloader(): %w", err)
+	}
+
+	r.level = zap.NewAtomicLevelAt(cfg.Level.Level())
+	core, closeSinks, err := buildCore(cfg, r.level)
+	if err != nil {
+		return nil, fmt.Errorf("NewReloader(): building core failed - %w", err)
+	}
+	errSink, closeErrOut, err := zap.Open(cfg.ErrorOutputPaths...)
+	if err != nil {
+		closeSinks()
+		return nil, fmt.Errorf("NewReloader(): opening error output failed - %w", err)
+	}
+
+	r.core = newSwappableCore(core)
+	r.logger = zap.New(r.core, append(buildOptions(cfg.Config, errSink), opts...)...)
+	r.cfg = cfg
+	r.fingerprint = fingerprint
+	r.modTime, r.size = info.ModTime(), info.Size()
+	r.closeSinks = closeSinks
+	r.closeErrOut = closeErrOut
+
+	if interval > 0 {
+		go r.watch(interval)
+	} else {
+		close(r.done)
+	}
+
+	return r, nil
+}
+
+// Logger returns the logger maintained by r.
+func (r *Reloader) Logger() *zap.Logger {
+	return r.logger
+}
+
+// Level returns the atomic level of the logger maintained by r.
+func (r *Reloader) Level() zap.AtomicLevel {
+	return r.level
+}
+
+// Reload reads the configuration file and applies changes to the logger. On
+// error, the logger remains unchanged.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cfg, fingerprint, info, err := r.load()
+	if err != nil {
+		return fmt.Errorf("Reload(): %w", err)
+	}
+	r.modTime, r.size = info.ModTime(), info.Size()
+
+	if !r.sameCore(cfg, fingerprint) {
+		core, closeSinks, err := buildCore(cfg, r.level)
+		if err != nil {
+			return fmt.Errorf("Reload(): building core failed - %w", err)
+		}
+		r.core.swap(core)
+
+		// entries might still be written to the previous core, hence its
+		// sinks are closed with the next swap
+		if r.closePrev != nil {
+			r.closePrev()
+		}
+		r.closePrev, r.closeSinks = r.closeSinks, closeSinks
+		r.cfg, r.fingerprint = cfg, fingerprint
+	}
+
+	r.level.SetLevel(cfg.Level.Level())
+	return nil
+}
+
+// Close stops watching the configuration file and closes all sinks; the
+// logger must not be used afterwards.
+func (r *Reloader) Close() error {
+	select {
+	case <-r.stop:
+	default:
+		close(r.stop)
+	}
+	<-r.done
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err := syncLogger(r.logger)
+	for _, closer := range []func(){r.closePrev, r.closeSinks, r.closeErrOut} {
+		if closer != nil {
+			closer()
+		}
+	}
+	r.closePrev, r.closeSinks, r.closeErrOut = nil, nil, nil
+	return err
+}
+
+func (r *Reloader) load() (Config, string, os.FileInfo, error) {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return Config{}, "", nil, fmt.Errorf("reading configuration failed - %w", err)
+	}
+
+	var cfg Config
+	if err := cfg.LoadFile(r.path); err != nil {
+		return Config{}, "", nil, err
+	}
+	if cfg.Level == (zap.AtomicLevel{}) {
+		cfg.Level = zap.NewAtomicLevel()
+	}
+
+	fingerprint, err := encoderFingerprint(cfg)
+	if err != nil {
+		return Config{}, "", nil, fmt.Errorf("creating encoder failed - %w", err)
+	}
+	return cfg, fingerprint, info, nil
+}
+
+// sameCore reports, whether cfg would result in the same core as the current
+// configuration.
+func (r *Reloader) sameCore(cfg Config, fingerprint string) bool {
+	if fingerprint != r.fingerprint || cfg.Encoding != r.cfg.Encoding || cfg.Syslog != r.cfg.Syslog {
+		return false
+	}
+	if !reflect.DeepEqual(cfg.OutputPaths, r.cfg.OutputPaths) ||
+		!reflect.DeepEqual(cfg.InitialFields, r.cfg.InitialFields) ||
+		!reflect.DeepEqual(cfg.Redact, r.cfg.Redact) {
+		return false
+	}
+
+	x, y := cfg.Sampling, r.cfg.Sampling
+	if x == nil || y == nil {
+		return x == y
+	}
+	return x.Initial == y.Initial && x.Thereafter == y.Thereafter &&
+		cfg.SamplingTick == r.cfg.SamplingTick &&
+		reflect.ValueOf(x.Hook).Pointer() == reflect.ValueOf(y.Hook).Pointer()
+}
+
+func (r *Reloader) watch(interval time.Duration) {
+	defer close(r.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(r.path)
+		if err == nil {
+			// an invalid configuration is reported only once per change
+			r.mu.Lock()
+			changed := !info.ModTime().Equal(r.modTime) || info.Size() != r.size
+			r.modTime, r.size = info.ModTime(), info.Size()
+			r.mu.Unlock()
+			if !changed {
+				continue
+			}
+			err = r.Reload()
+		}
+		if err != nil {
+			r.reportError(err)
+		}
+	}
+}
+
+func (r *Reloader) reportError(err error) {
+	if r.onError != nil {
+		r.onError(err)
+		return
+	}
+	r.logger.Error("reloading log configuration failed",
+		zap.String("path", r.path), zap.Error(err))
+}
+
+// swappableCore is a zapcore.Core delegating to a core, which can be replaced
+// atomically. Fields added with With are retained across replacements.
+type swappableCore struct {
+	current *atomic.Pointer[coreGeneration]
+	fields  []zapcore.Field
+	derived atomic.Pointer[coreGeneration]
+}
+
+// coreGeneration is a core together with the number of replacements preceding
+// it.
+type coreGeneration struct {
+	gen  uint64
+	core zapcore.Core
+}
+
+func newSwappableCore(core zapcore.Core) *swappableCore {
+	c := &swappableCore{current: &atomic.Pointer[coreGeneration]{}}
+	c.current.Store(&coreGeneration{core: core})
+	return c
+}
+
+func (c *swappableCore) swap(core zapcore.Core) {
+	for {
+		cur := c.current.Load()
+		if c.current.CompareAndSwap(cur, &coreGeneration{gen: cur.gen + 1, core: core}) {
+			return
+		}
+	}
+}
+
+// load returns the current core with the fields of c applied.
+func (c *swappableCore) load() zapcore.Core {
+	cur := c.current.Load()
+	if len(c.fields) <= 0 {
+		return cur.core
+	}
+	if derived := c.derived.Load(); derived != nil && derived.gen == cur.gen {
+		return derived.core
+	}
+	derived := &coreGeneration{gen: cur.gen, core: cur.core.With(c.fields)}
+	c.derived.Store(derived)
+	return derived.core
+}
+
+// Enabled implements the zapcore.LevelEnabler interface.
+func (c *swappableCore) Enabled(lvl zapcore.Level) bool {
+	return c.load().Enabled(lvl)
+}
+
+// With implements the zapcore.Core interface.
+func (c *swappableCore) With(fields []zapcore.Field) zapcore.Core {
+	child := &swappableCore{
+		current: c.current,
+		fields:  make([]zapcore.Field, 0, len(c.fields)+len(fields)),
+	}
+	child.fields = append(append(child.fields, c.fields...), fields...)
+	return child
+}
+
+// Check implements the zapcore.Core interface.
+func (c *swappableCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return c.load().Check(ent, ce)
+}
+
+// Write implements the zapcore.Core interface.
+func (c *swappableCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return c.load().Write(ent, fields)
+}
+
+// Sync implements the zapcore.Core interface.
+func (c *swappableCore) Sync() error {
+	return c.current.Load().core.Sync()
+}
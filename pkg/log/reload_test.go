@@ -0,0 +1,143 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package log_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sobchak-security/klutz/pkg/log"
+)
+
+func testWriteConfig(t *testing.T, path, conf string, mod time.Time) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(conf), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mod, mod); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloader(t *testing.T) {
+	dir := t.TempDir()
+	confPath := filepath.Join(dir, "log.hcl")
+	logPath := filepath.Join(dir, "app.log")
+	mod := time.Now().Add(-time.Hour)
+
+	conf := func(level, encoding string) string {
+		return fmt.Sprintf(`
+			level = %q
+			encoding = %q
+			disable_caller = true
+			output_paths = [ %q ]
+			encoder_config {
+				message_key = "M"
+				level_key = "L"
+			}`, level, encoding, logPath)
+	}
+	testWriteConfig(t, confPath, conf("info", "console"), mod)
+
+	var reloadErrs []error
+	r, err := log.NewReloader(confPath, 0, func(err error) { reloadErrs = append(reloadErrs, err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := r.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	logger := r.Logger().With(zap.String("component", "test"))
+	logger.Debug("debug 1")
+	logger.Info("info 1")
+
+	// level change only
+	testWriteConfig(t, confPath, conf("debug", "console"), mod.Add(time.Minute))
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Level().Level(); got != zap.DebugLevel {
+		t.Errorf("Reload() [Level]: %v, want %v", got, zap.DebugLevel)
+	}
+	logger.Debug("debug 2")
+
+	// encoding change
+	testWriteConfig(t, confPath, conf("debug", "json"), mod.Add(2*time.Minute))
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	logger.Debug("debug 3")
+
+	// invalid configuration
+	testWriteConfig(t, confPath, conf("verbose", "json"), mod.Add(3*time.Minute))
+	if err := r.Reload(); err == nil {
+		t.Errorf("Reload() error = %v, wantErr %v", err, true)
+	}
+	logger.Info("info 2")
+
+	wantLog := strings.Join([]string{
+		`info\tinfo 1\t{"component": "test"}`,
+		`debug\tdebug 2\t{"component": "test"}`,
+		`{"L":"debug","M":"debug 3","component":"test"}`,
+		`{"L":"info","M":"info 2","component":"test"}`,
+	}, `\n`)
+	testMatchLog(t, testReadLog(t, logPath), wantLog)
+	if len(reloadErrs) > 0 {
+		t.Errorf("NewReloader(): unexpected errors reported %v", reloadErrs)
+	}
+}
+
+func TestReloaderWatch(t *testing.T) {
+	dir := t.TempDir()
+	confPath := filepath.Join(dir, "log.json")
+	logPath := filepath.Join(dir, "app.log")
+	mod := time.Now().Add(-time.Hour)
+
+	conf := func(level string) string {
+		return fmt.Sprintf(`{
+			"level": %q,
+			"encoding": "json",
+			"outputPaths": [ %q ],
+			"encoderConfig": { "messageKey": "M" }
+		}`, level, logPath)
+	}
+	testWriteConfig(t, confPath, conf("warn"), mod)
+
+	errs := make(chan error, 1)
+	r, err := log.NewReloader(confPath, 5*time.Millisecond, func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	testWriteConfig(t, confPath, conf("error"), mod.Add(time.Minute))
+	testWaitFor(t, func() bool { return r.Level().Level() == zap.ErrorLevel })
+
+	testWriteConfig(t, confPath, `{ "level": `, mod.Add(2*time.Minute))
+	select {
+	case <-errs:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("NewReloader(): error of invalid configuration not reported")
+	}
+	if got := r.Level().Level(); got != zap.ErrorLevel {
+		t.Errorf("NewReloader() [Level]: %v, want %v", got, zap.ErrorLevel)
+	}
+
+	r.Logger().Error("still running")
+	testMatchLog(t, testReadLog(t, logPath), `"M":"still running"`)
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package log
+
+import (
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+var samplingHooks = newRegistry("sampling hook", map[string]func(zapcore.Entry, zapcore.SamplingDecision){})
+
+// RegisterSamplingHook makes a sampling hook available under name, so it can
+// be referenced by the hook attribute of a HCL sampling block.
+func RegisterSamplingHook(name string, hook func(zapcore.Entry, zapcore.SamplingDecision)) error {
+	return samplingHooks.register(name, hook, hook == nil)
+}
+
+// SamplingCounter counts the sampling decisions of a zap logger. Its Hook
+// method can be registered with RegisterSamplingHook or assigned to
+// zap.SamplingConfig.Hook directly.
+type SamplingCounter struct {
+	sampled atomic.Uint64
+	dropped atomic.Uint64
+}
+
+// Hook records a sampling decision.
+func (sc *SamplingCounter) Hook(_ zapcore.Entry, dec zapcore.SamplingDecision) {
+	if dec&zapcore.LogDropped != 0 {
+		sc.dropped.Add(1)
+	}
+	if dec&zapcore.LogSampled != 0 {
+		sc.sampled.Add(1)
+	}
+}
+
+// Sampled returns the number of entries, which have been logged.
+func (sc *SamplingCounter) Sampled() uint64 {
+	return sc.sampled.Load()
+}
+
+// Dropped returns the number of entries, which have been dropped.
+func (sc *SamplingCounter) Dropped() uint64 {
+	return sc.dropped.Load()
+}
@@ -0,0 +1,385 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/gocty"
+	"go.uber.org/zap/zapcore"
+)
+
+// SchemaAttribute describes an attribute of a HCL log configuration.
+type SchemaAttribute struct {
+	Name     string
+	Type     cty.Type
+	Required bool
+	// Default is the value assumed, if the attribute is absent, or nil, if
+	// there is no such value.
+	Default interface{}
+	// Enum lists the allowed values, or the allowed elements of lists, when
+	// the schema has been created; it is nil, if any value is allowed.
+	Enum        []string
+	Description string
+}
+
+// SchemaBlock describes a block type of a HCL log configuration.
+type SchemaBlock struct {
+	Type        string
+	Labels      []string
+	Repeatable  bool
+	Description string
+	Body        *Schema
+}
+
+// Schema describes the body of a HCL log configuration or of one of its
+// blocks.
+type Schema struct {
+	// Body is the schema used for decoding the body.
+	Body       *hcl.BodySchema
+	Attributes []SchemaAttribute
+	Blocks     []SchemaBlock
+}
+
+// schemaDoc documents an attribute, cf. SchemaAttribute.
+type schemaDoc struct {
+	description string
+	def         interface{}
+	enum        func() []string
+	// ty overrides the type implied by the decoded field, e.g. of cty.Value
+	// fields.
+	ty cty.Type
+}
+
+// schemaBlockDocs documents the block types by name.
+var schemaBlockDocs = map[string]string{
+	"sampling":       "Samples entries per level and message, cf. zap.SamplingConfig.",
+	"encoder_config": "Configures the encoder, cf. zapcore.EncoderConfig.",
+	"sink":           "Adds a rotating file sink to the output paths, cf. RotateOptions.",
+	"redact":         "Redacts field values by key or value pattern, cf. RedactConfig.",
+	"output":         "Adds an output with its own level, encoding and encoder configuration; only supported by TeeConfig, which requires output blocks instead of output paths and sink blocks.",
+}
+
+// schemaDocs documents the attributes by the name of their block type, if
+// any, and their own name, e.g. "sink.path".
+var schemaDocs = map[string]schemaDoc{
+	"level":              {description: "The minimum enabled level.", def: "info", enum: levelNames},
+	"encoding":           {description: "The encoding of entries.", def: "json", enum: encodingNames},
+	"output_paths":       {description: "The URLs or file paths to write entries to, e.g. \"stderr\"."},
+	"error_output_paths": {description: "The URLs or file paths to write internal errors to."},
+	"initial_fields":     {description: "The fields added to all entries; values may be strings, numbers, bools, lists and objects.", ty: cty.Map(cty.DynamicPseudoType)},
+	"development":        {description: "Puts the logger in development mode, e.g. DPanic entries panic.", def: false},
+	"disable_caller":     {description: "Stops annotating entries with the calling function's file name and line number.", def: false},
+	"disable_stacktrace": {description: "Stops capturing stack traces of warn entries in development mode and of error entries otherwise.", def: false},
+
+	"sampling.initial":    {description: "The number of entries per tick logged first.", def: 100},
+	"sampling.thereafter": {description: "Every how many entries per tick are logged thereafter; 0 drops all of them and has to be set explicitly.", def: 100},
+	"sampling.tick":       {description: "The sampling interval, a positive duration; ConfigWrapper only accepts \"1s\", as zap.Config always samples per second.", def: "1s"},
+	"sampling.hook":       {description: "The name of a registered sampling hook, cf. RegisterSamplingHook.", enum: samplingHooks.names},
+
+	"encoder_config.message_key":       {description: "The key of the message; the message is omitted, if empty."},
+	"encoder_config.level_key":         {description: "The key of the level; the level is omitted, if empty."},
+	"encoder_config.time_key":          {description: "The key of the time; the time is omitted, if empty."},
+	"encoder_config.name_key":          {description: "The key of the logger name; the name is omitted, if empty."},
+	"encoder_config.caller_key":        {description: "The key of the caller; the caller is omitted, if empty."},
+	"encoder_config.function_key":      {description: "The key of the function; the function is omitted, if empty."},
+	"encoder_config.stacktrace_key":    {description: "The key of the stack trace; the stack trace is omitted, if empty."},
+	"encoder_config.line_ending":       {description: "The line ending of entries.", def: "\n"},
+	"encoder_config.level_encoder":     {description: "The name of the level encoder, cf. RegisterLevelEncoder.", def: "lowercase", enum: levelEncoders.names},
+	"encoder_config.time_encoder":      {description: "The name of the time encoder, cf. RegisterTimeEncoder; conflicts with time_layout.", def: "epoch", enum: timeEncoders.names},
+	"encoder_config.time_layout":       {description: "The layout of times, either a layout of package time or the name of one of its layout constants, e.g. \"RFC3339Nano\", cf. TimeLayout; conflicts with time_encoder."},
+	"encoder_config.time_zone":         {description: "The time zone of times, e.g. \"UTC\", \"Local\" or an IANA time zone name."},
+	"encoder_config.duration_encoder":  {description: "The name of the duration encoder, cf. RegisterDurationEncoder.", def: "seconds", enum: durationEncoders.names},
+	"encoder_config.caller_encoder":    {description: "The name of the caller encoder, cf. RegisterCallerEncoder.", def: "short", enum: callerEncoders.names},
+	"encoder_config.name_encoder":      {description: "The name of the logger name encoder, cf. RegisterNameEncoder.", def: "full", enum: nameEncoders.names},
+	"encoder_config.console_separator": {description: "The separator of the elements of console encoded entries.", def: "\t"},
+	"encoder_config.skip_line_ending":  {description: "Omits the line ending of entries.", def: false},
+	"encoder_config.syslog_facility":   {description: "The syslog facility; requires the syslog encoding and is rejected by ConfigWrapper.", def: "user", enum: syslogFacilityNames},
+	"encoder_config.syslog_app_name":   {description: "The syslog application name, by default the name of the executable; requires the syslog encoding and is rejected by ConfigWrapper."},
+	"encoder_config.syslog_hostname":   {description: "The syslog hostname, by default the hostname of the system; requires the syslog encoding and is rejected by ConfigWrapper."},
+	"encoder_config.syslog_format":     {description: "The syslog message format; requires the syslog encoding and is rejected by ConfigWrapper.", def: RFC5424, enum: func() []string { return []string{RFC5424, RFC3164} }},
+
+	"sink.path":             {description: "The path of the log file."},
+	"sink.max_size":         {description: "The maximum size in megabytes of the log file before it gets rotated.", def: 100},
+	"sink.max_age":          {description: "The maximum number of days to retain rotated files; 0 retains files regardless of their age.", def: 0},
+	"sink.max_backups":      {description: "The maximum number of rotated files to retain; 0 retains all files.", def: 0},
+	"sink.compress":         {description: "Compresses rotated files using gzip.", def: false},
+	"sink.local_time":       {description: "Formats the timestamps of rotated files in local time rather than UTC.", def: false},
+	"sink.rotate_on_sighup": {description: "Rotates the log file, when the process receives SIGHUP.", def: false},
+
+	"redact.keys":        {description: "The keys of fields, whose values are redacted, either exact names or glob patterns, compared case-insensitively."},
+	"redact.values":      {description: "Regular expressions; matching parts of string values are redacted."},
+	"redact.presets":     {description: "Names of predefined value patterns.", enum: RedactPresets},
+	"redact.action":      {description: "Either replaces values by the replacement, or by the prefix of their HMAC-SHA256 keyed by the hash key.", def: RedactActionRedact, enum: func() []string { return []string{RedactActionRedact, RedactActionHash} }},
+	"redact.replacement": {description: "The replacement of redacted values.", def: defaultRedactReplacement},
+	"redact.hash_key":    {description: "The key of the HMAC, required by the action hash; it must not be stored together with the logs, e.g. use secret_file()."},
+
+	"output.path":     {description: "The URL or file path to write entries to."},
+	"output.level":    {description: "The minimum enabled level of the output, by default the level of the configuration.", enum: levelNames},
+	"output.encoding": {description: "The encoding of the output, by default the encoding of the configuration.", enum: encodingNames},
+}
+
+// levelNames returns the names of all levels parseLevelHCL accepts, unlike
+// Levels including dpanic and panic.
+func levelNames() []string {
+	var names []string
+	for lvl := zapcore.DebugLevel; lvl <= zapcore.FatalLevel; lvl++ {
+		names = append(names, lvl.String())
+	}
+	return names
+}
+
+// encodingNames returns the names of all encodings known to this package.
+func encodingNames() []string {
+	return encodings.names()
+}
+
+// syslogFacilityNames returns the names of the syslog facilities.
+func syslogFacilityNames() []string {
+	names := make([]string, 0, len(syslogFacilities))
+	for name := range syslogFacilities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ConfigSchema returns the schema of HCL log configurations, as processed by
+// Config.UnmarshalHCL and TeeConfig.UnmarshalHCL. The allowed values of
+// attributes like encoder names include all names registered so far.
+func ConfigSchema() *Schema {
+	return newSchema(reflect.TypeOf(configHCL{}), "")
+}
+
+// newSchema creates the schema of the body decoded into a struct of type t by
+// gohcl; the attributes are documented by the entries of schemaDocs prefixed
+// by block, the name of the block type.
+func newSchema(t reflect.Type, block string) *Schema {
+	body, _ := gohcl.ImpliedBodySchema(reflect.New(t).Interface())
+	s := &Schema{Body: body}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, ok := field.Tag.Lookup("hcl")
+		if !ok {
+			continue
+		}
+		name, kind, _ := strings.Cut(tag, ",")
+
+		switch kind {
+		case "", "attr", "optional":
+			key := name
+			if len(block) > 0 {
+				key = block + "." + name
+			}
+			doc := schemaDocs[key]
+			ty, err := gocty.ImpliedType(reflect.Zero(field.Type).Interface())
+			if doc.ty != cty.NilType {
+				ty = doc.ty
+			} else if err != nil {
+				ty = cty.DynamicPseudoType
+			}
+			attr := SchemaAttribute{
+				Name:        name,
+				Type:        ty,
+				Required:    kind != "optional",
+				Default:     doc.def,
+				Description: doc.description,
+			}
+			if doc.enum != nil {
+				attr.Enum = doc.enum()
+			}
+			s.Attributes = append(s.Attributes, attr)
+		case "block":
+			ft := field.Type
+			repeatable := ft.Kind() == reflect.Slice
+			if ft.Kind() == reflect.Ptr || repeatable {
+				ft = ft.Elem()
+			}
+			var labels []string
+			for j := 0; j < ft.NumField(); j++ {
+				if name, kind, _ := strings.Cut(ft.Field(j).Tag.Get("hcl"), ","); kind == "label" {
+					labels = append(labels, name)
+				}
+			}
+			s.Blocks = append(s.Blocks, SchemaBlock{
+				Type:        name,
+				Labels:      labels,
+				Repeatable:  repeatable,
+				Description: schemaBlockDocs[name],
+				Body:        newSchema(ft, name),
+			})
+		}
+	}
+	return s
+}
+
+// JSONSchema renders s as JSON Schema of configurations in HCL's JSON syntax,
+// e.g. to validate configuration files.
+func (s *Schema) JSONSchema() ([]byte, error) {
+	root := s.jsonSchema()
+	root["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	root["title"] = "klutz log configuration"
+
+	b, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("JSONSchema(): marshaling schema failed - %w", err)
+	}
+	return append(b, '\n'), nil
+}
+
+// jsonSchema returns the JSON Schema of an object representing the body.
+func (s *Schema) jsonSchema() map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []string{}
+
+	for _, attr := range s.Attributes {
+		prop := jsonSchemaType(attr.Type, attr.Enum)
+		if attr.Default != nil {
+			prop["default"] = attr.Default
+		}
+		if len(attr.Description) > 0 {
+			prop["description"] = attr.Description
+		}
+		properties[attr.Name] = prop
+		if attr.Required {
+			required = append(required, attr.Name)
+		}
+	}
+
+	for _, block := range s.Blocks {
+		prop := block.Body.jsonSchema()
+		// blocks are nested in objects keyed by their labels, and repeatable
+		// blocks may be given as arrays, cf. HCL's JSON syntax
+		for range block.Labels {
+			prop = map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": prop,
+			}
+		}
+		if block.Repeatable && len(block.Labels) <= 0 {
+			prop = map[string]interface{}{
+				"anyOf": []interface{}{prop, map[string]interface{}{"type": "array", "items": prop}},
+			}
+		}
+		if len(block.Description) > 0 {
+			prop["description"] = block.Description
+		}
+		properties[block.Type] = prop
+	}
+
+	schema := map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// jsonSchemaType returns the JSON Schema of values of type ty; enum restricts
+// primitive values or the elements of collections.
+func jsonSchemaType(ty cty.Type, enum []string) map[string]interface{} {
+	switch {
+	case ty == cty.String:
+		schema := map[string]interface{}{"type": "string"}
+		if len(enum) > 0 {
+			schema["enum"] = enum
+		}
+		return schema
+	case ty == cty.Number:
+		return map[string]interface{}{"type": "number"}
+	case ty == cty.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case ty.IsListType(), ty.IsSetType():
+		return map[string]interface{}{"type": "array", "items": jsonSchemaType(ty.ElementType(), enum)}
+	case ty.IsMapType():
+		return map[string]interface{}{"type": "object", "additionalProperties": jsonSchemaType(ty.ElementType(), enum)}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// Markdown renders s as Markdown documentation with a section per block type.
+func (s *Schema) Markdown() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("# Log configuration\n")
+	s.markdown(&buf, "", "")
+	return buf.Bytes()
+}
+
+// markdown writes the section of the body of the block type path.
+func (s *Schema) markdown(buf *bytes.Buffer, path, description string) {
+	buf.WriteString("\n")
+	if len(path) > 0 {
+		fmt.Fprintf(buf, "## Block `%s`\n\n", path)
+	}
+	if len(description) > 0 {
+		fmt.Fprintf(buf, "%s\n\n", description)
+	}
+
+	if len(s.Attributes) > 0 {
+		buf.WriteString("| Attribute | Type | Required | Default | Description |\n")
+		buf.WriteString("|-----------|------|----------|---------|-------------|\n")
+		for _, attr := range s.Attributes {
+			def := ""
+			if attr.Default != nil {
+				b, _ := json.Marshal(attr.Default)
+				def = "`" + string(b) + "`"
+			}
+			desc := attr.Description
+			if len(attr.Enum) > 0 {
+				values := make([]string, 0, len(attr.Enum))
+				for _, v := range attr.Enum {
+					values = append(values, "`"+v+"`")
+				}
+				desc = strings.TrimSpace(desc + " One of " + strings.Join(values, ", ") + ".")
+			}
+			required := "no"
+			if attr.Required {
+				required = "yes"
+			}
+			fmt.Fprintf(buf, "| `%s` | `%s` | %s | %s | %s |\n",
+				attr.Name, typeexpr.TypeString(attr.Type), required, def, strings.ReplaceAll(desc, "|", "\\|"))
+		}
+	}
+
+	if len(s.Blocks) > 0 {
+		if len(s.Attributes) > 0 {
+			buf.WriteString("\n")
+		}
+		buf.WriteString("| Block | Labels | Repeatable |\n")
+		buf.WriteString("|-------|--------|------------|\n")
+		for _, block := range s.Blocks {
+			labels := make([]string, 0, len(block.Labels))
+			for _, label := range block.Labels {
+				labels = append(labels, "`"+label+"`")
+			}
+			repeatable := "no"
+			if block.Repeatable {
+				repeatable = "yes"
+			}
+			fmt.Fprintf(buf, "| `%s` | %s | %s |\n", block.Type, strings.Join(labels, ", "), repeatable)
+		}
+	}
+
+	for _, block := range s.Blocks {
+		name := block.Type
+		if len(path) > 0 {
+			name = path + "." + name
+		}
+		block.Body.markdown(buf, name, block.Description)
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package log_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/sobchak-security/klutz/pkg/log"
+)
+
+func TestConfigSchema(t *testing.T) {
+	s := log.ConfigSchema()
+
+	// all attributes and blocks of the decoding schema are described and
+	// documented
+	var check func(path string, s *log.Schema)
+	check = func(path string, s *log.Schema) {
+		if len(s.Attributes) != len(s.Body.Attributes) || len(s.Blocks) != len(s.Body.Blocks) {
+			t.Errorf("ConfigSchema() %s: %d attributes and %d blocks, want %d and %d",
+				path, len(s.Attributes), len(s.Blocks), len(s.Body.Attributes), len(s.Body.Blocks))
+		}
+		for _, attr := range s.Attributes {
+			if len(attr.Description) <= 0 {
+				t.Errorf("ConfigSchema() %s%s: missing description", path, attr.Name)
+			}
+		}
+		for _, block := range s.Blocks {
+			if len(block.Description) <= 0 {
+				t.Errorf("ConfigSchema() %s%s: missing description", path, block.Type)
+			}
+			check(path+block.Type+".", block.Body)
+		}
+	}
+	check("", s)
+
+	attr := func(s *log.Schema, name string) log.SchemaAttribute {
+		t.Helper()
+		for _, attr := range s.Attributes {
+			if attr.Name == name {
+				return attr
+			}
+		}
+		t.Fatalf("ConfigSchema(): missing attribute %q", name)
+		return log.SchemaAttribute{}
+	}
+	block := func(s *log.Schema, name string) log.SchemaBlock {
+		t.Helper()
+		for _, block := range s.Blocks {
+			if block.Type == name {
+				return block
+			}
+		}
+		t.Fatalf("ConfigSchema(): missing block %q", name)
+		return log.SchemaBlock{}
+	}
+
+	levels := []string{"debug", "info", "warn", "error", "dpanic", "panic", "fatal"}
+	if got := attr(s, "level"); got.Default != "info" || !reflect.DeepEqual(got.Enum, levels) {
+		t.Errorf("ConfigSchema() level: default %v and enum %q, want %q and %q", got.Default, got.Enum, "info", levels)
+	}
+	if got := attr(s, "output_paths"); got.Type != cty.List(cty.String) || got.Required {
+		t.Errorf("ConfigSchema() output_paths: type %#v, required %t", got.Type, got.Required)
+	}
+	if got := attr(block(s, "sink").Body, "path"); !got.Required {
+		t.Errorf("ConfigSchema() sink.path: not required")
+	}
+	if got := block(s, "output"); !got.Repeatable || !reflect.DeepEqual(got.Labels, []string{"name"}) {
+		t.Errorf("ConfigSchema() output: repeatable %t and labels %q", got.Repeatable, got.Labels)
+	}
+
+	// enums include registered names
+	log.RestoreRegistries(t)
+	if err := log.RegisterLevelEncoder("schemaLevel", zapcore.CapitalLevelEncoder); err != nil {
+		t.Fatal(err)
+	}
+	enum := attr(block(log.ConfigSchema(), "encoder_config").Body, "level_encoder").Enum
+	if !strings.Contains(strings.Join(enum, ","), "schemaLevel") {
+		t.Errorf("ConfigSchema() encoder_config.level_encoder: enum %q without registered encoder", enum)
+	}
+}
+
+func TestConfigSchemaJSONSchema(t *testing.T) {
+	b, err := log.ConfigSchema().JSONSchema()
+	if err != nil {
+		t.Fatalf("JSONSchema() error = %v", err)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("JSONSchema(): invalid JSON - %v", err)
+	}
+	lookup := func(path string) interface{} {
+		var v interface{} = got
+		for _, key := range strings.Split(path, "/") {
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+			v = m[key]
+		}
+		return v
+	}
+
+	tests := []struct {
+		path string
+		want interface{}
+	}{
+		{"additionalProperties", false},
+		{"properties/level/default", "info"},
+		{"properties/initial_fields/type", "object"},
+		{"properties/output_paths/items/type", "string"},
+		{"properties/sampling/properties/initial/type", "number"},
+		{"properties/encoder_config/properties/skip_line_ending/type", "boolean"},
+		{"properties/redact/properties/presets/items/enum", []interface{}{"aws_access_key", "credit_card", "jwt"}},
+		{"properties/output/type", "object"},
+		{"properties/output/additionalProperties/required", []interface{}{"path"}},
+		{"properties/output/additionalProperties/properties/encoder_config/type", "object"},
+	}
+	for _, tt := range tests {
+		if got := lookup(tt.path); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("JSONSchema() %s = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+	if sink, ok := lookup("properties/sink/anyOf").([]interface{}); !ok || len(sink) != 2 {
+		t.Errorf("JSONSchema() properties/sink/anyOf = %v, want object or array", lookup("properties/sink/anyOf"))
+	}
+}
+
+func TestConfigSchemaMarkdown(t *testing.T) {
+	got := string(log.ConfigSchema().Markdown())
+
+	for _, want := range []string{
+		"# Log configuration\n",
+		"| `level` | `string` | no | `\"info\"` | The minimum enabled level. One of `debug`, `info`, `warn`, `error`, `dpanic`, `panic`, `fatal`. |\n",
+		"| `output_paths` | `list(string)` | no |  |",
+		"| `output` | `name` | yes |\n",
+		"## Block `sink`\n",
+		"| `path` | `string` | yes |  | The path of the log file. |\n",
+		"## Block `output.encoder_config`\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Markdown() does not contain %q", want)
+		}
+	}
+}
@@ -0,0 +1,360 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package log
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// RotateScheme is the URL scheme of rotating file sinks, which can be used in
+// the output paths of a zap.Config, e.g.
+//
+//	rotate:///var/log/app.log?max_size=100&max_backups=3&compress=true
+//
+// Relative paths are given by the query parameter path instead, e.g.
+//
+//	rotate:///?path=logs%2Fapp.log&max_size=100
+//
+// The supported query parameters are described by RotateOptions. All sinks of
+// a file share a single lumberjack.Logger, which is closed with the last of
+// them; the options of the sink opened last apply to all of them.
+const RotateScheme = "rotate"
+
+func init() {
+	if err := zap.RegisterSink(RotateScheme, newRotateSink); err != nil {
+		panic(fmt.Sprintf("registering sink %q failed - %v", RotateScheme, err))
+	}
+}
+
+// RotateOptions configures a rotating file sink.
+type RotateOptions struct {
+	// MaxSize is the maximum size in megabytes of the log file before it
+	// gets rotated, query parameter max_size; defaults to 100 megabytes.
+	MaxSize int
+	// MaxAge is the maximum number of days to retain rotated files, query
+	// parameter max_age; the default is not to remove files based on age.
+	MaxAge int
+	// MaxBackups is the maximum number of rotated files to retain, query
+	// parameter max_backups; the default is to retain all files.
+	MaxBackups int
+	// Compress determines, whether rotated files are compressed using gzip,
+	// query parameter compress.
+	Compress bool
+	// LocalTime determines, whether the timestamps of rotated files are
+	// formatted in local time rather than UTC, query parameter local_time.
+	LocalTime bool
+	// RotateOnSIGHUP determines, whether the file is rotated when the
+	// process receives SIGHUP, query parameter rotate_on_sighup.
+	RotateOnSIGHUP bool
+}
+
+// RotateURL returns the URL of a rotating file sink writing to path.
+func RotateURL(path string, opts RotateOptions) string {
+	q := url.Values{}
+	for k, v := range map[string]int{
+		"max_size":    opts.MaxSize,
+		"max_age":     opts.MaxAge,
+		"max_backups": opts.MaxBackups,
+	} {
+		if v > 0 {
+			q.Set(k, strconv.Itoa(v))
+		}
+	}
+	for k, v := range map[string]bool{
+		"compress":         opts.Compress,
+		"local_time":       opts.LocalTime,
+		"rotate_on_sighup": opts.RotateOnSIGHUP,
+	} {
+		if v {
+			q.Set(k, strconv.FormatBool(v))
+		}
+	}
+
+	u := url.URL{Scheme: RotateScheme, Path: path}
+	if len(path) > 0 && path[0] != '/' {
+		// relative paths would be taken for the host, cf. parseRotateURL
+		q.Set("path", path)
+		u.Path = "/"
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// parseRotateURL returns the path and options of a rotating file sink given by
+// u, cf. RotateURL.
+func parseRotateURL(u *url.URL) (string, RotateOptions, error) {
+	if u.User != nil || len(u.Fragment) > 0 {
+		return "", RotateOptions{}, fmt.Errorf("user, password and fragments not allowed with %s URLs: got %v", RotateScheme, u)
+	}
+
+	// relative paths are either given by the query parameter path, cf.
+	// RotateURL, or by host and path, e.g. rotate://logs/app.log
+	q := u.Query()
+	path := u.Host + u.Path
+	if q.Has("path") {
+		if len(u.Host) > 0 || (len(u.Path) > 0 && u.Path != "/") {
+			return "", RotateOptions{}, fmt.Errorf("path and query parameter path of %s URL are mutually exclusive: got %v", RotateScheme, u)
+		}
+		path = q.Get("path")
+	}
+	if len(path) <= 0 {
+		return "", RotateOptions{}, fmt.Errorf("missing path of %s URL: got %v", RotateScheme, u)
+	}
+
+	var opts RotateOptions
+	for k, vs := range q {
+		v := vs[len(vs)-1]
+
+		var err error
+		switch k {
+		case "path":
+		case "max_size":
+			opts.MaxSize, err = strconv.Atoi(v)
+		case "max_age":
+			opts.MaxAge, err = strconv.Atoi(v)
+		case "max_backups":
+			opts.MaxBackups, err = strconv.Atoi(v)
+		case "compress":
+			opts.Compress, err = strconv.ParseBool(v)
+		case "local_time":
+			opts.LocalTime, err = strconv.ParseBool(v)
+		case "rotate_on_sighup":
+			opts.RotateOnSIGHUP, err = strconv.ParseBool(v)
+		default:
+			err = fmt.Errorf("unknown query parameter")
+		}
+		if err != nil {
+			return "", RotateOptions{}, fmt.Errorf("invalid query parameter %s=%q of %s URL - %w", k, v, RotateScheme, err)
+		}
+	}
+	return path, opts, nil
+}
+
+// rotateFiles keeps the files of rotating file sinks by absolute path, so
+// that all sinks of a file, e.g. of the previous and the current core of a
+// Reloader, share a single lumberjack.Logger.
+var rotateFiles = struct {
+	sync.Mutex
+	m map[string]*rotateFile
+}{m: map[string]*rotateFile{}}
+
+// rotateFile is a file rotated by lumberjack, which is shared by all sinks
+// writing to it and closed with the last one.
+type rotateFile struct {
+	path string
+	refs int // guarded by rotateFiles
+
+	mu      sync.Mutex
+	logger  *lumberjack.Logger
+	opts    RotateOptions
+	signals chan os.Signal
+	closed  bool
+}
+
+// openRotateFile returns the file path, which is configured by opts, and
+// increments its references.
+func openRotateFile(path string, opts RotateOptions) (*rotateFile, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("resolving path %q of %s URL failed - %w", path, RotateScheme, err)
+	}
+
+	rotateFiles.Lock()
+	defer rotateFiles.Unlock()
+
+	f, ok := rotateFiles.m[abs]
+	if !ok {
+		f = &rotateFile{path: abs}
+		rotateFiles.m[abs] = f
+	}
+	f.refs++
+	f.configure(opts)
+	return f, nil
+}
+
+// configure applies opts to f; as lumberjack reads its options concurrently,
+// the lumberjack.Logger is replaced, if they changed. The options of the sink
+// opened last apply to all sinks of the file.
+func (f *rotateFile) configure(opts RotateOptions) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.logger != nil && opts == f.opts {
+		return
+	}
+	if f.logger != nil {
+		_ = f.logger.Close()
+	}
+	f.logger = &lumberjack.Logger{
+		Filename:   f.path,
+		MaxSize:    opts.MaxSize,
+		MaxAge:     opts.MaxAge,
+		MaxBackups: opts.MaxBackups,
+		LocalTime:  opts.LocalTime,
+		Compress:   opts.Compress,
+	}
+	f.opts = opts
+
+	switch {
+	case opts.RotateOnSIGHUP && f.signals == nil:
+		f.signals = make(chan os.Signal, 1)
+		signal.Notify(f.signals, syscall.SIGHUP)
+		go func(signals chan os.Signal) {
+			for range signals {
+				_ = f.Rotate()
+			}
+		}(f.signals)
+	case !opts.RotateOnSIGHUP && f.signals != nil:
+		f.stopSignals()
+	}
+}
+
+// stopSignals stops rotating f on SIGHUP; callers must hold the lock.
+func (f *rotateFile) stopSignals() {
+	signal.Stop(f.signals)
+	close(f.signals)
+	f.signals = nil
+}
+
+// Write writes p to the file, which is rotated, if necessary.
+func (f *rotateFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+	return f.logger.Write(p)
+}
+
+// Rotate rotates the file, cf. lumberjack.Logger.Rotate. A closed file is not
+// rotated, as lumberjack would reopen it, e.g. on a SIGHUP, which has been
+// received before the file was closed.
+func (f *rotateFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return os.ErrClosed
+	}
+	return f.logger.Rotate()
+}
+
+// release decrements the references of f and closes it with the last one.
+func (f *rotateFile) release() error {
+	rotateFiles.Lock()
+	defer rotateFiles.Unlock()
+
+	if f.refs--; f.refs > 0 {
+		return nil
+	}
+	delete(rotateFiles.m, f.path)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.signals != nil {
+		f.stopSignals()
+	}
+	f.closed = true
+	return f.logger.Close()
+}
+
+// rotateSink is a zap.Sink writing to a shared rotating file.
+type rotateSink struct {
+	*rotateFile
+
+	once sync.Once
+}
+
+func newRotateSink(u *url.URL) (zap.Sink, error) {
+	path, opts, err := parseRotateURL(u)
+	if err != nil {
+		return nil, err
+	}
+	f, err := openRotateFile(path, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &rotateSink{rotateFile: f}, nil
+}
+
+// Sync implements the zap.Sink interface; lumberjack does not buffer writes.
+func (s *rotateSink) Sync() error {
+	return nil
+}
+
+// Close implements the zap.Sink interface; the file is closed with the last
+// sink writing to it.
+func (s *rotateSink) Close() error {
+	var err error
+	s.once.Do(func() {
+		err = s.release()
+	})
+	return err
+}
+
+// netSink is the connection of a sink to a network address, which is
+// re-established once, if writing fails, e.g. after the server closed it.
+type netSink struct {
+	mu      sync.Mutex
+	network string
+	address string
+	conn    net.Conn
+}
+
+func (s *netSink) dial() error {
+	conn, err := net.DialTimeout(s.network, s.address, 5*time.Second)
+	if err != nil {
+		return fmt.Errorf("connecting to %s://%s failed - %w", s.network, s.address, err)
+	}
+	s.conn = conn
+	return nil
+}
+
+// write writes p to the connection; callers must hold the lock.
+func (s *netSink) write(p []byte) error {
+	if s.conn != nil {
+		if _, err := s.conn.Write(p); err == nil {
+			return nil
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+	if err := s.dial(); err != nil {
+		return err
+	}
+	_, err := s.conn.Write(p)
+	return err
+}
+
+// Sync implements the zap.Sink interface.
+func (s *netSink) Sync() error {
+	return nil
+}
+
+// Close implements the zap.Sink interface.
+func (s *netSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
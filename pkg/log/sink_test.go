@@ -0,0 +1,283 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package log_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/sobchak-security/klutz/pkg/log"
+)
+
+func TestRotateURL(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		opts log.RotateOptions
+		want string
+	}{
+		{
+			name: "success: absolute path without options",
+			path: "/var/log/app.log",
+			want: "rotate:///var/log/app.log",
+		},
+		{
+			name: "success: absolute path with options",
+			path: "/var/log/app.log",
+			opts: log.RotateOptions{MaxSize: 10, MaxBackups: 3, Compress: true},
+			want: "rotate:///var/log/app.log?compress=true&max_backups=3&max_size=10",
+		},
+		{
+			name: "success: relative path",
+			path: "logs/app.log",
+			opts: log.RotateOptions{RotateOnSIGHUP: true},
+			want: "rotate:///?path=logs%2Fapp.log&rotate_on_sighup=true",
+		},
+		{
+			name: "success: absolute path with special characters",
+			path: "/var/log/a:b 50%?#.log",
+			want: "rotate:///var/log/a:b%2050%25%3F%23.log",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := log.RotateURL(tt.path, tt.opts); got != tt.want {
+				t.Errorf("RotateURL(): %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRotateURLRoundTrip(t *testing.T) {
+	for _, path := range []string{
+		"/var/log/app.log",
+		"/var/log/a:b 50%?#.log",
+		"app.log",
+		"logs/app.log",
+		"host:8080/app.log",
+		"logs/a:b 50%?#.log",
+		`C:\logs\app.log`,
+	} {
+		t.Run("success: "+path, func(t *testing.T) {
+			opts := log.RotateOptions{MaxSize: 10, Compress: true}
+			cfg := log.Config{Config: log.StdConfig()}
+			cfg.OutputPaths = []string{log.RotateURL(path, opts)}
+
+			b, err := cfg.MarshalHCL()
+			if err != nil {
+				t.Fatalf("MarshalHCL() error = %v", err)
+			}
+			if want := fmt.Sprintf("%q", path); !strings.Contains(string(b), want) {
+				t.Errorf("MarshalHCL() = %s, want %s", b, want)
+			}
+
+			again := testUnmarshalHCL(t, b, "rotate.hcl")
+			if strings.Join(again.OutputPaths, " ") != strings.Join(cfg.OutputPaths, " ") {
+				t.Errorf("UnmarshalHCL() [OutputPaths]: %q, want %q", again.OutputPaths, cfg.OutputPaths)
+			}
+		})
+	}
+
+	t.Run("success: file with special characters", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "a:b 50%?#.log")
+		sink, closeSink, err := zap.Open(log.RotateURL(path, log.RotateOptions{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := sink.Write([]byte("written\n")); err != nil {
+			t.Fatal(err)
+		}
+		closeSink()
+		testMatchLog(t, testReadLog(t, path), `^written\n$`)
+	})
+}
+
+func TestRotateSink(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	tests := []struct {
+		name    string
+		url     string
+		wantErr bool
+	}{
+		{name: "failure: unknown parameter", url: "rotate://" + path + "?max_files=3", wantErr: true},
+		{name: "failure: invalid number", url: "rotate://" + path + "?max_size=large", wantErr: true},
+		{name: "failure: missing path", url: "rotate://", wantErr: true},
+		{name: "success: all parameters", url: "rotate://" + path +
+			"?max_size=1&max_age=1&max_backups=1&compress=false&local_time=true&rotate_on_sighup=true"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := zap.NewProductionConfig()
+			cfg.OutputPaths = []string{tt.url}
+
+			l, err := cfg.Build()
+			if err != nil {
+				if !tt.wantErr {
+					t.Errorf("Build() error = %v, wantErr %v", err, false)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Fatalf("Build() error = %v, wantErr %v", nil, true)
+			}
+			l.Info("written")
+		})
+	}
+}
+
+func TestRotateSinkShared(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	u := log.RotateURL(path, log.RotateOptions{MaxSize: 1})
+
+	first, closeFirst, err := zap.Open(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// paths are cleaned
+	second, closeSecond, err := zap.Open(log.RotateURL(dir+"/./app.log", log.RotateOptions{MaxSize: 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the file is rotated, once both sinks together exceed the maximum size
+	for _, w := range []zapcore.WriteSyncer{first, second, first, second} {
+		if _, err := w.Write(bytes.Repeat([]byte("x"), 300*1024)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("Write(): %d files, want the rotated and the current one", len(entries))
+	}
+
+	// the file remains open until the last sink is closed
+	closeFirst()
+	if _, err := second.Write([]byte("after close\n")); err != nil {
+		t.Errorf("Write() error = %v after closing another sink", err)
+	}
+	closeSecond()
+	testMatchLog(t, testReadLog(t, path), `x*after close\n$`)
+}
+
+func TestRotateSinkHCL(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	hf, diags := hclparse.NewParser().ParseHCL([]byte(fmt.Sprintf(`
+		encoding = "json"
+		output_paths = [ "stderr" ]
+		encoder_config {
+			message_key = "msg"
+		}
+		sink {
+			path = %q
+			max_backups = 2
+			rotate_on_sighup = true
+		}`, path)), "")
+	if diags.HasErrors() {
+		t.Fatalf("parsing config failed %v", diags)
+	}
+
+	var cfg zap.Config
+	if err := (*log.ConfigWrapper)(&cfg).UnmarshalHCL(nil, hf.Body); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"stderr", "rotate://" + path + "?max_backups=2&rotate_on_sighup=true"}
+	if strings.Join(cfg.OutputPaths, " ") != strings.Join(want, " ") {
+		t.Fatalf("UnmarshalHCL() [OutputPaths]: %q, want %q", cfg.OutputPaths, want)
+	}
+
+	cfg.OutputPaths = cfg.OutputPaths[1:]
+	l, err := cfg.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Info("before rotation")
+
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Signal(syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	testWaitFor(t, func() bool {
+		entries, err := os.ReadDir(dir)
+		return err == nil && len(entries) == 2
+	})
+	l.Info("after rotation")
+
+	testMatchLog(t, testReadLog(t, path), `^{"msg":"after rotation"}\n$`)
+}
+
+func TestRotateSinkClosedOnSIGHUP(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	sink, closeSink, err := zap.Open(log.RotateURL(path, log.RotateOptions{RotateOnSIGHUP: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sink.Write([]byte("written\n")); err != nil {
+		t.Fatal(err)
+	}
+	closeSink()
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// a closed file must not be reopened by rotating it; the process must not
+	// be terminated by SIGHUP, once no sink is notified anymore
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Signal(syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) > 0 {
+		t.Errorf("Rotate() after close: %d files, %v, want none", len(entries), err)
+	}
+	if _, err := sink.Write([]byte("after close\n")); err == nil {
+		t.Errorf("Write() error = %v after close, want error", err)
+	}
+}
+
+func TestRotateSinkHCLDiagnostics(t *testing.T) {
+	hf, diags := hclparse.NewParser().ParseHCL([]byte(`
+		sink {
+			path = "app.log"
+			max_size = -1
+		}`), "test.hcl")
+	if diags.HasErrors() {
+		t.Fatalf("parsing config failed %v", diags)
+	}
+
+	var cfg zap.Config
+	err := (*log.ConfigWrapper)(&cfg).UnmarshalHCL(nil, hf.Body)
+	if err == nil || !strings.Contains(err.Error(), "test.hcl:4") {
+		t.Errorf("UnmarshalHCL() error = %v, want diagnostic at test.hcl:4", err)
+	}
+}
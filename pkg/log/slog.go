@@ -0,0 +1,182 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package log
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// SlogHandlerOptions configure a SlogHandler.
+type SlogHandlerOptions struct {
+	// Name is the logger name of all entries.
+	Name string
+	// AddSource adds the caller of the log call to all entries.
+	AddSource bool
+}
+
+// SlogHandler is a slog.Handler, which writes records to a zapcore.Core, e.g.
+// the core of a logger built from a configuration loaded by Config.LoadFile:
+//
+//	slog.SetDefault(slog.New(log.NewSlogHandler(l.Core(), log.SlogHandlerOptions{})))
+//
+// Attributes are converted to fields, groups to nested objects, and levels
+// to the closest zap level not above them, e.g. slog.LevelWarn+1 to warn.
+type SlogHandler struct {
+	core   zapcore.Core
+	opts   SlogHandlerOptions
+	groups []string
+}
+
+// NewSlogHandler creates a SlogHandler writing to core.
+func NewSlogHandler(core zapcore.Core, opts SlogHandlerOptions) *SlogHandler {
+	return &SlogHandler{core: core, opts: opts}
+}
+
+// Enabled implements the slog.Handler interface.
+func (h *SlogHandler) Enabled(_ context.Context, lvl slog.Level) bool {
+	return h.core.Enabled(slogLevel(lvl))
+}
+
+// Handle implements the slog.Handler interface.
+func (h *SlogHandler) Handle(_ context.Context, r slog.Record) error {
+	ent := zapcore.Entry{
+		LoggerName: h.opts.Name,
+		Time:       r.Time,
+		Level:      slogLevel(r.Level),
+		Message:    r.Message,
+	}
+	if h.opts.AddSource && r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		ent.Caller = zapcore.EntryCaller{
+			Defined:  true,
+			PC:       frame.PC,
+			File:     frame.File,
+			Line:     frame.Line,
+			Function: frame.Function,
+		}
+	}
+
+	ce := h.core.Check(ent, nil)
+	if ce == nil {
+		return nil
+	}
+
+	fields := make([]zapcore.Field, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		fields = appendSlogAttr(fields, a)
+		return true
+	})
+	ce.Write(h.withGroups(fields)...)
+	return nil
+}
+
+// WithAttrs implements the slog.Handler interface.
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var fields []zapcore.Field
+	for _, a := range attrs {
+		fields = appendSlogAttr(fields, a)
+	}
+	if len(fields) <= 0 {
+		return h
+	}
+	return &SlogHandler{core: h.core.With(h.withGroups(fields)), opts: h.opts}
+}
+
+// WithGroup implements the slog.Handler interface.
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if len(name) <= 0 {
+		return h
+	}
+	groups := make([]string, len(h.groups), len(h.groups)+1)
+	copy(groups, h.groups)
+	return &SlogHandler{core: h.core, opts: h.opts, groups: append(groups, name)}
+}
+
+// withGroups prefixes non-empty fields with namespaces of the groups, which
+// have been opened by WithGroup since the last call of WithAttrs; empty
+// groups are omitted, cf. slog.Handler.
+func (h *SlogHandler) withGroups(fields []zapcore.Field) []zapcore.Field {
+	if len(fields) <= 0 || len(h.groups) <= 0 {
+		return fields
+	}
+	nested := make([]zapcore.Field, 0, len(h.groups)+len(fields))
+	for _, group := range h.groups {
+		nested = append(nested, zap.Namespace(group))
+	}
+	return append(nested, fields...)
+}
+
+// slogLevel converts lvl to the closest zap level not above it.
+func slogLevel(lvl slog.Level) zapcore.Level {
+	switch {
+	case lvl >= slog.LevelError:
+		return zapcore.ErrorLevel
+	case lvl >= slog.LevelWarn:
+		return zapcore.WarnLevel
+	case lvl >= slog.LevelInfo:
+		return zapcore.InfoLevel
+	default:
+		return zapcore.DebugLevel
+	}
+}
+
+// appendSlogAttr appends the field of a to fields; empty attributes and groups
+// are omitted, and the attributes of groups without a key are inlined.
+func appendSlogAttr(fields []zapcore.Field, a slog.Attr) []zapcore.Field {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return fields
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindBool:
+		return append(fields, zap.Bool(a.Key, a.Value.Bool()))
+	case slog.KindDuration:
+		return append(fields, zap.Duration(a.Key, a.Value.Duration()))
+	case slog.KindFloat64:
+		return append(fields, zap.Float64(a.Key, a.Value.Float64()))
+	case slog.KindInt64:
+		return append(fields, zap.Int64(a.Key, a.Value.Int64()))
+	case slog.KindString:
+		return append(fields, zap.String(a.Key, a.Value.String()))
+	case slog.KindTime:
+		return append(fields, zap.Time(a.Key, a.Value.Time()))
+	case slog.KindUint64:
+		return append(fields, zap.Uint64(a.Key, a.Value.Uint64()))
+	case slog.KindGroup:
+		attrs := a.Value.Group()
+		if len(attrs) <= 0 {
+			return fields
+		}
+		if len(a.Key) <= 0 {
+			for _, attr := range attrs {
+				fields = appendSlogAttr(fields, attr)
+			}
+			return fields
+		}
+		return append(fields, zap.Object(a.Key, slogGroup(attrs)))
+	default:
+		return append(fields, zap.Any(a.Key, a.Value.Any()))
+	}
+}
+
+// slogGroup marshals the attributes of a group as object.
+type slogGroup []slog.Attr
+
+// MarshalLogObject implements the zapcore.ObjectMarshaler interface.
+func (g slogGroup) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	var fields []zapcore.Field
+	for _, a := range g {
+		fields = appendSlogAttr(fields, a)
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	return nil
+}
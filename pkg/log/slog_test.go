@@ -0,0 +1,118 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package log_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/sobchak-security/klutz/pkg/log"
+)
+
+func TestSlogHandler(t *testing.T) {
+	tests := []struct {
+		name string
+		log  func(l *slog.Logger)
+		want string
+	}{
+		{
+			name: "success: attributes",
+			log: func(l *slog.Logger) {
+				l.Info("msg", "s", "v", "i", -1, "u", uint64(2), "f", 1.5, "b", true,
+					"d", time.Second, "t", time.Unix(0, 0).UTC(), "e", errors.New("failed"))
+			},
+			want: `{"level":"info","msg":"msg","s":"v","i":-1,"u":2,"f":1.5,"b":true,"d":1,"t":0,"e":"failed"}`,
+		},
+		{
+			name: "success: levels",
+			log: func(l *slog.Logger) {
+				l.Debug("debug")
+				l.Log(context.Background(), slog.LevelInfo+1, "info")
+				l.Warn("warn")
+				l.Log(context.Background(), slog.LevelError+4, "error")
+			},
+			want: `{"level":"debug","msg":"debug"}
+{"level":"info","msg":"info"}
+{"level":"warn","msg":"warn"}
+{"level":"error","msg":"error"}`,
+		},
+		{
+			name: "success: groups",
+			log: func(l *slog.Logger) {
+				l.With("a", 1).WithGroup("g").With("b", 2).WithGroup("h").WithGroup("i").
+					Info("msg", slog.Group("j", "c", 3), slog.Group("", "d", 4), slog.Group("empty"))
+			},
+			want: `{"level":"info","msg":"msg","a":1,"g":{"b":2,"h":{"i":{"j":{"c":3},"d":4}}}}`,
+		},
+		{
+			name: "success: empty groups omitted",
+			log: func(l *slog.Logger) {
+				l.WithGroup("g").With().Info("msg", slog.Attr{})
+			},
+			want: `{"level":"info","msg":"msg"}`,
+		},
+		{
+			name: "success: log valuer",
+			log: func(l *slog.Logger) {
+				l.Info("msg", "token", testToken("secret"))
+			},
+			want: `{"level":"info","msg":"msg","token":"[TOKEN]"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			core := zapcore.NewCore(zapcore.NewJSONEncoder(zapcore.EncoderConfig{
+				LevelKey:       "level",
+				MessageKey:     "msg",
+				EncodeLevel:    zapcore.LowercaseLevelEncoder,
+				EncodeTime:     zapcore.EpochTimeEncoder,
+				EncodeDuration: zapcore.SecondsDurationEncoder,
+			}), zapcore.AddSync(&buf), zapcore.DebugLevel)
+
+			tt.log(slog.New(log.NewSlogHandler(core, log.SlogHandlerOptions{})))
+
+			if got := buf.String(); got != tt.want+"\n" {
+				t.Errorf("SlogHandler wrote %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+type testToken string
+
+func (testToken) LogValue() slog.Value { return slog.StringValue("[TOKEN]") }
+
+func TestSlogHandlerOptions(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	l := slog.New(log.NewSlogHandler(core, log.SlogHandlerOptions{Name: "slog", AddSource: true}))
+
+	if l.Enabled(context.Background(), slog.LevelInfo) {
+		t.Errorf("Enabled(info) = true, want false")
+	}
+	l.Info("info")
+	l.Warn("warn")
+
+	got := logs.All()
+	if len(got) != 1 {
+		t.Fatalf("SlogHandler logged %d entries, want 1", len(got))
+	}
+	if got[0].LoggerName != "slog" || got[0].Message != "warn" {
+		t.Errorf("SlogHandler logged %q by %q, want %q by %q", got[0].Message, got[0].LoggerName, "warn", "slog")
+	}
+	if file := filepath.Base(got[0].Caller.File); file != "slog_test.go" {
+		t.Errorf("SlogHandler caller = %s, want slog_test.go", got[0].Caller)
+	}
+	if got[0].Time.IsZero() {
+		t.Errorf("SlogHandler entry time is zero")
+	}
+}
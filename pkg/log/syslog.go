@@ -0,0 +1,344 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package log
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// Syslog related encoding and sink schemes. The sinks expect a single message
+// per write, as written by zap's cores; messages are sent as datagrams via
+// UDP and unix datagram sockets, with octet counting framing via TCP (cf. RFC
+// 6587) and delimited by newlines via unix stream sockets, e.g.
+//
+//	syslog+udp://localhost:514
+//	syslog+tcp://localhost:601
+//	unix:///dev/log
+const (
+	SyslogEncoding   = "syslog"
+	SyslogUDPScheme  = "syslog+udp"
+	SyslogTCPScheme  = "syslog+tcp"
+	SyslogUnixScheme = "unix"
+
+	// syslogSDID is the ID of the structured data element containing the
+	// fields of an entry, using the enterprise number reserved for
+	// documentation, cf. RFC 5612.
+	syslogSDID = "fields@32473"
+)
+
+// Syslog message formats, cf. SyslogOptions.
+const (
+	RFC5424 = "rfc5424"
+	RFC3164 = "rfc3164"
+)
+
+// syslogFacilities maps facility names to their codes, cf. RFC 5424.
+var syslogFacilities = map[string]int{
+	"kern":     0,
+	"user":     1,
+	"mail":     2,
+	"daemon":   3,
+	"auth":     4,
+	"syslog":   5,
+	"lpr":      6,
+	"news":     7,
+	"uucp":     8,
+	"cron":     9,
+	"authpriv": 10,
+	"ftp":      11,
+	"local0":   16,
+	"local1":   17,
+	"local2":   18,
+	"local3":   19,
+	"local4":   20,
+	"local5":   21,
+	"local6":   22,
+	"local7":   23,
+}
+
+func init() {
+	if err := RegisterEncoding(SyslogEncoding, func(ec zapcore.EncoderConfig) (zapcore.Encoder, error) {
+		return NewSyslogEncoder(ec, SyslogOptions{})
+	}); err != nil {
+		panic(fmt.Sprintf("registering encoding %q failed - %v", SyslogEncoding, err))
+	}
+	for _, scheme := range []string{SyslogUDPScheme, SyslogTCPScheme, SyslogUnixScheme} {
+		if err := zap.RegisterSink(scheme, newSyslogSink); err != nil {
+			panic(fmt.Sprintf("registering sink %q failed - %v", scheme, err))
+		}
+	}
+}
+
+// SyslogOptions configures the syslog encoder.
+type SyslogOptions struct {
+	// Facility is the name of the facility, e.g. "daemon" or "local0";
+	// defaults to "user".
+	Facility string
+	// AppName identifies the application; defaults to the name of the
+	// executable.
+	AppName string
+	// Hostname defaults to the hostname determined by LookupEnv.
+	Hostname string
+	// Format is either RFC5424, the default, or RFC3164.
+	Format string
+}
+
+// validate checks the facility and format of opts.
+func (opts SyslogOptions) validate() error {
+	if _, ok := syslogFacilities[opts.Facility]; len(opts.Facility) > 0 && !ok {
+		names := make([]string, 0, len(syslogFacilities))
+		for name := range syslogFacilities {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown syslog facility %q, valid facilities are %q", opts.Facility, names)
+	}
+	switch opts.Format {
+	case "", RFC5424, RFC3164:
+	default:
+		return fmt.Errorf("unknown syslog format %q, valid formats are %q", opts.Format, []string{RFC5424, RFC3164})
+	}
+	return nil
+}
+
+// syslogEncoder is a zapcore.Encoder producing syslog messages.
+type syslogEncoder struct {
+	*flatEncoder
+	ec       zapcore.EncoderConfig
+	facility int
+	appName  string
+	hostname string
+	procID   string
+	rfc3164  bool
+}
+
+// NewSyslogEncoder creates a syslog encoder. Fields are added as parameters of
+// a structured data element in RFC 5424 format, or appended to the message as
+// key="value" pairs in RFC 3164 format; keys of nested objects are joined by
+// dots. The time, level, and message keys of ec are ignored, the name of the
+// logger is used as message ID, the caller is added as field, if the caller
+// key is set, and the stacktrace is appended to the message, if the
+// stacktrace key is set.
+func NewSyslogEncoder(ec zapcore.EncoderConfig, opts SyslogOptions) (zapcore.Encoder, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	e := &syslogEncoder{
+		ec:       ec,
+		facility: syslogFacilities["user"],
+		appName:  opts.AppName,
+		hostname: opts.Hostname,
+		procID:   strconv.Itoa(os.Getpid()),
+		rfc3164:  opts.Format == RFC3164,
+	}
+	e.flatEncoder = newFlatEncoder(&e.ec, ".")
+	if len(opts.Facility) > 0 {
+		e.facility = syslogFacilities[opts.Facility]
+	}
+	if len(e.appName) <= 0 {
+		e.appName = filepath.Base(os.Args[0])
+	}
+	if len(e.hostname) <= 0 {
+		e.hostname, _ = LookupEnv("HOSTNAME")
+	}
+	return e, nil
+}
+
+// Clone implements the zapcore.Encoder interface.
+func (e *syslogEncoder) Clone() zapcore.Encoder {
+	return e.clone()
+}
+
+func (e *syslogEncoder) clone() *syslogEncoder {
+	c := *e
+	c.flatEncoder = e.flatEncoder.clone()
+	c.flatEncoder.ec = &c.ec
+	return &c
+}
+
+// EncodeEntry implements the zapcore.Encoder interface.
+func (e *syslogEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	final := e.clone()
+	if ent.Caller.Defined && len(e.ec.CallerKey) > 0 {
+		caller := ent.Caller.TrimmedPath()
+		if e.ec.EncodeCaller != nil {
+			if s, ok := encodeText(func(enc zapcore.PrimitiveArrayEncoder) { e.ec.EncodeCaller(ent.Caller, enc) }); ok {
+				caller = s
+			}
+		}
+		final.fields = append([]flatField{{key: e.ec.CallerKey, value: caller}}, final.fields...)
+	}
+	for _, f := range fields {
+		f.AddTo(final)
+	}
+
+	buf := bufferPool.Get()
+	buf.AppendByte('<')
+	buf.AppendInt(int64(e.facility*8 + syslogSeverity(ent.Level)))
+	buf.AppendByte('>')
+
+	if e.rfc3164 {
+		buf.AppendString(ent.Time.Format("Jan _2 15:04:05"))
+		buf.AppendByte(' ')
+		buf.AppendString(syslogHeaderValue(e.hostname, 255))
+		buf.AppendByte(' ')
+		buf.AppendString(syslogHeaderValue(e.appName, 32))
+		buf.AppendString("[" + e.procID + "]: ")
+		buf.AppendString(ent.Message)
+		for _, f := range final.fields {
+			buf.AppendByte(' ')
+			appendSyslogParam(buf, f)
+		}
+	} else {
+		buf.AppendString("1 ")
+		buf.AppendString(ent.Time.Format("2006-01-02T15:04:05.000000Z07:00"))
+		buf.AppendByte(' ')
+		buf.AppendString(syslogHeaderValue(e.hostname, 255))
+		buf.AppendByte(' ')
+		buf.AppendString(syslogHeaderValue(e.appName, 48))
+		buf.AppendByte(' ')
+		buf.AppendString(syslogHeaderValue(e.procID, 128))
+		buf.AppendByte(' ')
+		buf.AppendString(syslogHeaderValue(ent.LoggerName, 32))
+		buf.AppendByte(' ')
+		if len(final.fields) <= 0 {
+			buf.AppendByte('-')
+		} else {
+			buf.AppendString("[" + syslogSDID)
+			for _, f := range final.fields {
+				buf.AppendByte(' ')
+				appendSyslogParam(buf, f)
+			}
+			buf.AppendByte(']')
+		}
+		if len(ent.Message) > 0 {
+			buf.AppendByte(' ')
+			buf.AppendString(ent.Message)
+		}
+	}
+
+	if len(ent.Stack) > 0 && len(e.ec.StacktraceKey) > 0 {
+		buf.AppendByte('\n')
+		buf.AppendString(ent.Stack)
+	}
+
+	if !e.ec.SkipLineEnding {
+		if len(e.ec.LineEnding) > 0 {
+			buf.AppendString(e.ec.LineEnding)
+		} else {
+			buf.AppendString(zapcore.DefaultLineEnding)
+		}
+	}
+	return buf, nil
+}
+
+// syslogHeaderValue returns s restricted to printable US-ASCII characters and
+// max characters, or the nil value "-", if s is empty.
+func syslogHeaderValue(s string, max int) string {
+	if len(s) <= 0 {
+		return "-"
+	}
+	b := []byte(s)
+	if len(b) > max {
+		b = b[:max]
+	}
+	for i, c := range b {
+		if c < 33 || c > 126 {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+// appendSyslogParam appends f as SD parameter, cf. RFC 5424, section 6.3.3.
+func appendSyslogParam(buf *buffer.Buffer, f flatField) {
+	name := []byte(f.key)
+	if len(name) > 32 {
+		name = name[:32]
+	}
+	for i, c := range name {
+		if c < 33 || c > 126 || c == '=' || c == ']' || c == '"' {
+			name[i] = '_'
+		}
+	}
+	buf.Write(name)
+	buf.AppendString(`="`)
+	for _, r := range f.value {
+		if r == '"' || r == '\\' || r == ']' {
+			buf.AppendByte('\\')
+		}
+		buf.AppendString(string(r))
+	}
+	buf.AppendByte('"')
+}
+
+// syslogSink is a zap.Sink shipping syslog messages, cf. SyslogUDPScheme.
+type syslogSink struct {
+	netSink
+}
+
+func newSyslogSink(u *url.URL) (zap.Sink, error) {
+	if u.User != nil || len(u.Fragment) > 0 || len(u.RawQuery) > 0 {
+		return nil, fmt.Errorf("user, password, query parameters and fragments not allowed with %s URLs: got %v", u.Scheme, u)
+	}
+
+	s := &syslogSink{}
+	switch u.Scheme {
+	case SyslogUnixScheme:
+		path := u.Host + u.Path
+		if len(path) <= 0 {
+			return nil, fmt.Errorf("missing path of %s URL: got %v", u.Scheme, u)
+		}
+		// syslog daemons usually listen on datagram sockets
+		s.network, s.address = "unixgram", path
+		if err := s.dial(); err != nil {
+			s.network = "unix"
+			if err := s.dial(); err != nil {
+				return nil, err
+			}
+		}
+		return s, nil
+	default:
+		if len(u.Host) <= 0 || len(u.Port()) <= 0 {
+			return nil, fmt.Errorf("missing host or port of %s URL: got %v", u.Scheme, u)
+		}
+		s.network, s.address = strings.TrimPrefix(u.Scheme, "syslog+"), u.Host
+	}
+
+	if err := s.dial(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Write implements the zap.Sink interface.
+func (s *syslogSink) Write(p []byte) (int, error) {
+	msg := strings.TrimRight(string(p), "\r\n")
+
+	switch s.network {
+	case "tcp":
+		msg = strconv.Itoa(len(msg)) + " " + msg
+	case "unix":
+		msg += "\n"
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.write([]byte(msg)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
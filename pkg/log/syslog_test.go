@@ -0,0 +1,227 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package log_test
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/sobchak-security/klutz/pkg/log"
+)
+
+func TestSyslogEncoder(t *testing.T) {
+	ent := zapcore.Entry{
+		Level:      zapcore.WarnLevel,
+		Time:       time.Date(2023, 1, 2, 3, 4, 5, 678000000, time.UTC),
+		LoggerName: "db",
+		Message:    "slow query",
+		Caller:     zapcore.NewEntryCaller(0, "/src/pkg/db/query.go", 42, true),
+	}
+	pid := strconv.Itoa(os.Getpid())
+
+	tests := []struct {
+		name   string
+		ec     zapcore.EncoderConfig
+		opts   log.SyslogOptions
+		fields []zap.Field
+		want   string
+	}{
+		{
+			name: "success: RFC 5424 without fields",
+			opts: log.SyslogOptions{AppName: "app", Hostname: "host"},
+			want: "<12>1 2023-01-02T03:04:05.678000Z host app " + pid + " db - slow query\n",
+		},
+		{
+			name: "success: RFC 5424 with fields and caller",
+			ec:   zapcore.EncoderConfig{CallerKey: "caller", EncodeCaller: zapcore.ShortCallerEncoder},
+			opts: log.SyslogOptions{Facility: "local0", AppName: "app", Hostname: "host"},
+			fields: []zap.Field{
+				zap.String("query", `SELECT "a]"`),
+				zap.Object("a", testObject{}),
+				zap.Duration("took", 1500*time.Millisecond),
+			},
+			want: "<132>1 2023-01-02T03:04:05.678000Z host app " + pid + " db " +
+				`[fields@32473 caller="db/query.go:42" query="SELECT \"a\]\"" a.b="c" a.d="1" took="1.5s"]` +
+				" slow query\n",
+		},
+		{
+			name:   "success: RFC 3164",
+			ec:     zapcore.EncoderConfig{SkipLineEnding: true},
+			opts:   log.SyslogOptions{Facility: "daemon", AppName: "app", Hostname: "host", Format: log.RFC3164},
+			fields: []zap.Field{zap.Int("rows", 3)},
+			want:   "<28>Jan  2 03:04:05 host app[" + pid + `]: slow query rows="3"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc, err := log.NewSyslogEncoder(tt.ec, tt.opts)
+			if err != nil {
+				t.Fatalf("NewSyslogEncoder() error = %v", err)
+			}
+			buf, err := enc.EncodeEntry(ent, tt.fields)
+			if err != nil {
+				t.Fatalf("EncodeEntry() error = %v", err)
+			}
+			defer buf.Free()
+
+			if got := buf.String(); got != tt.want {
+				t.Errorf("EncodeEntry(): %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewSyslogEncoderOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    log.SyslogOptions
+		wantErr bool
+	}{
+		{name: "success: no options"},
+		{name: "success: options", opts: log.SyslogOptions{Facility: "local0", AppName: "app"}},
+		{name: "failure: unknown facility", opts: log.SyslogOptions{Facility: "local8"}, wantErr: true},
+		{name: "failure: unknown format", opts: log.SyslogOptions{Format: "rfc42"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := log.NewSyslogEncoder(zapcore.EncoderConfig{}, tt.opts); (err != nil) != tt.wantErr {
+				t.Errorf("NewSyslogEncoder() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSyslogSinks(t *testing.T) {
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket() error = %v", err)
+	}
+	defer udp.Close()
+
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer tcp.Close()
+
+	dir, err := os.MkdirTemp("", "syslog")
+	if err != nil {
+		t.Fatalf("MkdirTemp() error = %v", err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "log.sock")
+	unix, err := net.ListenPacket("unixgram", socket)
+	if err != nil {
+		t.Fatalf("ListenPacket() error = %v", err)
+	}
+	defer unix.Close()
+
+	readPacket := func(conn net.PacketConn) func() (string, error) {
+		return func() (string, error) {
+			buf := make([]byte, 4096)
+			_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			n, _, err := conn.ReadFrom(buf)
+			return string(buf[:n]), err
+		}
+	}
+	readFrame := func() (string, error) {
+		conn, err := tcp.Accept()
+		if err != nil {
+			return "", err
+		}
+		defer conn.Close()
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		r := bufio.NewReader(conn)
+		length, err := r.ReadString(' ')
+		if err != nil {
+			return "", err
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(length, " "))
+		if err != nil {
+			return "", err
+		}
+		msg := make([]byte, n)
+		_, err = io.ReadFull(r, msg)
+		return string(msg), err
+	}
+
+	tests := []struct {
+		name string
+		url  string
+		read func() (string, error)
+	}{
+		{name: "success: UDP", url: "syslog+udp://" + udp.LocalAddr().String(), read: readPacket(udp)},
+		{name: "success: TCP", url: "syslog+tcp://" + tcp.Addr().String(), read: readFrame},
+		{name: "success: unix", url: "unix://" + socket, read: readPacket(unix)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hf, diags := hclparse.NewParser().ParseHCL([]byte(`
+encoding     = "syslog"
+output_paths = ["`+tt.url+`"]
+encoder_config {
+  syslog_facility = "local3"
+  syslog_app_name = "klutz"
+}`), "syslog.hcl")
+			if diags.HasErrors() {
+				t.Fatalf("ParseHCL() error = %v", diags)
+			}
+			var cfg log.Config
+			if err := cfg.UnmarshalHCL(nil, hf.Body); err != nil {
+				t.Fatalf("UnmarshalHCL() error = %v", err)
+			}
+			l, cleanup, err := cfg.Build()
+			if err != nil {
+				t.Fatalf("Build() error = %v", err)
+			}
+			defer cleanup()
+
+			l.Error("failure", zap.String("user", "alice"))
+
+			got, err := tt.read()
+			if err != nil {
+				t.Fatalf("reading message failed - %v", err)
+			}
+			want := regexp.MustCompile(`^<155>1 \S+ \S+ klutz \d+ - \[fields@32473 user="alice"\] failure$`)
+			if !want.MatchString(got) {
+				t.Errorf("reading message: %q, want match of %q", got, want)
+			}
+		})
+	}
+}
+
+func TestSyslogEncodingJSON(t *testing.T) {
+	var cfg log.Config
+	err := cfg.UnmarshalMap(map[string]interface{}{
+		"level":    "info",
+		"encoding": "syslog",
+		"encoderConfig": map[string]interface{}{
+			"syslogFormat":   "rfc3164",
+			"syslogHostname": "host",
+		},
+	})
+	if err != nil {
+		t.Fatalf("UnmarshalMap() error = %v", err)
+	}
+	if want := (log.SyslogOptions{Format: "rfc3164", Hostname: "host"}); cfg.Encoding != "syslog" || cfg.Syslog != want {
+		t.Errorf("UnmarshalMap(): encoding %q with %+v, want %q with %+v", cfg.Encoding, cfg.Syslog, "syslog", want)
+	}
+	_, cleanup, err := cfg.Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	_ = cleanup()
+}
@@ -0,0 +1,157 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package log
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// OutputConfig configures an output of a TeeConfig.
+type OutputConfig struct {
+	Name          string
+	Level         zap.AtomicLevel
+	Encoding      string
+	EncoderConfig zapcore.EncoderConfig
+	Syslog        SyslogOptions
+	TimeLayout    string
+	TimeZone      string
+	OutputPaths   []string
+}
+
+// TeeConfig configures a logger writing to several outputs, each with its own
+// level, encoding and encoder configuration, e.g. colored console output at
+// debug level to stderr and JSON at warn level to a file, cf. zapcore.NewTee.
+// Outputs replace the level, encoding settings and output paths of Config;
+// its other settings, e.g. the redaction, apply to all outputs.
+type TeeConfig struct {
+	Config  Config
+	Outputs []OutputConfig
+}
+
+// UnmarshalHCL processes a HCL configuration like Config.UnmarshalHCL,
+// but expects repeatable output blocks instead of output paths, e.g.
+//
+//	output "console" {
+//	  path     = "stderr"
+//	  level    = "debug"
+//	  encoding = "console"
+//	  encoder_config {
+//	    level_encoder = "capitalColor"
+//	  }
+//	}
+//
+// The level, encoding and encoder configuration of an output default to the
+// ones of the enclosing configuration.
+func (tc *TeeConfig) UnmarshalHCL(ctx *hcl.EvalContext, body hcl.Body) error {
+	var cfg configHCL
+
+	if diags := gohcl.DecodeBody(unmarkBody(body), ctx, &cfg); diags.HasErrors() {
+		return fmt.Errorf("UnmarshalHCL(): parsing log configuration failed - %w", diags)
+	}
+
+	var base Config
+	if diags := cfg.initConfig(&base); diags.HasErrors() {
+		return fmt.Errorf("UnmarshalHCL(): initializing configuration failed - %w", diags)
+	}
+
+	if len(cfg.Outputs) <= 0 {
+		return fmt.Errorf("UnmarshalHCL(): initializing configuration failed - %w", hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Missing output block",
+			Detail:   "At least one output block is required.",
+			Subject:  body.MissingItemRange().Ptr(),
+		}})
+	}
+	if len(base.OutputPaths) > 0 {
+		return fmt.Errorf("UnmarshalHCL(): initializing configuration failed - %w", hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Unsupported argument",
+			Detail:   "Output paths and sink blocks are not supported together with output blocks.",
+			Subject:  attributeRanges(cfg.Body, &cfg).get("output_paths"),
+		}})
+	}
+
+	outputs := make([]OutputConfig, 0, len(cfg.Outputs))
+	names := map[string]bool{}
+	for _, och := range cfg.Outputs {
+		if names[och.Name] {
+			return fmt.Errorf("UnmarshalHCL(): initializing configuration failed - %w", hcl.Diagnostics{{
+				Severity: hcl.DiagError,
+				Summary:  "Duplicate output block",
+				Detail:   fmt.Sprintf("The output %q has already been defined.", och.Name),
+				Subject:  och.Body.MissingItemRange().Ptr(),
+			}})
+		}
+		names[och.Name] = true
+
+		oc, diags := och.initOutputConfig(base)
+		if diags.HasErrors() {
+			return fmt.Errorf("UnmarshalHCL(): initializing output configuration failed - %w", diags)
+		}
+		outputs = append(outputs, oc)
+	}
+
+	tc.Config = base
+	tc.Outputs = outputs
+	return nil
+}
+
+// Build creates a logger writing to all outputs of tc. It returns the atomic
+// levels of the outputs by name, and a function, which syncs the logger and
+// closes all sinks; the logger must not be used after calling it.
+func (tc TeeConfig) Build(opts ...zap.Option) (*zap.Logger, map[string]zap.AtomicLevel, func() error, error) {
+	if len(tc.Outputs) <= 0 {
+		return nil, nil, nil, errors.New("Build(): missing outputs")
+	}
+
+	var closers []func()
+	closeAll := func() {
+		for _, closer := range closers {
+			closer()
+		}
+	}
+
+	cores := make([]zapcore.Core, 0, len(tc.Outputs))
+	levels := make(map[string]zap.AtomicLevel, len(tc.Outputs))
+	for _, out := range tc.Outputs {
+		if _, ok := levels[out.Name]; ok {
+			closeAll()
+			return nil, nil, nil, fmt.Errorf("Build(): duplicate output %q", out.Name)
+		}
+		if out.Level == (zap.AtomicLevel{}) {
+			out.Level = zap.NewAtomicLevel()
+		}
+
+		cfg := tc.Config
+		cfg.Encoding = out.Encoding
+		cfg.EncoderConfig = out.EncoderConfig
+		cfg.Syslog = out.Syslog
+		cfg.TimeLayout, cfg.TimeZone = out.TimeLayout, out.TimeZone
+		cfg.OutputPaths = out.OutputPaths
+		core, closeSinks, err := buildCore(cfg, out.Level)
+		if err != nil {
+			closeAll()
+			return nil, nil, nil, fmt.Errorf("Build(): building output %q failed - %w", out.Name, err)
+		}
+		closers = append(closers, closeSinks)
+		cores = append(cores, core)
+		levels[out.Name] = out.Level
+	}
+
+	errSink, closeErrOut, err := zap.Open(tc.Config.ErrorOutputPaths...)
+	if err != nil {
+		closeAll()
+		return nil, nil, nil, fmt.Errorf("Build(): opening error output failed - %w", err)
+	}
+	closers = append(closers, closeErrOut)
+
+	logger := zap.New(zapcore.NewTee(cores...), append(buildOptions(tc.Config.Config, errSink), opts...)...)
+	return logger, levels, cleanupFunc(logger, closeAll), nil
+}
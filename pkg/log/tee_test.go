@@ -0,0 +1,253 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package log_test
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"go.uber.org/zap"
+
+	"github.com/sobchak-security/klutz/pkg/log"
+)
+
+func TestTeeConfig(t *testing.T) {
+	dir := t.TempDir()
+	consolePath := filepath.Join(dir, "console.log")
+	jsonPath := filepath.Join(dir, "app.json")
+
+	hf, diags := hclparse.NewParser().ParseHCL([]byte(`
+level          = "info"
+disable_caller = true
+initial_fields = {
+  service = "klutz"
+}
+encoder_config {
+  message_key = "msg"
+  level_key   = "level"
+}
+redact {
+  keys = ["password"]
+}
+output "console" {
+  path     = "`+consolePath+`"
+  level    = "debug"
+  encoding = "console"
+  encoder_config {
+    message_key   = "M"
+    level_key     = "L"
+    level_encoder = "capital"
+  }
+}
+output "file" {
+  path     = "`+jsonPath+`"
+  level    = "warn"
+}`), "tee.hcl")
+	if diags.HasErrors() {
+		t.Fatalf("ParseHCL() error = %v", diags)
+	}
+
+	var tc log.TeeConfig
+	if err := tc.UnmarshalHCL(nil, hf.Body); err != nil {
+		t.Fatalf("UnmarshalHCL() error = %v", err)
+	}
+	l, levels, cleanup, err := tc.Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	l.Debug("debug")
+	l.Warn("warn", zap.String("password", "secret"))
+	levels["file"].SetLevel(zap.DebugLevel)
+	l.Debug("debug again")
+	if err := cleanup(); err != nil {
+		t.Errorf("cleanup() error = %v", err)
+	}
+
+	if got, want := testReadLog(t, consolePath),
+		"DEBUG\tdebug\t{\"service\": \"klutz\"}\nWARN\twarn\t{\"service\": \"klutz\", \"password\": \"[REDACTED]\"}\nDEBUG\tdebug again\t{\"service\": \"klutz\"}\n"; got != want {
+		t.Errorf("console output: %q, want %q", got, want)
+	}
+	if got, want := testReadLog(t, jsonPath),
+		`{"level":"warn","msg":"warn","service":"klutz","password":"[REDACTED]"}`+"\n"+`{"level":"debug","msg":"debug again","service":"klutz"}`+"\n"; got != want {
+		t.Errorf("JSON output: %q, want %q", got, want)
+	}
+}
+
+func TestTeeConfigDiagnostics(t *testing.T) {
+	tests := []struct {
+		name       string
+		conf       string
+		wrapper    bool
+		wantLine   int
+		wantDetail string
+	}{
+		{
+			name:       "failure: missing output",
+			conf:       `level = "info"`,
+			wantDetail: "At least one output block is required.",
+		},
+		{
+			name: "failure: duplicate output",
+			conf: `
+output "a" {
+  path = "stderr"
+}
+output "a" {
+  path = "stdout"
+}`,
+			wantLine:   5,
+			wantDetail: `The output "a" has already been defined.`,
+		},
+		{
+			name: "failure: output paths",
+			conf: `
+output_paths = ["stderr"]
+output "a" {
+  path = "stderr"
+}`,
+			wantLine:   2,
+			wantDetail: "not supported together with output blocks",
+		},
+		{
+			name: "failure: misspelled output level",
+			conf: `
+output "a" {
+  path  = "stderr"
+  level = "dbug"
+}`,
+			wantLine:   4,
+			wantDetail: `Did you mean "debug"?`,
+		},
+		{
+			name: "failure: output syslog options without syslog encoding",
+			conf: `
+encoding = "syslog"
+encoder_config { syslog_facility = "local0" }
+output "a" {
+  path     = "stderr"
+  encoding = "json"
+  encoder_config {
+    syslog_app_name = "app"
+  }
+}`,
+			wantLine:   8,
+			wantDetail: `Syslog options require the encoding "syslog"`,
+		},
+		{
+			name: "failure: outputs with ConfigWrapper",
+			conf: `
+output "a" {
+  path = "stderr"
+}`,
+			wrapper:    true,
+			wantLine:   2,
+			wantDetail: "only supported by TeeConfig",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hf, diags := hclparse.NewParser().ParseHCL([]byte(tt.conf), "tee.hcl")
+			if diags.HasErrors() {
+				t.Fatalf("ParseHCL() error = %v", diags)
+			}
+
+			var err error
+			if tt.wrapper {
+				var cfg zap.Config
+				err = (*log.ConfigWrapper)(&cfg).UnmarshalHCL(nil, hf.Body)
+			} else {
+				var tc log.TeeConfig
+				err = tc.UnmarshalHCL(nil, hf.Body)
+			}
+
+			var got hcl.Diagnostics
+			if !errors.As(err, &got) || len(got) != 1 {
+				t.Fatalf("UnmarshalHCL() error = %v, want single diagnostic", err)
+			}
+			if tt.wantLine > 0 && got[0].Subject.Start.Line != tt.wantLine {
+				t.Errorf("UnmarshalHCL() diagnostic subject = %v, want line %d", got[0].Subject, tt.wantLine)
+			}
+			if !strings.Contains(got[0].Detail, tt.wantDetail) {
+				t.Errorf("UnmarshalHCL() diagnostic detail = %q, want %q", got[0].Detail, tt.wantDetail)
+			}
+		})
+	}
+}
+
+func TestTeeConfigOutputEncoderConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.json")
+
+	hf, diags := hclparse.NewParser().ParseHCL([]byte(`
+encoder_config {
+  message_key   = "msg"
+  level_key     = "severity"
+  level_encoder = "lowercase"
+}
+output "file" {
+  path = "`+path+`"
+  encoder_config {
+    level_encoder = "capitalColor"
+  }
+}`), "tee.hcl")
+	if diags.HasErrors() {
+		t.Fatalf("ParseHCL() error = %v", diags)
+	}
+
+	var tc log.TeeConfig
+	if err := tc.UnmarshalHCL(nil, hf.Body); err != nil {
+		t.Fatalf("UnmarshalHCL() error = %v", err)
+	}
+	l, _, cleanup, err := tc.Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	l.Info("info")
+	if err := cleanup(); err != nil {
+		t.Errorf("cleanup() error = %v", err)
+	}
+
+	// the encoder configuration of the output overlays the enclosing one
+	if got, want := testReadLog(t, path),
+		`{"severity":"\u001b[34mINFO\u001b[0m","msg":"info"}`+"\n"; got != want {
+		t.Errorf("JSON output: %q, want %q", got, want)
+	}
+}
+
+func TestTeeConfigOutputSyslog(t *testing.T) {
+	hf, diags := hclparse.NewParser().ParseHCL([]byte(`
+encoding = "syslog"
+encoder_config {
+  syslog_facility = "local0"
+}
+output "syslog" {
+  path = "stderr"
+  encoder_config {
+    syslog_app_name = "app"
+  }
+}
+output "json" {
+  path     = "stderr"
+  encoding = "json"
+}`), "tee.hcl")
+	if diags.HasErrors() {
+		t.Fatalf("ParseHCL() error = %v", diags)
+	}
+
+	var tc log.TeeConfig
+	if err := tc.UnmarshalHCL(nil, hf.Body); err != nil {
+		t.Fatalf("UnmarshalHCL() error = %v", err)
+	}
+
+	// syslog options are inherited by outputs with the same encoding only
+	for i, want := range []log.SyslogOptions{{Facility: "local0", AppName: "app"}, {}} {
+		if got := tc.Outputs[i].Syslog; got != want {
+			t.Errorf("UnmarshalHCL() [Outputs[%d].Syslog]: %+v, want %+v", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+level        = "info"
+encoding     = "console"
+output_paths = ["stdout"]
+
+encoder_config {
+  message_key      = "M"
+  level_key        = "L"
+  time_key         = "T"
+  level_encoder    = "lowercase"
+  time_encoder     = "short"
+  duration_encoder = "seconds"
+  caller_encoder   = "short"
+}
@@ -0,0 +1,9 @@
+encoding     = "console"
+output_paths = ["stdout"]
+
+encoder_config {
+  message_key  = "M"
+  level_key    = "L"
+  time_key     = "T"
+  time_encoder = "short"
+}
@@ -0,0 +1,59 @@
+# all attributes and blocks supported by ConfigWrapper
+level              = "debug"
+encoding           = "syslog"
+output_paths       = ["stderr", "/var/log/app.log"]
+error_output_paths = ["stderr"]
+development        = true
+disable_caller     = true
+disable_stacktrace = true
+
+initial_fields = {
+  service  = "klutz"
+  region   = "eu"
+  replicas = 3
+  ratio    = 0.5
+  canary   = false
+  tags     = ["a", "b"]
+  owner    = { team = "sec", oncall = true }
+}
+
+sampling {
+  initial    = 100
+  thereafter = 10
+  tick       = "500ms"
+}
+
+encoder_config {
+  message_key       = "msg"
+  level_key         = "level"
+  time_key          = "ts"
+  name_key          = "logger"
+  caller_key        = "caller"
+  function_key      = "func"
+  stacktrace_key    = "stack"
+  line_ending       = "\r\n"
+  level_encoder     = "capital"
+  time_encoder      = "int64seconds"
+  duration_encoder  = "string"
+  caller_encoder    = "full"
+  name_encoder      = "full"
+  console_separator = " | "
+  skip_line_ending  = false
+  syslog_facility   = "local0"
+  syslog_app_name   = "klutz"
+  syslog_format     = "rfc3164"
+}
+
+sink {
+  path        = "/var/log/app-rotated.log"
+  max_size    = 10
+  max_backups = 3
+  compress    = true
+}
+
+redact {
+  keys        = ["password", "*token*"]
+  presets     = ["jwt"]
+  action      = "redact"
+  replacement = "***"
+}
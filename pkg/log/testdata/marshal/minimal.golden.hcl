@@ -0,0 +1,9 @@
+level    = "warn"
+encoding = "json"
+
+encoder_config {
+  level_encoder    = "lowercase"
+  time_encoder     = "epoch"
+  duration_encoder = "seconds"
+  caller_encoder   = "short"
+}
@@ -0,0 +1 @@
+level = "warn"
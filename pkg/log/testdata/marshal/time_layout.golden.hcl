@@ -0,0 +1,11 @@
+level    = "info"
+encoding = "console"
+
+encoder_config {
+  time_key         = "T"
+  level_encoder    = "lowercase"
+  time_layout      = "StampMilli"
+  time_zone        = "Europe/Zurich"
+  duration_encoder = "seconds"
+  caller_encoder   = "short"
+}
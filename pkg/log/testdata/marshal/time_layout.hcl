@@ -0,0 +1,7 @@
+encoding = "console"
+
+encoder_config {
+  time_key    = "T"
+  time_layout = "StampMilli"
+  time_zone   = "Europe/Zurich"
+}
@@ -0,0 +1,11 @@
+level    = "info"
+encoding = "json"
+
+encoder_config {
+  time_key         = "ts"
+  level_encoder    = "lowercase"
+  time_encoder     = "RFC3339"
+  time_zone        = "UTC"
+  duration_encoder = "seconds"
+  caller_encoder   = "short"
+}
@@ -0,0 +1,7 @@
+encoding = "json"
+
+encoder_config {
+  time_key     = "ts"
+  time_encoder = "rfc3339"
+  time_zone    = "UTC"
+}
@@ -3,7 +3,9 @@ package log_test
 import (
 	"fmt"
 	"os"
+	"regexp"
 	"testing"
+	"time"
 
 	"go.uber.org/zap/zapcore"
 )
@@ -100,3 +102,37 @@ func testCompareEncoderConfig(t *testing.T, got, want zapcore.EncoderConfig) {
 			x, y)
 	}
 }
+
+func testMatchLog(t *testing.T, got, want string) {
+	t.Helper()
+
+	ok, err := regexp.MatchString(want, got)
+	if err != nil {
+		t.Errorf("MatchString failed %v", err)
+	}
+	if !ok {
+		t.Errorf("sample log output: %q, want %q", got, want)
+	}
+}
+
+func testReadLog(t *testing.T, path string) string {
+	t.Helper()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func testWaitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		if cond() {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("condition not met in time")
+}
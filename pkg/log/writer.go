@@ -0,0 +1,222 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package log
+
+import (
+	"bytes"
+	stdlog "log"
+	"os"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// levelAliases maps lower case level names, which are detected by a Writer,
+// to levels.
+var levelAliases = map[string]zapcore.Level{
+	"trace":   zapcore.DebugLevel,
+	"debug":   zapcore.DebugLevel,
+	"info":    zapcore.InfoLevel,
+	"warn":    zapcore.WarnLevel,
+	"warning": zapcore.WarnLevel,
+	"err":     zapcore.ErrorLevel,
+	"error":   zapcore.ErrorLevel,
+	"dpanic":  zapcore.DPanicLevel,
+	"panic":   zapcore.PanicLevel,
+	"fatal":   zapcore.FatalLevel,
+}
+
+// WriterOptions configure a Writer.
+type WriterOptions struct {
+	// Level is the level of lines without a detected level; it defaults to
+	// info.
+	Level zapcore.Level
+	// DetectLevel enables the detection of a level prefix of lines, e.g.
+	// "[WARN]" or "level=error", which is removed from the message.
+	DetectLevel bool
+}
+
+// Writer is an io.WriteCloser, which logs each line written to it as a
+// message of its own, e.g. to redirect the output of a package, which writes
+// to an io.Writer, to a logger. Partial lines are buffered until their line
+// ending has been written, line endings are trimmed and empty lines are
+// skipped.
+//
+// Writing never panics or exits the process, even if a line is logged at
+// panic or fatal level. A Writer is safe for concurrent use.
+type Writer struct {
+	mu     sync.Mutex
+	logger *zap.Logger
+	opts   WriterOptions
+	buf    []byte
+	closed bool
+	// hclog enables the parsing of lines written by hclog, cf. NewHclogWriter
+	hclog bool
+}
+
+// noopHook is a zapcore.CheckWriteHook, which does nothing.
+type noopHook struct{}
+
+// OnWrite implements the zapcore.CheckWriteHook interface.
+func (noopHook) OnWrite(*zapcore.CheckedEntry, []zapcore.Field) {}
+
+// NewWriter creates a Writer logging to l.
+func NewWriter(l *zap.Logger, opts WriterOptions) *Writer {
+	return newWriter(l, opts, 0)
+}
+
+// newWriter creates a Writer logging to l, whose caller annotations
+// additionally skip the given number of callers of Write.
+func newWriter(l *zap.Logger, opts WriterOptions, skip int) *Writer {
+	return &Writer{
+		// skip Writer.log and Writer.Write
+		logger: l.WithOptions(zap.AddCallerSkip(2+skip), zap.WithFatalHook(noopHook{})),
+		opts:   opts,
+	}
+}
+
+// NewStdLog creates a logger of the standard library, which logs to l, e.g.
+// for http.Server.ErrorLog, cf. Writer.
+func NewStdLog(l *zap.Logger, opts WriterOptions) *stdlog.Logger {
+	// skip log.Logger.output and its exported caller, e.g. log.Logger.Printf
+	return stdlog.New(newWriter(l, opts, 2), "", 0)
+}
+
+// RedirectStdLog redirects the output of the standard library's global
+// logger to l, cf. Writer, and returns a function, which restores the output,
+// prefix and flags of the global logger.
+func RedirectStdLog(l *zap.Logger, opts WriterOptions) func() {
+	flags, prefix, out := stdlog.Flags(), stdlog.Prefix(), stdlog.Writer()
+
+	// skip log.Logger.output and its exported caller, e.g. log.Printf
+	w := newWriter(l, opts, 2)
+	stdlog.SetFlags(0)
+	stdlog.SetPrefix("")
+	stdlog.SetOutput(w)
+
+	return func() {
+		_ = w.Close()
+		stdlog.SetFlags(flags)
+		stdlog.SetPrefix(prefix)
+		stdlog.SetOutput(out)
+	}
+}
+
+// Write implements the io.Writer interface. It logs all complete lines of p
+// and buffers the remainder.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+
+	w.buf = append(w.buf, p...)
+	for {
+		idx := bytes.IndexByte(w.buf, '\n')
+		if idx < 0 {
+			break
+		}
+		w.log(w.buf[:idx])
+		w.buf = w.buf[idx+1:]
+	}
+	if len(w.buf) <= 0 {
+		w.buf = nil
+	}
+	return len(p), nil
+}
+
+// Flush logs a buffered partial line.
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.log(w.buf)
+	w.buf = nil
+	return nil
+}
+
+// Close implements the io.Closer interface. It logs a buffered partial line;
+// subsequent writes fail.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.log(w.buf)
+	w.buf = nil
+	w.closed = true
+	return nil
+}
+
+// log logs line; callers must hold the lock.
+func (w *Writer) log(line []byte) {
+	msg := strings.TrimRight(string(line), "\r\n")
+	if w.hclog && w.logHclog(msg) {
+		return
+	}
+
+	lvl := w.opts.Level
+	if w.opts.DetectLevel {
+		if detected, rest, ok := detectLevel(msg); ok {
+			lvl, msg = detected, rest
+		}
+	}
+	if len(msg) <= 0 {
+		return
+	}
+
+	// the logger panics after writing entries at panic level, and at dpanic
+	// level in development mode
+	defer func() {
+		_ = recover()
+	}()
+	if ce := w.logger.Check(lvl, msg); ce != nil {
+		ce.Write()
+	}
+}
+
+// detectLevel detects a level prefix of msg, e.g. "[WARN]", "level=error" or
+// `level="info"`, and returns the level and the remainder of msg.
+func detectLevel(msg string) (zapcore.Level, string, bool) {
+	s := strings.TrimLeft(msg, " \t")
+
+	var name string
+	switch {
+	case strings.HasPrefix(s, "["):
+		end := strings.IndexByte(s, ']')
+		if end < 0 {
+			return zapcore.InvalidLevel, msg, false
+		}
+		name, s = s[1:end], s[end+1:]
+	case strings.HasPrefix(s, "level="):
+		s = s[len("level="):]
+		if strings.HasPrefix(s, `"`) {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				return zapcore.InvalidLevel, msg, false
+			}
+			name, s = s[1:end+1], s[end+2:]
+		} else {
+			end := strings.IndexAny(s, " \t")
+			if end < 0 {
+				end = len(s)
+			}
+			name, s = s[:end], s[end:]
+		}
+	default:
+		return zapcore.InvalidLevel, msg, false
+	}
+
+	lvl, ok := levelAliases[strings.ToLower(name)]
+	if !ok {
+		return zapcore.InvalidLevel, msg, false
+	}
+	return lvl, strings.TrimLeft(s, " \t"), true
+}
@@ -0,0 +1,205 @@
+// Copyright (c) 2023 Remo Ronca 106963724+sobchak-security@users.noreply.github.com
+// MIT License
+
+package log_test
+
+import (
+	"errors"
+	"fmt"
+	stdlog "log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/sobchak-security/klutz/pkg/log"
+)
+
+func TestWriter(t *testing.T) {
+	type entry struct {
+		Level   zapcore.Level
+		Message string
+	}
+
+	tests := []struct {
+		name   string
+		opts   log.WriterOptions
+		writes []string
+		flush  bool
+		want   []entry
+	}{
+		{
+			name:   "success: partial writes",
+			writes: []string{"hello ", "world\nsecond", " line\r\n", "\n", "partial"},
+			want: []entry{
+				{zapcore.InfoLevel, "hello world"},
+				{zapcore.InfoLevel, "second line"},
+			},
+		},
+		{
+			name:   "success: flush partial line",
+			opts:   log.WriterOptions{Level: zapcore.WarnLevel},
+			writes: []string{"a\nb"},
+			flush:  true,
+			want: []entry{
+				{zapcore.WarnLevel, "a"},
+				{zapcore.WarnLevel, "b"},
+			},
+		},
+		{
+			name: "success: detect level",
+			opts: log.WriterOptions{Level: zapcore.DebugLevel, DetectLevel: true},
+			writes: []string{
+				"[WARN] disk almost full\n",
+				"level=error msg=failed\n",
+				"level=\"info\" started\n",
+				" [Warning]  spaces\n",
+				"[TRACE] trace\n",
+				"[unknown] kept\n",
+				"plain\n",
+			},
+			want: []entry{
+				{zapcore.WarnLevel, "disk almost full"},
+				{zapcore.ErrorLevel, "msg=failed"},
+				{zapcore.InfoLevel, "started"},
+				{zapcore.WarnLevel, "spaces"},
+				{zapcore.DebugLevel, "trace"},
+				{zapcore.DebugLevel, "[unknown] kept"},
+				{zapcore.DebugLevel, "plain"},
+			},
+		},
+		{
+			name:   "success: level prefix without detection",
+			writes: []string{"[ERROR] kept\n"},
+			want:   []entry{{zapcore.InfoLevel, "[ERROR] kept"}},
+		},
+		{
+			name:   "success: no panic or exit",
+			opts:   log.WriterOptions{DetectLevel: true},
+			writes: []string{"[DPANIC] dpanic\n[PANIC] panic\n[FATAL] fatal\n"},
+			want: []entry{
+				{zapcore.DPanicLevel, "dpanic"},
+				{zapcore.PanicLevel, "panic"},
+				{zapcore.FatalLevel, "fatal"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core, logs := observer.New(zapcore.DebugLevel)
+			w := log.NewWriter(zap.New(core, zap.Development()), tt.opts)
+
+			for _, s := range tt.writes {
+				if n, err := w.Write([]byte(s)); n != len(s) || err != nil {
+					t.Fatalf("Write(%q) = %d, %v, want %d, nil", s, n, err, len(s))
+				}
+			}
+			if tt.flush {
+				if err := w.Flush(); err != nil {
+					t.Fatalf("Flush() error = %v", err)
+				}
+			}
+
+			var got []entry
+			for _, e := range logs.AllUntimed() {
+				got = append(got, entry{e.Level, e.Message})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Writer logged %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriterClose(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	w := log.NewWriter(zap.New(core), log.WriterOptions{})
+
+	if _, err := w.Write([]byte("partial")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if _, err := w.Write([]byte("closed\n")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Write() error = %v, want %v", err, os.ErrClosed)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+
+	if got := logs.AllUntimed(); len(got) != 1 || got[0].Message != "partial" {
+		t.Errorf("Writer logged %v, want single entry %q", got, "partial")
+	}
+}
+
+func TestWriterConcurrent(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	w := log.NewWriter(zap.New(core), log.WriterOptions{})
+
+	const writers, lines = 8, 100
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < lines; j++ {
+				_, _ = fmt.Fprintf(w, "writer %d line %d\n", i, j)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if got := logs.FilterMessageSnippet("writer").Len(); got != writers*lines {
+		t.Errorf("Writer logged %d entries, want %d", got, writers*lines)
+	}
+}
+
+func TestWriterCaller(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	w := log.NewWriter(zap.New(core, zap.AddCaller()), log.WriterOptions{})
+
+	_, _ = w.Write([]byte("caller\n"))
+
+	got := logs.AllUntimed()
+	if len(got) != 1 {
+		t.Fatalf("Writer logged %d entries, want 1", len(got))
+	}
+	if file := filepath.Base(got[0].Caller.File); file != "writer_test.go" {
+		t.Errorf("Writer caller = %s, want writer_test.go", got[0].Caller)
+	}
+}
+
+func TestRedirectStdLog(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	l := zap.New(core, zap.AddCaller())
+
+	restore := log.RedirectStdLog(l, log.WriterOptions{DetectLevel: true})
+	stdlog.Printf("[WARN] global %d", 1)
+	restore()
+	log.NewStdLog(l, log.WriterOptions{}).Println("local")
+
+	got := logs.AllUntimed()
+	if len(got) != 2 {
+		t.Fatalf("standard logger logged %d entries, want 2", len(got))
+	}
+	for i, want := range []struct {
+		lvl zapcore.Level
+		msg string
+	}{{zapcore.WarnLevel, "global 1"}, {zapcore.InfoLevel, "local"}} {
+		if got[i].Level != want.lvl || got[i].Message != want.msg {
+			t.Errorf("standard logger logged %v %q, want %v %q", got[i].Level, got[i].Message, want.lvl, want.msg)
+		}
+		if file := filepath.Base(got[i].Caller.File); file != "writer_test.go" {
+			t.Errorf("standard logger caller = %s, want writer_test.go", got[i].Caller)
+		}
+	}
+	if stdlog.Writer() != os.Stderr {
+		t.Errorf("RedirectStdLog(): output not restored")
+	}
+}